@@ -15,6 +15,7 @@ Set-Location -Path $PSScriptRoot
 
 $image = "microservice-extron-sis"
 $portMapping = "80:80"
+$eventPortMapping = "8080:8080"
 
 # Find container(s) (running or stopped) for this image
 $containers = docker ps -aqf "ancestor=$image"
@@ -35,6 +36,6 @@ if ($LASTEXITCODE -ne 0) {
 }
 
 Write-Host "Starting new container..."
-docker run -d -p $portMapping $image
+docker run -d -p $portMapping -p $eventPortMapping $image
 
 Write-Host "Done."
\ No newline at end of file
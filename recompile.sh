@@ -13,4 +13,4 @@ fi
 
 # Rebuild and run
 sudo docker build -t microservice-extron-sis .
-sudo docker run -d -p 80:80 microservice-extron-sis
+sudo docker run -d -p 80:80 -p 8080:8080 microservice-extron-sis
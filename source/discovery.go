@@ -0,0 +1,172 @@
+package main
+
+// I/O size discovery.
+// The information command ("I") reports the size of the video and audio matrix, ex: "V8X6 A8X6" is 8 inputs and 6 outputs.
+// Together with the model name from the login banner this gives a plain 1:1 input/output index map
+// for models that don't have a device profile, so standard matrix switchers and switchers work without one.
+// Profiles still win: they know about names like "3A" that the size alone can't tell us.
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mefranklin6/microservice-framework/framework"
+)
+
+type ioSize struct {
+	VideoInputs  int `json:"videoInputs"`
+	VideoOutputs int `json:"videoOutputs"`
+	AudioInputs  int `json:"audioInputs,omitempty"`
+	AudioOutputs int `json:"audioOutputs,omitempty"`
+}
+
+var ioSizes = make(map[string]*ioSize) // socketKey -> discovered size
+var ioSizesMutex sync.Mutex
+
+var ioSizePattern = regexp.MustCompile(`V(\d+)X(\d+)(?:\s+A(\d+)X(\d+))?`)
+
+// Parses an information response, ex: "V8X6 A8X6".  Devices without a matrix (ex: DMP) answer with something else.
+func parseIOSize(resp string) (*ioSize, bool) {
+	m := ioSizePattern.FindStringSubmatch(resp)
+	if m == nil {
+		return nil, false
+	}
+	size := &ioSize{}
+	size.VideoInputs, _ = strconv.Atoi(m[1])
+	size.VideoOutputs, _ = strconv.Atoi(m[2])
+	if m[3] != "" {
+		size.AudioInputs, _ = strconv.Atoi(m[3])
+		size.AudioOutputs, _ = strconv.Atoi(m[4])
+	}
+	return size, true
+}
+
+// Asks a freshly logged in telnet session for its size, before anything else is sent.
+// Asked on every login: the size was forgotten with the other device tables, the device may have been swapped while we were away.
+// Talks to the transport directly like enableVerboseMode, the caller is already inside sendBasicCommandDo.
+func discoverIOSize(socketKey string) {
+	function := "discoverIOSize"
+
+	cmdString := publicGetCmdEndpoints["modelname"]
+	transport := transportFor(socketKey)
+	start := time.Now()
+	if !transport.writeLine(socketKey, cmdString) {
+		framework.AddToErrors(socketKey, function+" - failed to send information command")
+		return
+	}
+	resp := transport.readLine(socketKey)
+	var unsolicited []string
+	for skipped := 0; isUnsolicited(socketKey, cmdString, resp) && skipped < maxUnsolicitedLines; skipped++ {
+		publishNotification(socketKey, resp)
+		unsolicited = append(unsolicited, resp)
+		resp = transport.readLine(socketKey)
+	}
+	recordTranscript(socketKey, cmdString, unsolicited, resp, time.Since(start))
+
+	storeIOSize(socketKey, resp)
+}
+
+func storeIOSize(socketKey string, resp string) *ioSize {
+	function := "storeIOSize"
+
+	size, ok := parseIOSize(resp)
+	if !ok {
+		framework.Log(fmt.Sprintf("%s - %s - no I/O size in information response: %s", function, socketKey, resp))
+		size = &ioSize{} // don't ask again
+	} else {
+		framework.Log(fmt.Sprintf("%s - %s - %s is %dx%d video, %dx%d audio", function, socketKey,
+			deviceModel(socketKey), size.VideoInputs, size.VideoOutputs, size.AudioInputs, size.AudioOutputs))
+	}
+
+	ioSizesMutex.Lock()
+	ioSizes[socketKey] = size
+	ioSizesMutex.Unlock()
+	return size
+}
+
+func forgetIOSize(socketKey string) {
+	ioSizesMutex.Lock()
+	defer ioSizesMutex.Unlock()
+	delete(ioSizes, socketKey)
+}
+
+// The discovered size, asking the device if it hasn't been asked yet (ex: SSH, where there is no login to hook into)
+func findIOSize(socketKey string) (*ioSize, error) {
+	ioSizesMutex.Lock()
+	size, exists := ioSizes[socketKey]
+	ioSizesMutex.Unlock()
+	if exists {
+		return size, nil
+	}
+
+	resp, err := sendBasicCommand(socketKey, publicGetCmdEndpoints["modelname"])
+	if err != nil {
+		return nil, errors.New("error getting I/O size: " + err.Error())
+	}
+	return storeIOSize(socketKey, strings.Trim(resp, `"`)), nil
+}
+
+// Input and output name -> response index maps for a device.
+// From the device profile when it has them, otherwise numbered 1 to N from the discovered size.
+// Either map is nil when neither knows.
+func ioMaps(socketKey string, profile *deviceProfile) (map[string]int, map[string]int) {
+	var inputs, outputs map[string]int
+	if profile != nil {
+		inputs, outputs = profile.Inputs, profile.Outputs
+	}
+	if len(inputs) > 0 && len(outputs) > 0 {
+		return inputs, outputs
+	}
+
+	size, err := findIOSize(socketKey)
+	if err != nil {
+		framework.Log("ioMaps - " + socketKey + " - " + err.Error())
+		return inputs, outputs
+	}
+	if len(inputs) == 0 && size.VideoInputs > 0 {
+		inputs = numberedIO(size.VideoInputs)
+	}
+	if len(outputs) == 0 && size.VideoOutputs > 0 {
+		outputs = numberedIO(size.VideoOutputs)
+	}
+	return inputs, outputs
+}
+
+// Number of outputs the tie commands cover for a media ("1" video, "2" audio).
+// The discovered size when there is one, otherwise the highest output number in the names, ex: "4B" is output 4.
+// Not len(outputs), a DTP CrossPoint 84 has six output names for its four tie outputs.
+func tieOutputCount(socketKey string, outputs map[string]int, media string) int {
+	ioSizesMutex.Lock()
+	size := ioSizes[socketKey]
+	ioSizesMutex.Unlock()
+	if size != nil {
+		if media == "2" && size.AudioOutputs > 0 {
+			return size.AudioOutputs
+		}
+		if size.VideoOutputs > 0 {
+			return size.VideoOutputs
+		}
+	}
+
+	count := 0
+	for name := range outputs {
+		if outputNum, err := strconv.Atoi(strings.TrimRight(name, "AB")); err == nil {
+			count = max(count, outputNum)
+		}
+	}
+	return count
+}
+
+// "1" -> 0, "2" -> 1 ... count
+func numberedIO(count int) map[string]int {
+	names := make(map[string]int, count)
+	for i := 1; i <= count; i++ {
+		names[strconv.Itoa(i)] = i - 1
+	}
+	return names
+}
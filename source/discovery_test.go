@@ -0,0 +1,42 @@
+package main
+
+import "testing"
+
+func TestParseIOSize(t *testing.T) {
+	tests := []struct {
+		resp string
+		want *ioSize
+	}{
+		{"V8X6 A8X6", &ioSize{VideoInputs: 8, VideoOutputs: 6, AudioInputs: 8, AudioOutputs: 6}},
+		{"V10X8 A10X8", &ioSize{VideoInputs: 10, VideoOutputs: 8, AudioInputs: 10, AudioOutputs: 8}},
+		{"V8X4 A8X2", &ioSize{VideoInputs: 8, VideoOutputs: 4, AudioInputs: 8, AudioOutputs: 2}},
+		{"V4X1", &ioSize{VideoInputs: 4, VideoOutputs: 1}}, // video only
+		{"DMP 128 Plus C V", nil}, // no matrix
+		{"60-1238-01", nil},
+		{"E10", nil},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		got, ok := parseIOSize(tt.resp)
+		switch {
+		case tt.want == nil && ok:
+			t.Errorf("parseIOSize(%q) = %+v, want no size", tt.resp, *got)
+		case tt.want != nil && (!ok || *got != *tt.want):
+			t.Errorf("parseIOSize(%q) = %+v, %v, want %+v", tt.resp, got, ok, *tt.want)
+		}
+	}
+}
+
+// The size goes with the other device tables, so a device swapped while we were away is asked again
+func TestForgetIOSize(t *testing.T) {
+	socketKey := "telnet|admin:extron@forget_io_size"
+	storeIOSize(socketKey, "V8X4 A8X4")
+	forgetDeviceTables(socketKey)
+
+	ioSizesMutex.Lock()
+	_, known := ioSizes[socketKey]
+	ioSizesMutex.Unlock()
+	if known {
+		t.Error("the size should be forgotten with the device tables")
+	}
+}
@@ -3,13 +3,15 @@ package main
 import (
 	"errors"
 	"fmt"
+	"regexp"
 	"strconv"
 	"strings"
 )
 
 // DMP's are static-architecture DSP's that rely on a matrix of mix points
 // This file handles the maths to determine mix point numbers
-// These were built using a DMP 128 Plus C V AT
+// These were built using a DMP 128 Plus C V AT.  The address layout is the same across models,
+// only the number of channels differs (see dmpModels), so every mix point is checked against the model's counts.
 
 type TableKey string
 
@@ -41,78 +43,120 @@ var dmpBaseAddr = map[TableKey]int{
 	VRetToEXPOut: 22032, // Virtual Return to EXP Output Mix Matrix
 }
 
-// Internal: acts as a router to the sub-helper functions for calculating DMP mix points
-func calculateDmpMixPointNumber(input, output string) (string, error) {
+// Channel counts of a DMP model.  AT (Dante) inputs are numbered after the mic/line inputs
+// and AT outputs after the analog outputs, ex: on a DMP 128 Plus AT, "MicToOut13" is AT input 1.
+type dmpModel struct {
+	Name           string
+	MicInputs      int // mic/line
+	ATInputs       int
+	Outputs        int // analog
+	ATOutputs      int
+	VirtualSends   int // A, B, ...
+	VirtualReturns int
+	EXPInputs      int // expansion bus, 0 without an EXP port
+	EXPOutputs     int
+	Groups         int // group masters
+}
+
+// Keyed by family, then " Plus" and " AT" when the model name has them (see findDmpModel)
+var dmpModels = map[string]dmpModel{
+	"DMP 64":          {Name: "DMP 64", MicInputs: 6, Outputs: 4, VirtualSends: 8, VirtualReturns: 8, Groups: 32},
+	"DMP 64 Plus":     {Name: "DMP 64 Plus", MicInputs: 6, Outputs: 4, VirtualSends: 16, VirtualReturns: 16, Groups: 64, EXPInputs: 16, EXPOutputs: 16},
+	"DMP 64 Plus AT":  {Name: "DMP 64 Plus AT", MicInputs: 6, ATInputs: 8, Outputs: 4, ATOutputs: 8, VirtualSends: 16, VirtualReturns: 16, Groups: 64, EXPInputs: 16, EXPOutputs: 16},
+	"DMP 128":         {Name: "DMP 128", MicInputs: 12, Outputs: 8, VirtualSends: 8, VirtualReturns: 8, Groups: 32, EXPInputs: 16, EXPOutputs: 16},
+	"DMP 128 AT":      {Name: "DMP 128 AT", MicInputs: 12, ATInputs: 8, Outputs: 8, ATOutputs: 8, VirtualSends: 8, VirtualReturns: 8, Groups: 32, EXPInputs: 16, EXPOutputs: 16},
+	"DMP 128 Plus":    {Name: "DMP 128 Plus", MicInputs: 12, Outputs: 8, VirtualSends: 16, VirtualReturns: 16, Groups: 64, EXPInputs: 16, EXPOutputs: 16},
+	"DMP 128 Plus AT": {Name: "DMP 128 Plus AT", MicInputs: 12, ATInputs: 8, Outputs: 8, ATOutputs: 8, VirtualSends: 16, VirtualReturns: 16, Groups: 64, EXPInputs: 16, EXPOutputs: 16},
+}
+
+var dmpFamilyPattern = regexp.MustCompile(`DMP ?(64|128)`)
+var dmpATPattern = regexp.MustCompile(`\bAT\b`)
+
+// The channel counts for a model name, ex: "DMP 128 Plus C V AT" is a "DMP 128 Plus AT"
+func findDmpModel(model string) (*dmpModel, error) {
+	m := dmpFamilyPattern.FindStringSubmatch(model)
+	if m == nil {
+		return nil, fmt.Errorf("mix points are not known for model '%s'", model)
+	}
+	key := "DMP " + m[1]
+	if strings.Contains(model, "Plus") {
+		key += " Plus"
+	}
+	if dmpATPattern.MatchString(model) {
+		key += " AT"
+	}
+	counts, ok := dmpModels[key]
+	if !ok {
+		return nil, fmt.Errorf("mix points are not known for model '%s' (%s)", model, key)
+	}
+	return &counts, nil
+}
+
+// Internal: acts as a router to the sub-helper functions for calculating DMP mix points.
+// model is the model name (deviceModels), it decides which channels exist.
+func calculateDmpMixPointNumber(model, input, output string) (string, error) {
 	function := "calculateDmpMixPointNumber"
 
+	dmp, err := findDmpModel(model)
+	if err != nil {
+		return "", err
+	}
+
 	var prefix string
 	var mixPointNum string
-	var err error
 
 	switch {
 	case strings.HasPrefix(input, "MicToOut"):
 		prefix = "MicToOut"
 		input = strings.TrimPrefix(input, prefix)
-		mixPointNum, err = micInputToOutputNumber(input, output)
-		if err != nil {
-			return "", err
-		}
+		mixPointNum, err = micInputToOutputNumber(dmp, input, output)
 	case strings.HasPrefix(input, "VRetToOut"):
 		prefix = "VRetToOut"
 		input = strings.TrimPrefix(input, prefix)
-		mixPointNum, err = virtualReturnToOutput(input, output)
-		if err != nil {
-			return "", err
-		}
+		mixPointNum, err = virtualReturnToOutput(dmp, input, output)
 	case strings.HasPrefix(input, "EXPInToOut"):
 		prefix = "EXPInToOut"
 		input = strings.TrimPrefix(input, prefix)
-		mixPointNum, err = eXPInputToOutput(input, output)
-		if err != nil {
-			return "", err
-		}
+		mixPointNum, err = eXPInputToOutput(dmp, input, output)
 	case strings.HasPrefix(input, "MicToSend"):
 		prefix = "MicToSend"
 		input = strings.TrimPrefix(input, prefix)
-		mixPointNum, err = micInputToVirtualSend(input, output)
-		if err != nil {
-			return "", err
-		}
+		mixPointNum, err = micInputToVirtualSend(dmp, input, output)
 	case strings.HasPrefix(input, "VRetToSend"):
 		prefix = "VRetToSend"
 		input = strings.TrimPrefix(input, prefix)
-		mixPointNum, err = virtualReturnToVirtualSend(input, output)
-		if err != nil {
-			return "", err
-		}
+		mixPointNum, err = virtualReturnToVirtualSend(dmp, input, output)
 	case strings.HasPrefix(input, "EXPInToSend"):
 		prefix = "EXPInToSend"
 		input = strings.TrimPrefix(input, prefix)
-		mixPointNum, err = eXPInputToVirtualSend(input, output)
-		if err != nil {
-			return "", err
-		}
+		mixPointNum, err = eXPInputToVirtualSend(dmp, input, output)
 	case strings.HasPrefix(input, "MicToEXPOut"):
 		prefix = "MicToEXPOut"
 		input = strings.TrimPrefix(input, prefix)
-		mixPointNum, err = micInputToEXPOutput(input, output)
-		if err != nil {
-			return "", err
-		}
+		mixPointNum, err = micInputToEXPOutput(dmp, input, output)
 	case strings.HasPrefix(input, "VRetToEXPOut"):
 		prefix = "VRetToEXPOut"
 		input = strings.TrimPrefix(input, prefix)
-		mixPointNum, err = virtualReturnToEXPOutput(input, output)
-		if err != nil {
-			return "", err
-		}
+		mixPointNum, err = virtualReturnToEXPOutput(dmp, input, output)
 	default:
 		errMsg := fmt.Sprintf(function+" - unknown input type: %s", input)
 		return "", errors.New(errMsg)
 	}
+	if err != nil {
+		return "", fmt.Errorf("%s on %s: %v", prefix, dmp.Name, err)
+	}
 	return mixPointNum, nil
 }
 
+// Internal: the mix point number on the device at socketKey, with the channels of its model
+func deviceMixPointNumber(socketKey, input, output string) (string, error) {
+	model, err := findModelName(socketKey)
+	if err != nil {
+		return "", err
+	}
+	return calculateDmpMixPointNumber(model, input, output)
+}
+
 // Internal: calculates the mix-point number for the DMP
 func dmpCalc(table TableKey, row int, col int) (string, error) {
 	// get the base address for the table
@@ -122,174 +166,371 @@ func dmpCalc(table TableKey, row int, col int) (string, error) {
 	}
 
 	// apply the DMP mix-point formula
-	intResult := base + 100*row + col
+	return strconv.Itoa(base + 100*row + col), nil
+}
 
-	// convert back to string
-	strResult := strconv.Itoa(intResult)
-	if strResult == "" {
-		return "", fmt.Errorf("input %s, output %s not supported", row, col)
+// Internal: a 1-based channel number, checked against the model's count.  Returns the 0-based row or column
+func dmpChannelNumber(kind string, value string, count int) (int, error) {
+	if count == 0 {
+		return 0, fmt.Errorf("this model has no %ss", kind)
+	}
+	num, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s %s is not a valid number", kind, value)
+	}
+	if num < 1 || num > count {
+		return 0, fmt.Errorf("%s %d is out of range, this model has %ss 1-%d", kind, num, kind, count)
+	}
+	return num - 1, nil
+}
+
+// Internal: a virtual send or return letter (A, B, ...), checked against the model's count.  Returns the 0-based row or column
+func dmpChannelLetter(kind string, value string, count int) (int, error) {
+	if count == 0 {
+		return 0, fmt.Errorf("this model has no %ss", kind)
+	}
+	last := rune('A' + count - 1)
+	if len(value) != 1 {
+		return 0, fmt.Errorf("%s A-%c expected, got %s", kind, last, value)
+	}
+	letter := rune(value[0])
+	if letter < 'A' || letter > last {
+		return 0, fmt.Errorf("%s %c is out of range, this model has %ss A-%c", kind, letter, kind, last)
 	}
-	return strResult, nil
+	return int(letter - 'A'), nil
 }
 
 // MicInputToOutput returns Table 3 mix-point: Mic/Line input → Line out
-func micInputToOutputNumber(input, output string) (string, error) {
-	inputInt, err := strconv.Atoi(input)
+func micInputToOutputNumber(dmp *dmpModel, input, output string) (string, error) {
+	row, err := dmpChannelNumber("input", input, dmp.MicInputs+dmp.ATInputs)
 	if err != nil {
-		return "", fmt.Errorf("input %s is not a number", input)
+		return "", err
 	}
-	outputInt, err := strconv.Atoi(output)
+	col, err := dmpChannelNumber("output", output, dmp.Outputs+dmp.ATOutputs)
 	if err != nil {
-		return "", fmt.Errorf("output %s is not a number", output)
+		return "", err
 	}
+	return dmpCalc(MicToOut, row, col)
+}
 
-	res, err := dmpCalc(MicToOut, inputInt-1, outputInt-1)
+// virtualReturnToOutput returns Table 4 mix-point: Virtual Return → Line out
+func virtualReturnToOutput(dmp *dmpModel, vret, output string) (string, error) {
+	row, err := dmpChannelLetter("return", vret, dmp.VirtualReturns)
 	if err != nil {
-		return "", fmt.Errorf("error calculating mix-point: %s", err)
+		return "", err
 	}
-
-	return res, nil
+	col, err := dmpChannelNumber("output", output, dmp.Outputs+dmp.ATOutputs)
+	if err != nil {
+		return "", err
+	}
+	return dmpCalc(VRetToOut, row, col)
 }
 
-// virtualReturnToOutput returns Table 4 mix-point: Virtual Return A-H → Line out
-func virtualReturnToOutput(vret, output string) (string, error) {
-	// Convert vret to a rune and validate it
-	if len(vret) != 1 {
-		return "", fmt.Errorf("return A-H expected, got %s", vret)
+// eXPInputToOutput returns Table 5 mix-point: EXP input → Line out
+func eXPInputToOutput(dmp *dmpModel, expIn, output string) (string, error) {
+	row, err := dmpChannelNumber("EXP input", expIn, dmp.EXPInputs)
+	if err != nil {
+		return "", err
 	}
-	vretRune := rune(vret[0])
-	if vretRune < 'A' || vretRune > 'H' {
-		return "", fmt.Errorf("return A-H expected, got %c", vretRune)
+	col, err := dmpChannelNumber("output", output, dmp.Outputs+dmp.ATOutputs)
+	if err != nil {
+		return "", err
 	}
+	return dmpCalc(EXPInToOut, row, col)
+}
 
-	// Convert output to an integer and validate it
-	outputInt, err := strconv.Atoi(output)
+// micInputToVirtualSend returns Table 6 mix-point: Mic/Line input → Virtual Send
+func micInputToVirtualSend(dmp *dmpModel, input string, send string) (string, error) {
+	row, err := dmpChannelNumber("input", input, dmp.MicInputs+dmp.ATInputs)
 	if err != nil {
-		return "", fmt.Errorf("output %s is not a valid number", output)
+		return "", err
 	}
-	if outputInt < 1 {
-		return "", fmt.Errorf("output must be at least 1, got %d", outputInt)
+	col, err := dmpChannelLetter("send", send, dmp.VirtualSends)
+	if err != nil {
+		return "", err
 	}
-
-	// Perform the mix-point calculation
-	return dmpCalc(VRetToOut, int(vretRune-'A'), outputInt-1)
+	return dmpCalc(MicToSend, row, col)
 }
 
-// eXPInputToOutput returns Table 5 mix-point: EXP input → Line out
-func eXPInputToOutput(expIn, output string) (string, error) {
-	// Convert expIn and output to integers
-	expInInt, err := strconv.Atoi(expIn)
+// virtualReturnToVirtualSend returns Table 7 mix-point: Virtual Return → Virtual Send
+func virtualReturnToVirtualSend(dmp *dmpModel, vret, send string) (string, error) {
+	row, err := dmpChannelLetter("return", vret, dmp.VirtualReturns)
 	if err != nil {
-		return "", fmt.Errorf("input %s is not a valid number", expIn)
+		return "", err
 	}
-	outputInt, err := strconv.Atoi(output)
+	col, err := dmpChannelLetter("send", send, dmp.VirtualSends)
 	if err != nil {
-		return "", fmt.Errorf("output %s is not a valid number", output)
+		return "", err
 	}
-
-	// Perform the mix-point calculation
-	return dmpCalc(EXPInToOut, expInInt-1, outputInt-1)
+	return dmpCalc(VRetToSend, row, col)
 }
 
-// micInputToVirtualSend returns Table 6 mix-point: Mic/Line input → Send A-H
-func micInputToVirtualSend(input string, send string) (string, error) {
-	// Convert input to an integer
-	inputInt, err := strconv.Atoi(input)
+// eXPInputToVirtualSend returns Table 8 mix-point: EXP input → Virtual Send
+func eXPInputToVirtualSend(dmp *dmpModel, expIn string, send string) (string, error) {
+	row, err := dmpChannelNumber("EXP input", expIn, dmp.EXPInputs)
 	if err != nil {
-		return "", fmt.Errorf("input %s is not a valid number", input)
+		return "", err
 	}
+	col, err := dmpChannelLetter("send", send, dmp.VirtualSends)
+	if err != nil {
+		return "", err
+	}
+	return dmpCalc(EXPInToSend, row, col)
+}
 
-	// Convert send to a rune and validate it
-	if len(send) != 1 {
-		return "", fmt.Errorf("send A-H expected, got %s", send)
+// micInputToEXPOutput returns Table 9 mix-point: Mic/Line input → EXP out
+func micInputToEXPOutput(dmp *dmpModel, input, expOut string) (string, error) {
+	row, err := dmpChannelNumber("input", input, dmp.MicInputs+dmp.ATInputs)
+	if err != nil {
+		return "", err
 	}
-	sendRune := rune(send[0])
-	if sendRune < 'A' || sendRune > 'H' {
-		return "", fmt.Errorf("send A-H expected, got %c", sendRune)
+	col, err := dmpChannelNumber("EXP output", expOut, dmp.EXPOutputs)
+	if err != nil {
+		return "", err
 	}
-
-	// Perform the mix-point calculation
-	return dmpCalc(MicToSend, inputInt-1, int(sendRune-'A'))
+	return dmpCalc(MicToEXPOut, row, col)
 }
 
-// virtualReturnToVirtualSend returns Table 7 mix-point: Virtual Return A-H → Send A-H
-func virtualReturnToVirtualSend(vret, send string) (string, error) {
-	// Convert vret to a rune and validate it
-	if len(vret) != 1 {
-		return "", fmt.Errorf("return A-H expected, got %s", vret)
+// virtualReturnToEXPOutput returns Table 10 mix-point: Virtual Return → EXP out
+func virtualReturnToEXPOutput(dmp *dmpModel, vret, expOut string) (string, error) {
+	row, err := dmpChannelLetter("return", vret, dmp.VirtualReturns)
+	if err != nil {
+		return "", err
 	}
-	vretRune := rune(vret[0])
-	if vretRune < 'A' || vretRune > 'H' {
-		return "", fmt.Errorf("return A-H expected, got %c", vretRune)
+	col, err := dmpChannelNumber("EXP output", expOut, dmp.EXPOutputs)
+	if err != nil {
+		return "", err
 	}
+	return dmpCalc(VRetToEXPOut, row, col)
+}
 
-	// Convert send to a rune and validate it
-	if len(send) != 1 {
-		return "", fmt.Errorf("send A-H expected, got %s", send)
+///////////////////////////////////////////////////////////////////////////////
+// Object ID to mix point //
+///////////////////////////////////////////////////////////////////////////////
+
+// A decoded mix point, with Input and Output as the matrixmute/matrixvolume endpoints take them
+type dmpMixPoint struct {
+	OID    string   `json:"oid"`
+	Table  TableKey `json:"table"`
+	Input  string   `json:"input"`  // ex: "MicToOut13"
+	Output string   `json:"output"` // ex: "6"
+	Name   string   `json:"name"`   // ex: "AT input 1 to output 6"
+}
+
+// The tables share rows by source and columns by destination (see dmpBaseAddr).
+// Mic/line rows run to 22000, so there is room for 20 of them, the others have 16.
+var dmpTableRows = []struct {
+	source string
+	base   int
+	rows   int
+}{
+	{"Mic", 20000, 20},
+	{"VRet", 22000, 16},
+	{"EXPIn", 23600, 16},
+}
+var dmpTableColumns = []struct {
+	destination string
+	offset      int
+}{
+	{"Out", 0},
+	{"Send", 16},
+	{"EXPOut", 32},
+}
+
+const dmpColumnsPerTable = 16
+
+// Internal: the inverse of calculateDmpMixPointNumber, ex: "21205" is MicToOut13, output 6 (AT input 1) on a DMP 128 Plus AT.
+// The mix point has to exist on model, so the same object ID is refused on a DMP 128 Plus without AT.
+func decodeDmpMixPoint(model, oid string) (*dmpMixPoint, error) {
+	dmp, err := findDmpModel(model)
+	if err != nil {
+		return nil, err
 	}
-	sendRune := rune(send[0])
-	if sendRune < 'A' || sendRune > 'H' {
-		return "", fmt.Errorf("send A-H expected, got %c", sendRune)
+	num, err := strconv.Atoi(oid)
+	if err != nil || !isMixPointOID(oid) {
+		return nil, fmt.Errorf("%s is not a mix point object ID", oid)
 	}
 
-	// Perform the mix-point calculation
-	return dmpCalc(VRetToSend, int(vretRune-'A'), int(sendRune-'A'))
+	for _, r := range dmpTableRows {
+		offset := num - r.base
+		row, col := offset/100, offset%100
+		if offset < 0 || row >= r.rows {
+			continue
+		}
+		for _, c := range dmpTableColumns {
+			if col < c.offset || col >= c.offset+dmpColumnsPerTable {
+				continue
+			}
+			table := TableKey(r.source + "To" + c.destination)
+			if _, exists := dmpBaseAddr[table]; !exists {
+				return nil, fmt.Errorf("%s would be %s, which is not a mix point table", oid, table)
+			}
+			mixPoint := &dmpMixPoint{
+				OID:    oid,
+				Table:  table,
+				Input:  string(table) + dmpRowLabel(r.source, row),
+				Output: dmpColumnLabel(c.destination, col-c.offset),
+			}
+			// checks the channels exist on this model, and that the tables agree both ways
+			check, err := calculateDmpMixPointNumber(model, mixPoint.Input, mixPoint.Output)
+			if err != nil {
+				return nil, fmt.Errorf("%s is %s/%s, but %v", oid, mixPoint.Input, mixPoint.Output, err)
+			}
+			if check != oid {
+				return nil, fmt.Errorf("%s decoded to %s/%s, which is %s", oid, mixPoint.Input, mixPoint.Output, check)
+			}
+			mixPoint.Name = dmpRowName(dmp, r.source, row) + " to " + dmpColumnName(dmp, c.destination, col-c.offset)
+			return mixPoint, nil
+		}
+	}
+	return nil, fmt.Errorf("%s is outside the mix point tables", oid)
 }
 
-// eXPInputToVirtualSend returns Table 8 mix-point: EXP input → Send A-H
-func eXPInputToVirtualSend(expIn string, send string) (string, error) {
-	// Convert expIn to an integer
-	expInInt, err := strconv.Atoi(expIn)
+// Internal: an object ID with the mix point it is, for logs and errors, ex: "21205 (MicToOut13/6)".
+// Just the object ID if it can't be decoded.
+func dmpMixPointLabel(model, oid string) string {
+	mixPoint, err := decodeDmpMixPoint(model, oid)
 	if err != nil {
-		return "", fmt.Errorf("input %s is not a valid number", expIn)
+		return oid
 	}
+	return fmt.Sprintf("%s (%s/%s)", oid, mixPoint.Input, mixPoint.Output)
+}
 
-	// Convert send to a rune and validate it
-	if len(send) != 1 {
-		return "", fmt.Errorf("send A-H expected, got %s", send)
+// Row and column labels, as the endpoints take them: numbers are 1 based, virtual sends and returns are letters
+func dmpRowLabel(source string, row int) string {
+	if source == "VRet" {
+		return string(rune('A' + row))
 	}
-	sendRune := rune(send[0])
-	if sendRune < 'A' || sendRune > 'H' {
-		return "", fmt.Errorf("send A-H expected, got %c", sendRune)
+	return strconv.Itoa(row + 1)
+}
+
+func dmpColumnLabel(destination string, col int) string {
+	if destination == "Send" {
+		return string(rune('A' + col))
 	}
+	return strconv.Itoa(col + 1)
+}
 
-	// Perform the mix-point calculation
-	return dmpCalc(EXPInToSend, expInInt-1, int(sendRune-'A'))
+// Row and column names as the DMP documentation has them, ex: "mic/line input 3", "AT input 1"
+func dmpRowName(dmp *dmpModel, source string, row int) string {
+	switch source {
+	case "VRet":
+		return "virtual return " + dmpRowLabel(source, row)
+	case "EXPIn":
+		return "EXP input " + dmpRowLabel(source, row)
+	}
+	if row >= dmp.MicInputs {
+		return fmt.Sprintf("AT input %d", row-dmp.MicInputs+1)
+	}
+	return "mic/line input " + dmpRowLabel(source, row)
 }
 
-// micInputToEXPOutput returns Table 9 mix-point: Mic/Line input → EXP out
-func micInputToEXPOutput(input, expOut string) (string, error) {
-	// Convert input and expOut to integers
-	inputInt, err := strconv.Atoi(input)
-	if err != nil {
-		return "", fmt.Errorf("input %s is not a valid number", input)
+func dmpColumnName(dmp *dmpModel, destination string, col int) string {
+	switch destination {
+	case "Send":
+		return "virtual send " + dmpColumnLabel(destination, col)
+	case "EXPOut":
+		return "EXP output " + dmpColumnLabel(destination, col)
 	}
-	expOutInt, err := strconv.Atoi(expOut)
-	if err != nil {
-		return "", fmt.Errorf("output %s is not a valid number", expOut)
+	if col >= dmp.Outputs {
+		return fmt.Sprintf("AT output %d", col-dmp.Outputs+1)
 	}
+	return "output " + dmpColumnLabel(destination, col)
+}
 
-	// Perform the mix-point calculation
-	return dmpCalc(MicToEXPOut, inputInt-1, expOutInt-1)
+///////////////////////////////////////////////////////////////////////////////
+// Gain blocks //
+///////////////////////////////////////////////////////////////////////////////
+
+// A row of gain blocks outside the mix matrix, one object ID per channel from BaseOID up.
+// They take the same level (G) and mute (M) commands as mix points, but each has its own range.
+type dmpGainBlock struct {
+	Name         string
+	Endpoint     string // level endpoint
+	MuteEndpoint string
+	BaseOID      int                     // channel 1, or A
+	Letters      bool                    // virtual sends and returns are A, B, ...
+	Channels     func(dmp *dmpModel) int // how many this model has
+	MinTenths    int                     // tenths of dB
+	MaxTenths    int
 }
 
-// virtualReturnToEXPOutput returns Table 10 mix-point: Virtual Return A-H → EXP out
-func virtualReturnToEXPOutput(vret, expOut string) (string, error) {
-	// Convert vret to a rune and validate it
-	if len(vret) != 1 {
-		return "", fmt.Errorf("return A-H expected, got %s", vret)
+var dmpInputGain = dmpGainBlock{
+	Name: "input gain", Endpoint: "dmpinputgain", MuteEndpoint: "dmpinputmute", BaseOID: 40000, MinTenths: -180, MaxTenths: 800, // mic preamp and digital gain, -18 to +80 dB
+	Channels: func(dmp *dmpModel) int { return dmp.MicInputs + dmp.ATInputs },
+}
+var dmpPremixerGain = dmpGainBlock{
+	Name: "pre-mixer gain", Endpoint: "dmppremixergain", MuteEndpoint: "dmppremixermute", BaseOID: 40100, MinTenths: -1000, MaxTenths: 120,
+	Channels: func(dmp *dmpModel) int { return dmp.MicInputs + dmp.ATInputs },
+}
+var dmpVirtualReturnGain = dmpGainBlock{
+	Name: "virtual return gain", Endpoint: "dmpvirtualreturngain", MuteEndpoint: "dmpvirtualreturnmute", BaseOID: 50500, Letters: true, MinTenths: -1000, MaxTenths: 120,
+	Channels: func(dmp *dmpModel) int { return dmp.VirtualReturns },
+}
+var dmpOutputVolume = dmpGainBlock{
+	Name: "output attenuation", Endpoint: "dmpoutputvolume", MuteEndpoint: "dmpoutputmute", BaseOID: 60000, MinTenths: -1000, MaxTenths: 0, // attenuation only
+	Channels: func(dmp *dmpModel) int { return dmp.Outputs + dmp.ATOutputs },
+}
+
+// Gain and mute endpoints, by the block they control
+var dmpGainBlocks = map[string]*dmpGainBlock{
+	"dmpinputgain":         &dmpInputGain,
+	"dmpinputmute":         &dmpInputGain,
+	"dmppremixergain":      &dmpPremixerGain,
+	"dmppremixermute":      &dmpPremixerGain,
+	"dmpvirtualreturngain": &dmpVirtualReturnGain,
+	"dmpvirtualreturnmute": &dmpVirtualReturnGain,
+	"dmpoutputvolume":      &dmpOutputVolume,
+	"dmpoutputmute":        &dmpOutputVolume,
+}
+
+// Internal: the object ID of a gain block channel on model, ex: output 3 volume is 60002
+func calculateDmpGainBlockNumber(model string, block *dmpGainBlock, channel string) (string, error) {
+	dmp, err := findDmpModel(model)
+	if err != nil {
+		return "", err
 	}
-	vretRune := rune(vret[0])
-	if vretRune < 'A' || vretRune > 'H' {
-		return "", fmt.Errorf("return A-H expected, got %c", vretRune)
+
+	var offset int
+	if block.Letters {
+		offset, err = dmpChannelLetter("channel", channel, block.Channels(dmp))
+	} else {
+		offset, err = dmpChannelNumber("channel", channel, block.Channels(dmp))
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s on %s: %v", block.Name, dmp.Name, err)
 	}
+	return strconv.Itoa(block.BaseOID + offset), nil
+}
 
-	// Convert expOut to an integer
-	expOutInt, err := strconv.Atoi(expOut)
+// Internal: the gain block object ID on the device at socketKey, with the channels of its model
+func deviceGainBlockNumber(socketKey string, block *dmpGainBlock, channel string) (string, error) {
+	model, err := findModelName(socketKey)
 	if err != nil {
-		return "", fmt.Errorf("output %s is not a valid number", expOut)
+		return "", err
 	}
+	return calculateDmpGainBlockNumber(model, block, channel)
+}
 
-	// Perform the mix-point calculation
-	return dmpCalc(VRetToEXPOut, int(vretRune-'A'), expOutInt-1)
+// Internal: the gain block channel an object ID is, ex: "60002" is output attenuation, channel "3".  For notifications
+func decodeDmpGainBlock(model, oid string) (*dmpGainBlock, string, bool) {
+	dmp, err := findDmpModel(model)
+	if err != nil {
+		return nil, "", false
+	}
+	num, err := strconv.Atoi(oid)
+	if err != nil {
+		return nil, "", false
+	}
+	for _, block := range dmpGainBlocks {
+		offset := num - block.BaseOID
+		if offset < 0 || offset >= block.Channels(dmp) {
+			continue
+		}
+		if block.Letters {
+			return block, string(rune('A' + offset)), true
+		}
+		return block, strconv.Itoa(offset + 1), true
+	}
+	return nil, "", false
 }
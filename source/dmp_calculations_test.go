@@ -0,0 +1,360 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// The models in order, so failures come out the same way every run
+func dmpModelNames() []string {
+	names := make([]string, 0, len(dmpModels))
+	for name := range dmpModels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// A mix point and the object ID it is, or the error it gets
+type mixPointCase struct {
+	input  string
+	output string
+	want   string
+}
+
+func TestCalculateDmpMixPointNumber(t *testing.T) {
+	for _, name := range dmpModelNames() {
+		dmp := dmpModels[name]
+		inputs, outputs := dmp.MicInputs+dmp.ATInputs, dmp.Outputs+dmp.ATOutputs
+		lastSend := string(rune('A' + dmp.VirtualSends - 1))
+		lastReturn := string(rune('A' + dmp.VirtualReturns - 1))
+
+		tests := []mixPointCase{
+			{"MicToOut1", "1", "20000"},
+			{"MicToOut" + strconv.Itoa(inputs), strconv.Itoa(outputs), strconv.Itoa(20000 + 100*(inputs-1) + outputs - 1)},
+			{"MicToSend1", "A", "20016"},
+			{"MicToSend1", lastSend, strconv.Itoa(20016 + dmp.VirtualSends - 1)},
+			{"VRetToOut" + lastReturn, "1", strconv.Itoa(22000 + 100*(dmp.VirtualReturns-1))},
+			{"VRetToSend" + lastReturn, lastSend, strconv.Itoa(22016 + 100*(dmp.VirtualReturns-1) + dmp.VirtualSends - 1)},
+		}
+		if dmp.ATInputs > 0 { // AT inputs and outputs are numbered after the mic/line inputs and analog outputs
+			tests = append(tests,
+				mixPointCase{"MicToOut" + strconv.Itoa(dmp.MicInputs+1), "1", strconv.Itoa(20000 + 100*dmp.MicInputs)},
+				mixPointCase{"MicToOut1", strconv.Itoa(dmp.Outputs + 1), strconv.Itoa(20000 + dmp.Outputs)},
+			)
+		}
+		if dmp.EXPInputs > 0 {
+			tests = append(tests,
+				mixPointCase{"EXPInToOut1", "1", "23600"},
+				mixPointCase{"EXPInToSend16", "A", "25116"},
+				mixPointCase{"MicToEXPOut1", "16", "20047"},
+				mixPointCase{"VRetToEXPOut" + lastReturn, "1", strconv.Itoa(22032 + 100*(dmp.VirtualReturns-1))},
+			)
+		}
+		for _, tt := range tests {
+			got, err := calculateDmpMixPointNumber(name, tt.input, tt.output)
+			if err != nil || got != tt.want {
+				t.Errorf("%s: %s/%s = %s, %v, want %s", name, tt.input, tt.output, got, err, tt.want)
+			}
+		}
+	}
+}
+
+func TestCalculateDmpMixPointNumberRejects(t *testing.T) {
+	for _, name := range dmpModelNames() {
+		dmp := dmpModels[name]
+		inputs, outputs := dmp.MicInputs+dmp.ATInputs, dmp.Outputs+dmp.ATOutputs
+		pastSend := string(rune('A' + dmp.VirtualSends))
+		pastReturn := string(rune('A' + dmp.VirtualReturns))
+
+		tests := []mixPointCase{
+			{"MicToOut99", "40", fmt.Sprintf("MicToOut on %s: input 99 is out of range, this model has inputs 1-%d", name, inputs)},
+			{"MicToOut1", "40", fmt.Sprintf("MicToOut on %s: output 40 is out of range, this model has outputs 1-%d", name, outputs)},
+			{"MicToOut" + strconv.Itoa(inputs+1), "1", fmt.Sprintf("MicToOut on %s: input %d is out of range, this model has inputs 1-%d", name, inputs+1, inputs)},
+			{"MicToOut0", "1", fmt.Sprintf("MicToOut on %s: input 0 is out of range, this model has inputs 1-%d", name, inputs)},
+			{"MicToOutA", "1", fmt.Sprintf("MicToOut on %s: input A is not a valid number", name)},
+			{"MicToSend1", pastSend, fmt.Sprintf("MicToSend on %s: send %s is out of range, this model has sends A-%c", name, pastSend, 'A'+dmp.VirtualSends-1)},
+			{"VRetToOut" + pastReturn, "1", fmt.Sprintf("VRetToOut on %s: return %s is out of range, this model has returns A-%c", name, pastReturn, 'A'+dmp.VirtualReturns-1)},
+			{"VRetToSendA", "AB", fmt.Sprintf("VRetToSend on %s: send A-%c expected, got AB", name, 'A'+dmp.VirtualSends-1)},
+			{"ATToOut1", "1", "calculateDmpMixPointNumber - unknown input type: ATToOut1"},
+		}
+		if dmp.EXPInputs == 0 {
+			tests = append(tests,
+				mixPointCase{"EXPInToOut1", "1", fmt.Sprintf("EXPInToOut on %s: this model has no EXP inputs", name)},
+				mixPointCase{"MicToEXPOut1", "1", fmt.Sprintf("MicToEXPOut on %s: this model has no EXP outputs", name)},
+			)
+		} else {
+			tests = append(tests,
+				mixPointCase{"EXPInToOut17", "1", fmt.Sprintf("EXPInToOut on %s: EXP input 17 is out of range, this model has EXP inputs 1-16", name)},
+			)
+		}
+		for _, tt := range tests {
+			got, err := calculateDmpMixPointNumber(name, tt.input, tt.output)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("%s: %s/%s = %s, %v, want %s", name, tt.input, tt.output, got, err, tt.want)
+			}
+		}
+	}
+}
+
+func TestDmpChannelNumber(t *testing.T) {
+	tests := []struct {
+		value string
+		count int
+		want  int
+		err   string
+	}{
+		{"1", 12, 0, ""},
+		{"12", 12, 11, ""},
+		{"13", 12, 0, "input 13 is out of range, this model has inputs 1-12"},
+		{"0", 12, 0, "input 0 is out of range, this model has inputs 1-12"},
+		{"x", 12, 0, "input x is not a valid number"},
+		{"1", 0, 0, "this model has no inputs"},
+	}
+	for _, tt := range tests {
+		got, err := dmpChannelNumber("input", tt.value, tt.count)
+		if tt.err == "" && (err != nil || got != tt.want) || tt.err != "" && (err == nil || err.Error() != tt.err) {
+			t.Errorf("dmpChannelNumber(%s, %d) = %d, %v, want %d %s", tt.value, tt.count, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestDmpChannelLetter(t *testing.T) {
+	tests := []struct {
+		value string
+		count int
+		want  int
+		err   string
+	}{
+		{"A", 8, 0, ""},
+		{"H", 8, 7, ""},
+		{"P", 16, 15, ""},
+		{"I", 8, 0, "send I is out of range, this model has sends A-H"},
+		{"a", 8, 0, "send a is out of range, this model has sends A-H"},
+		{"AB", 8, 0, "send A-H expected, got AB"},
+		{"", 8, 0, "send A-H expected, got "},
+		{"A", 0, 0, "this model has no sends"}, // not "A-@"
+	}
+	for _, tt := range tests {
+		got, err := dmpChannelLetter("send", tt.value, tt.count)
+		if tt.err == "" && (err != nil || got != tt.want) || tt.err != "" && (err == nil || err.Error() != tt.err) {
+			t.Errorf("dmpChannelLetter(%q, %d) = %d, %v, want %d %s", tt.value, tt.count, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+// Every mix point of every table on every model encodes to an object ID that decodes back to it,
+// and every other object ID in the tables' range is refused
+func TestDecodeDmpMixPointRoundTrip(t *testing.T) {
+	numbers := func(count int) []string {
+		labels := make([]string, count)
+		for i := range labels {
+			labels[i] = strconv.Itoa(i + 1)
+		}
+		return labels
+	}
+	letters := func(count int) []string {
+		labels := make([]string, count)
+		for i := range labels {
+			labels[i] = string(rune('A' + i))
+		}
+		return labels
+	}
+
+	for _, name := range dmpModelNames() {
+		dmp := dmpModels[name]
+		rows := map[string][]string{"Mic": numbers(dmp.MicInputs + dmp.ATInputs), "VRet": letters(dmp.VirtualReturns), "EXPIn": numbers(dmp.EXPInputs)}
+		columns := map[string][]string{"Out": numbers(dmp.Outputs + dmp.ATOutputs), "Send": letters(dmp.VirtualSends), "EXPOut": numbers(dmp.EXPOutputs)}
+
+		mixPoints := make(map[string]bool)
+		for table := range dmpBaseAddr {
+			for source, inputs := range rows {
+				for destination, outputs := range columns {
+					if string(table) != source+"To"+destination {
+						continue
+					}
+					for _, input := range inputs {
+						for _, output := range outputs {
+							oid, err := calculateDmpMixPointNumber(name, string(table)+input, output)
+							if err != nil {
+								t.Fatalf("%s: %s%s/%s: %v", name, table, input, output, err)
+							}
+							mixPoint, err := decodeDmpMixPoint(name, oid)
+							if err != nil || mixPoint.Table != table || mixPoint.Input != string(table)+input || mixPoint.Output != output {
+								t.Errorf("%s: %s%s/%s is %s, decodes to %+v, %v", name, table, input, output, oid, mixPoint, err)
+								continue
+							}
+							if again, _ := calculateDmpMixPointNumber(name, mixPoint.Input, mixPoint.Output); again != oid {
+								t.Errorf("%s: %s decodes to %s/%s, which encodes to %s", name, oid, mixPoint.Input, mixPoint.Output, again)
+							}
+							mixPoints[oid] = true
+						}
+					}
+				}
+			}
+		}
+
+		for num := 20000; num < 26000; num++ {
+			oid := strconv.Itoa(num)
+			if _, err := decodeDmpMixPoint(name, oid); (err == nil) != mixPoints[oid] {
+				t.Errorf("%s: %s decodes with error %v, but is a mix point on this model: %v", name, oid, err, mixPoints[oid])
+			}
+		}
+	}
+}
+
+func TestDecodeDmpMixPoint(t *testing.T) {
+	tests := []struct {
+		model   string
+		oid     string
+		want    dmpMixPoint
+		wantErr string
+	}{
+		{"DMP 128 Plus C V AT", "21205", dmpMixPoint{"21205", MicToOut, "MicToOut13", "6", "AT input 1 to output 6"}, ""},
+		{"DMP 128 Plus C V AT", "20015", dmpMixPoint{"20015", MicToOut, "MicToOut1", "16", "mic/line input 1 to AT output 8"}, ""},
+		{"DMP 128 Plus C V", "22117", dmpMixPoint{"22117", VRetToSend, "VRetToSendB", "B", "virtual return B to virtual send B"}, ""},
+		{"DMP 128 Plus C V", "25131", dmpMixPoint{"25131", EXPInToSend, "EXPInToSend16", "P", "EXP input 16 to virtual send P"}, ""},
+		{"DMP 64 Plus C V", "20532", dmpMixPoint{"20532", MicToEXPOut, "MicToEXPOut6", "1", "mic/line input 6 to EXP output 1"}, ""},
+
+		// EXP inputs don't mix to EXP outputs
+		{"DMP 128 Plus C V", "23632", dmpMixPoint{}, "23632 would be EXPInToEXPOut, which is not a mix point table"},
+		{"DMP 128 Plus C V", "25147", dmpMixPoint{}, "25147 would be EXPInToEXPOut, which is not a mix point table"},
+		{"DMP 128 Plus C V", "25148", dmpMixPoint{}, "25148 is outside the mix point tables"},
+		// channels the model doesn't have
+		{"DMP 128 Plus C V", "21205", dmpMixPoint{}, "21205 is MicToOut13/6, but MicToOut on DMP 128 Plus: input 13 is out of range, this model has inputs 1-12"},
+		{"DMP 64 C V", "20032", dmpMixPoint{}, "20032 is MicToEXPOut1/1, but MicToEXPOut on DMP 64: this model has no EXP outputs"},
+		{"DMP 64 C V", "22024", dmpMixPoint{}, "22024 is VRetToSendA/I, but VRetToSend on DMP 64: send I is out of range, this model has sends A-H"},
+		// not mix points
+		{"DMP 128 Plus C V", "40000", dmpMixPoint{}, "40000 is outside the mix point tables"},
+		{"DMP 128 Plus C V", "2120", dmpMixPoint{}, "2120 is not a mix point object ID"},
+		{"DMP 128 Plus C V", "2120x", dmpMixPoint{}, "2120x is not a mix point object ID"},
+		{"IN1606", "21205", dmpMixPoint{}, "mix points are not known for model 'IN1606'"},
+	}
+	for _, tt := range tests {
+		got, err := decodeDmpMixPoint(tt.model, tt.oid)
+		switch {
+		case tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr):
+			t.Errorf("%s %s: got %+v, %v, want %s", tt.model, tt.oid, got, err, tt.wantErr)
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("%s %s: %v", tt.model, tt.oid, err)
+		case tt.wantErr == "" && *got != tt.want:
+			t.Errorf("%s %s: got %+v, want %+v", tt.model, tt.oid, *got, tt.want)
+		}
+	}
+}
+
+// A mix point the model doesn't have is refused, without asking the device
+func TestDmpMixPointEndpointRejects(t *testing.T) {
+	socketKey := "telnet|admin:extron@dmp128plus_mixpoint_rejects"
+	if err := startTranscriptReplay(socketKey, filepath.Join("testdata", "transcripts", "telnet_dmp128plus_23.jsonl"), false); err != nil {
+		t.Fatal(err)
+	}
+	defer stopTranscriptReplay(socketKey)
+
+	for oid, want := range map[string]string{"21205": "input 13 is out of range", "23632": "not a mix point table", "60002": "outside the mix point tables"} {
+		if got, err := doDeviceSpecificGet(socketKey, "dmpmixpoint", oid, ""); err == nil || !strings.Contains(got, want) {
+			t.Errorf("%s: got %s, %v, want %s", oid, got, err, want)
+		}
+	}
+	replay, _ := transports.Load(socketKey)
+	if missed := replay.(*replayTransport).missed; len(missed) > 0 {
+		t.Errorf("sent %q", missed)
+	}
+}
+
+func TestDmpGainBlockNumber(t *testing.T) {
+	blocks := []*dmpGainBlock{&dmpInputGain, &dmpPremixerGain, &dmpVirtualReturnGain, &dmpOutputVolume}
+	for _, name := range dmpModelNames() {
+		dmp := dmpModels[name]
+		for _, block := range blocks {
+			count := block.Channels(&dmp)
+			label := func(offset int) string {
+				if block.Letters {
+					return string(rune('A' + offset))
+				}
+				return strconv.Itoa(offset + 1)
+			}
+
+			for _, offset := range []int{0, count - 1} {
+				oid, err := calculateDmpGainBlockNumber(name, block, label(offset))
+				if want := strconv.Itoa(block.BaseOID + offset); err != nil || oid != want {
+					t.Errorf("%s %s channel %s = %s, %v, want %s", name, block.Name, label(offset), oid, err, want)
+				}
+				decoded, channel, ok := decodeDmpGainBlock(name, strconv.Itoa(block.BaseOID+offset))
+				if !ok || decoded != block || channel != label(offset) {
+					t.Errorf("%s %d decodes to %v %s %v, want %s channel %s", name, block.BaseOID+offset, decoded, channel, ok, block.Name, label(offset))
+				}
+			}
+
+			if oid, err := calculateDmpGainBlockNumber(name, block, label(count)); err == nil {
+				t.Errorf("%s %s channel %s = %s, the model has %d", name, block.Name, label(count), oid, count)
+			}
+			if decoded, channel, ok := decodeDmpGainBlock(name, strconv.Itoa(block.BaseOID+count)); ok {
+				t.Errorf("%s %d decodes to %s channel %s, past the model's %d channels", name, block.BaseOID+count, decoded.Name, channel, count)
+			}
+		}
+	}
+
+	// AT inputs and outputs come after the mic/line inputs and analog outputs
+	tests := []struct {
+		model   string
+		block   *dmpGainBlock
+		channel string
+		want    string
+	}{
+		{"DMP 128 Plus C V AT", &dmpInputGain, "13", "40012"},
+		{"DMP 128 Plus C V AT", &dmpPremixerGain, "20", "40119"},
+		{"DMP 128 Plus C V AT", &dmpOutputVolume, "16", "60015"},
+		{"DMP 64 C V", &dmpVirtualReturnGain, "H", "50507"},
+	}
+	for _, tt := range tests {
+		if got, err := calculateDmpGainBlockNumber(tt.model, tt.block, tt.channel); err != nil || got != tt.want {
+			t.Errorf("%s %s channel %s = %s, %v, want %s", tt.model, tt.block.Name, tt.channel, got, err, tt.want)
+		}
+	}
+	if _, _, ok := decodeDmpGainBlock("DMP 128 Plus C V", "21205"); ok {
+		t.Error("a mix point is not a gain block")
+	}
+}
+
+// Channels are checked against the block before anything is sent, and percents outside 0-100 are clamped to the block's own range
+func TestSetDmpGainRejects(t *testing.T) {
+	socketKey := "telnet|admin:extron@dmp128plus_gain_rejects"
+	if err := startTranscriptReplay(socketKey, filepath.Join("testdata", "transcripts", "telnet_dmp128plus_gain_23.jsonl"), false); err != nil {
+		t.Fatal(err)
+	}
+	defer stopTranscriptReplay(socketKey)
+
+	tests := []struct {
+		endpoint string
+		channel  string
+		level    string
+		want     string
+	}{
+		{"dmpinputgain", "13", "50", "input gain on DMP 128 Plus: channel 13 is out of range, this model has channels 1-12"},
+		{"dmpoutputvolume", "9", "50", "output attenuation on DMP 128 Plus: channel 9 is out of range, this model has channels 1-8"},
+		{"dmpvirtualreturngain", "Q", "50", "virtual return gain on DMP 128 Plus: channel Q is out of range, this model has channels A-P"},
+		{"dmpinputgain", "1", "", "level (0-100) required"},
+	}
+	for _, tt := range tests {
+		if got, err := setDmpGainDo(socketKey, tt.endpoint, tt.channel, tt.level, ""); err == nil || !strings.Contains(got, tt.want) {
+			t.Errorf("%s %s %s: got %s, %v, want %s", tt.endpoint, tt.channel, tt.level, got, err, tt.want)
+		}
+	}
+
+	// the capture only has +80 dB for input 1 and -100 dB for output 8, anything else sent fails
+	if got, err := setDmpGainDo(socketKey, "dmpinputgain", "1", "101", ""); err != nil || got != "ok" {
+		t.Errorf("input gain 101%%: got %s, %v, want +80 dB", got, err)
+	}
+	if got, err := setDmpGainDo(socketKey, "dmpoutputvolume", "8", "-1", ""); err != nil || got != "ok" {
+		t.Errorf("output volume -1%%: got %s, %v, want -100 dB", got, err)
+	}
+	replay, _ := transports.Load(socketKey)
+	if missed := replay.(*replayTransport).missed; len(missed) > 0 {
+		t.Errorf("sent %q", missed)
+	}
+}
@@ -1,9 +1,11 @@
 package main
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
-	"math"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -14,11 +16,28 @@ import (
 
 // Package-level variables
 var deviceTypes = make(map[string]string)              // socketKey -> deviceType
+var deviceTypesMutex sync.Mutex                        // deviceTypes is written once the type is known and read everywhere, use knownDeviceType and setDeviceType
 var deviceModels = make(map[string]string)             // socketKey -> modeldescription
+var deviceModelsMutex sync.Mutex                       // deviceModels is written by the login and read everywhere, use deviceModel and setDeviceModel
 var keepAlivePollRoutines = make(map[string]chan bool) // socketKey -> stop channel
 var keepAlivePollRoutinesMutex sync.Mutex
 var txRxMutexes sync.Map // socketKey -> *sync.Mutex
 
+// What a DMP group master is: a mute group, or a gain group and its soft limits in tenths of dB
+type dmpGroup struct {
+	Mute  bool
+	Upper int
+	Lower int
+}
+
+var dmpGroups = make(map[string]map[string]dmpGroup) // socketKey -> group number -> last read
+var dmpGroupsMutex sync.Mutex
+
+// Tables that take a command per row to read, kept until the device may have changed them (see forgetDeviceTables)
+var edidTables = make(map[string][]*edidInfo) // socketKey -> every EDID slot
+var presetNames = make(map[string][]string)   // socketKey -> global preset names in preset order
+var deviceTablesMutex sync.Mutex
+
 ///////////////////////////////////////////////////////////////////////////////
 // Main functions //
 ///////////////////////////////////////////////////////////////////////////////
@@ -28,7 +47,7 @@ var txRxMutexes sync.Map // socketKey -> *sync.Mutex
 // This endpoint has limited functionality within the Extron ecosystem.
 // It is only used for group volume control of scalers.
 // For dedicated DSP units and matrix switchers, you'll want to use "matrixvolume" instead.
-// Note: IN1804 breaks all the patterns and is not supported yet.
+// The IN1804 has one program volume in whole dB on a dBFS scale, see transformVolumeIN1804.
 func getVolumeDo(socketKey string, endpoint string, name string, _ string, _ string) (string, error) {
 	function := "getVolumeDo"
 
@@ -39,22 +58,18 @@ func getVolumeDo(socketKey string, endpoint string, name string, _ string, _ str
 		return modelErr, errors.New(modelErr)
 	}
 
-	var oid string // mix point number
-	ok := false
-
 	// Check if model is supported
-	switch {
-	case strings.Contains(model, "160") && strings.Contains(model, "IN"): // IN 160x series
-		oid, ok = in160xGroupAudioVolumeMap[name] // Only group voloumes on 160x series (firmware bug)
-	default:
-		notImpMsg := function + "Model: " + model + " is not implemented or does not support 'volume'"
+	profile := profileForModel(socketKey, model)
+	if profile == nil || len(profile.VolumeGroups) == 0 {
+		notImpMsg := function + " - model " + model + " is not implemented or does not support 'volume'"
 		framework.AddToErrors(socketKey, notImpMsg)
 		return notImpMsg, errors.New(notImpMsg)
 	}
+	oid, ok := profile.VolumeGroups[name] // mix point number
 
 	// Check if we have the channel name+oid mapping for the model
 	if !ok {
-		errMsg := function + "Can't find OID for: " + name + " on model: " + model
+		errMsg := function + " - can't find OID for: " + name + " on model: " + model
 		framework.AddToErrors(socketKey, errMsg)
 		return errMsg, errors.New(errMsg)
 	}
@@ -68,7 +83,12 @@ func getVolumeDo(socketKey string, endpoint string, name string, _ string, _ str
 	}
 
 	// Convert return in tenths of DB to percent
-	percent, err := newUnTransformVolume(resp)
+	var percent string
+	if profile.CommandVariant == "IN1804" {
+		percent, err = unTransformVolumeIN1804(resp)
+	} else {
+		percent, err = newUnTransformVolume(volumeCurveFor(socketKey, "volume"), resp)
+	}
 	if err != nil {
 		errMsg := function + " - error converting device volume to percent: " + err.Error()
 		framework.AddToErrors(socketKey, errMsg)
@@ -80,6 +100,20 @@ func getVolumeDo(socketKey string, endpoint string, name string, _ string, _ str
 func getVideoRouteDo(socketKey string, endpoint string, output string, _ string, _ string) (string, error) {
 	function := "getVideoRouteDo"
 
+	// translate or throw away the 'output' arg for non-matrix devices before formatting
+	deviceType, err := findDeviceType(socketKey)
+	if err != nil {
+		errMsg := fmt.Sprintf(function+" - error finding device type: %s", err.Error())
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+	output, err = tieOutput(socketKey, deviceType, output)
+	if err != nil {
+		errMsg := function + " - " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
 	resp, err := deviceTypeDependantCommand(socketKey, "videoroute", "GET", output, "", "")
 	if err != nil {
 		errMsg := function + "- error getting video route: " + err.Error()
@@ -96,6 +130,8 @@ func getVideoRouteDo(socketKey string, endpoint string, output string, _ string,
 	return resp, nil
 }
 
+// Matrix switchers and scalers: when audio is broken away from video (tied to a different input),
+// the answer is JSON with both inputs instead of one input, ex: {"video":"3","audio":"5","breakaway":true}
 func getAudioAndVideoRouteDo(socketKey string, endpoint string, output string, _ string, _ string) (string, error) {
 	function := "getAudioAndVideoRouteDo"
 
@@ -105,11 +141,40 @@ func getAudioAndVideoRouteDo(socketKey string, endpoint string, output string, _
 		framework.AddToErrors(socketKey, errMsg)
 		return errMsg, errors.New(errMsg)
 	}
-	if deviceType != "Matrix Switcher" {
-		output = ""
+
+	if deviceType == "Matrix Switcher" || deviceType == "Scaler" {
+		video, err := getVideoRouteDo(socketKey, "videoroute", output, "", "")
+		if err != nil {
+			errMsg := function + " - error getting video route: " + err.Error()
+			framework.AddToErrors(socketKey, errMsg)
+			return errMsg, errors.New(errMsg)
+		}
+		audio, err := getAudioRouteDo(socketKey, "audioroute", output, "", "")
+		if err != nil {
+			errMsg := function + " - error getting audio route: " + err.Error()
+			framework.AddToErrors(socketKey, errMsg)
+			return errMsg, errors.New(errMsg)
+		}
+		video = trimZeros(strings.ReplaceAll(video, `"`, ``))
+		audio = trimZeros(strings.ReplaceAll(audio, `"`, ``))
+
+		if video == audio {
+			return `"` + video + `"`, nil
+		}
+
+		if output == "" || deviceType != "Matrix Switcher" {
+			output = "1"
+		}
+		framework.Log(fmt.Sprintf("%s - %s - output %s is broken away: video %s, audio %s", function, socketKey, output, video, audio))
+		recordRoute(socketKey, "audioandvideoroute", output, video, "video")
+		recordRoute(socketKey, "audioandvideoroute", output, audio, "audio")
+
+		data, _ := json.Marshal(map[string]interface{}{"video": video, "audio": audio, "breakaway": true})
+		return string(data), nil
 	}
 
-	resp, err := deviceTypeDependantCommand(socketKey, "audioandvideoroute", "GET", output, "", "")
+	// Switchers don't break away, "!" is the whole answer
+	resp, err := deviceTypeDependantCommand(socketKey, "audioandvideoroute", "GET", "", "", "")
 	if err != nil {
 		errMsg := function + "- error getting AV route: " + err.Error()
 		framework.AddToErrors(socketKey, errMsg)
@@ -125,6 +190,38 @@ func getAudioAndVideoRouteDo(socketKey string, endpoint string, output string, _
 	return resp, nil
 }
 
+func getAudioRouteDo(socketKey string, endpoint string, output string, _ string, _ string) (string, error) {
+	function := "getAudioRouteDo"
+
+	deviceType, err := findDeviceType(socketKey)
+	if err != nil {
+		errMsg := fmt.Sprintf(function+" - error finding device type: %s", err.Error())
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+	output, err = tieOutput(socketKey, deviceType, output)
+	if err != nil {
+		errMsg := function + " - " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	resp, err := deviceTypeDependantCommand(socketKey, "audioroute", "GET", output, "", "")
+	if err != nil {
+		errMsg := function + "- error getting audio route: " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	// some non-matrix devices have leading zeroes in the response, remove them.
+	// remember the response is wrapped in quotes
+	if len(resp) == 4 && resp[1] == '0' {
+		resp = `"` + resp[2:]
+	}
+	framework.Log(function + " - " + socketKey + "- Response: " + resp)
+	return resp, nil
+}
+
 func getInputStatusDo(socketKey string, endpoint string, input string, _ string, _ string) (string, error) {
 	function := "getInputStatusDo"
 
@@ -173,20 +270,11 @@ func getInputStatusDo(socketKey string, endpoint string, input string, _ string,
 	// Remove any matrix formatting (this is also valid path for switchers)
 	resp = strings.ReplaceAll(resp, `*`, ``)
 
-	deviceModel := deviceModels[socketKey]
+	deviceModel := deviceModel(socketKey)
 
-	inMap := make(map[string]int)
+	inMap, _ := ioMaps(socketKey, profileForModel(socketKey, deviceModel))
 
-	switch {
-	case strings.Contains(deviceModel, "DTPCP108"):
-		inMap = crossPoint108Map.inputs
-	case strings.Contains(deviceModel, "DTPCP86"):
-		inMap = crossPoint86Map.inputs
-	case strings.Contains(deviceModel, "DTPCP84"):
-		inMap = crossPoint84Map.inputs
-	case strings.Contains(deviceModel, "IN18"):
-		inMap = in180xMap.inputs
-	default:
+	if len(inMap) == 0 {
 		// If we got here, hopefully it's a device with a straight 1:1 mapping (ex: no '3A', just '3')
 		framework.Log(function + " - no special I/O name handling applied for device: " + deviceModel + "at" + socketKey)
 		inputNum, err := strconv.Atoi(input)
@@ -215,7 +303,7 @@ func getInputStatusDo(socketKey string, endpoint string, input string, _ string,
 	// Check if input is in the map
 	var index int
 	var ok bool
-	if index, ok = inMap[input]; ok {
+	if index, ok = inMap[input]; ok && index < len(resp) {
 		result := string(resp[index])
 		//framework.Log(fmt.Sprintf("%s - %s - input: %s, is at index: %d of %s", function, socketKey, input, index, resp))
 		//framework.Log(fmt.Sprintf("%s - %s - result: %s", function, socketKey, result))
@@ -229,7 +317,19 @@ func getInputStatusDo(socketKey string, endpoint string, input string, _ string,
 }
 
 func getVideoMuteDo(socketKey string, endpoint string, output string, _ string, _ string) (string, error) {
-	function := "getVideoMuteDo"
+	state, err := readVideoMute(socketKey, output)
+	if err != nil {
+		return state, err
+	}
+	if state == "0" {
+		return `"false"`, nil
+	}
+	return `"true"`, nil // muted, with or without sync
+}
+
+// The video mute of an output as the device has it: "0" (not muted), "1" (muted with sync) or "2" (sync mute)
+func readVideoMute(socketKey string, output string) (string, error) {
+	function := "readVideoMute"
 
 	model, err := findModelName(socketKey)
 	if err != nil {
@@ -237,9 +337,9 @@ func getVideoMuteDo(socketKey string, endpoint string, output string, _ string,
 		framework.AddToErrors(socketKey, modelErr)
 		return modelErr, errors.New(modelErr)
 	}
-	switch {
-	case strings.Contains(model, "160") && strings.Contains(model, "IN"): // IN 160x series special handling
-		outputNum, ok := in160xVideoMuteMap[output]
+	profile := profileForModel(socketKey, model)
+	if profile != nil && len(profile.VideoMutes) > 0 { // ex: IN 160x mutes "1A" as "1"
+		outputNum, ok := profile.VideoMutes[output]
 		output = outputNum
 		if !ok {
 			errMsg := function + " - can't find video mute mapping for provided output on model: " + model
@@ -269,12 +369,8 @@ func getVideoMuteDo(socketKey string, endpoint string, output string, _ string,
 	// Simple device, only one character reply
 	// Could be IN 16xx or a switcher
 	if len(resp) == 1 {
-		if resp == "0" {
-			return `"false"`, nil
-		} else if resp == "1" {
-			return `"true"`, nil
-		} else if resp == "2" { // sync mute
-			return `"true"`, nil
+		if resp == "0" || resp == "1" || resp == "2" { // 2 is sync mute
+			return resp, nil
 		} else {
 			errMsg := function + " - invalid one character response for video mute: " + resp
 			disconnectAfterBadData(socketKey, function)
@@ -283,27 +379,22 @@ func getVideoMuteDo(socketKey string, endpoint string, output string, _ string,
 		}
 	}
 
-	// Query is for loop out (built for IN 1808)
-	// Future maintainers: any new device with LoopOut needs to be handled here
+	// Query is for loop out (ex: IN 1808), the profile says where it is in the response
 	if output == "LoopOut" {
 		framework.Log(fmt.Sprintf("%s - %s - LoopOut response: %s", function, socketKey, resp))
-		if len(resp) == 3 {
-			result := string(resp[2])
+		if profile != nil && profile.LoopOut != nil && *profile.LoopOut < len(resp) {
+			result := string(resp[*profile.LoopOut])
 			switch result {
-			case "0":
-				return `"false"`, nil
-			case "1":
-				return `"true"`, nil
-			case "2":
-				return `"true"`, nil
+			case "0", "1", "2":
+				return result, nil
 			default:
 				errMsg := function + " - invalid loopout response: " + resp
 				disconnectAfterBadData(socketKey, function)
 				framework.AddToErrors(socketKey, errMsg)
 				return errMsg, errors.New(errMsg)
 			}
-		} else { // 1808 is only known LoopOut device, this was called on the wrong device, or we need update handling
-			framework.AddToErrors(socketKey, function+" - LoopOut called, but device is not IN1808 "+resp)
+		} else { // called on the wrong device, or the profile needs a loopOut
+			framework.AddToErrors(socketKey, function+" - LoopOut called, but the profile for "+model+" has no LoopOut for response: "+resp)
 		}
 	}
 
@@ -317,22 +408,20 @@ func getVideoMuteDo(socketKey string, endpoint string, output string, _ string,
 	// Distribution Amplifier
 	if deviceType == "Distribution Amplifier" {
 
-		// assuming Extron will not make an odd number of outputs without a loop through
-		hasLoopThrough := false
-		if !isEven(len(resp)) {
-			hasLoopThrough = true
+		// The profile says where the loop through is.  Without one,
+		// assume Extron will not make an odd number of outputs without a loop through, and that it comes first.
+		loopThrough := 0
+		hasLoopThrough := !isEven(len(resp))
+		if profile != nil && profile.LoopThrough != nil {
+			loopThrough = *profile.LoopThrough
+			hasLoopThrough = loopThrough < len(resp)
 		}
 
 		if output == "LoopThrough" && hasLoopThrough {
-			// LoopThrough is the first output in the string
-			result := string(resp[0])
+			result := string(resp[loopThrough])
 			switch result {
-			case "0":
-				return `"false"`, nil
-			case "1":
-				return `"true"`, nil
-			case "2":
-				return `"true"`, nil
+			case "0", "1", "2":
+				return result, nil
 			default:
 				errMsg := function + " - invalid loopthrough response: " + resp
 				disconnectAfterBadData(socketKey, function)
@@ -346,43 +435,28 @@ func getVideoMuteDo(socketKey string, endpoint string, output string, _ string,
 			return errMsg, errors.New(errMsg)
 		}
 
-		// Drop the first character (loop through)
-		if len(resp) > 0 {
-			resp = resp[1:]
+		// Drop the loop through
+		if hasLoopThrough {
+			resp = resp[:loopThrough] + resp[loopThrough+1:]
 		}
 		outputInt, err := strconv.Atoi(output)
-		if err != nil {
+		if err != nil || outputInt < 1 || outputInt > len(resp) {
 			errMsg := function + " - invalid output number: " + output
 			framework.AddToErrors(socketKey, errMsg)
 			return errMsg, errors.New(errMsg)
 		}
 		result := string(resp[outputInt-1]) //-1: convert for 0-based index
 		switch result {
-		case "0":
-			return `"false"`, nil
-		case "1":
-			return `"true"`, nil
-		case "2":
-			return `"true"`, nil
+		case "0", "1", "2":
+			return result, nil
 		}
 	}
 
 	// Matrix switchers and IN 180x
-	// We need to map the output name to the index in the response string
-	outMap := make(map[string]int)
-	deviceModel := deviceModels[socketKey]
-
-	switch {
-	case strings.Contains(deviceModel, "DTPCP108"):
-		outMap = crossPoint108Map.outputs
-	case strings.Contains(deviceModel, "DTPCP86"):
-		outMap = crossPoint86Map.outputs
-	case strings.Contains(deviceModel, "DTPCP84"):
-		outMap = crossPoint84Map.outputs
-	case strings.Contains(deviceModel, "IN18"):
-		outMap = in180xMap.outputs
-	default:
-		errMsg := function + " - unknown device model: " + deviceModels[socketKey]
+	// We need to map the output name to the index in the response string, from the profile or the discovered size
+	_, outMap := ioMaps(socketKey, profile)
+	if len(outMap) == 0 {
+		errMsg := function + " - unknown device model: " + model + ".  Add a device profile with its outputs"
 		framework.AddToErrors(socketKey, errMsg)
 		return errMsg, errors.New(errMsg)
 	}
@@ -391,7 +465,7 @@ func getVideoMuteDo(socketKey string, endpoint string, output string, _ string,
 	var index int
 	var ok bool
 	var result string
-	if index, ok = outMap[output]; ok {
+	if index, ok = outMap[output]; ok && index < len(resp) {
 		result = string(resp[index])
 	} else {
 		errMsg := function + " - invalid output name: " + output
@@ -405,9 +479,8 @@ func getVideoMuteDo(socketKey string, endpoint string, output string, _ string,
 	//framework.Log(fmt.Sprintf("%s - %s - output: %s, is at index: %d of %s", function, socketKey, output, index, resp))
 	//framework.Log(fmt.Sprintf("%s - %s - result: %s", function, socketKey, result))
 
-	result, err = stringIntToStringBool(socketKey, result)
-	if err != nil {
-		errMsg := function + " - error converting video mute status to boolean: " + err.Error()
+	if result != "0" && result != "1" && result != "2" {
+		errMsg := function + " - invalid video mute status: " + result
 		framework.AddToErrors(socketKey, errMsg)
 		return errMsg, errors.New(errMsg)
 	}
@@ -425,22 +498,18 @@ func getAudioMuteDo(socketKey string, endpoint string, name string, _ string, _
 		return modelErr, errors.New(modelErr)
 	}
 
-	var oid string // mix point number
-	ok := false
-
 	// Check if model is supported
-	switch {
-	case strings.Contains(model, "160") && strings.Contains(model, "IN"): // IN 160x series
-		oid, ok = in160xGroupAudioMuteMap[name]
-	default:
-		notImpMsg := function + "Model: " + model + " is not implemented or does not support 'mute'"
+	profile := profileForModel(socketKey, model)
+	if profile == nil || len(profile.MuteGroups) == 0 {
+		notImpMsg := function + " - model " + model + " is not implemented or does not support 'audiomute'"
 		framework.AddToErrors(socketKey, notImpMsg)
 		return notImpMsg, errors.New(notImpMsg)
 	}
+	oid, ok := profile.MuteGroups[name] // mix point number
 
 	// Check if we have the channel name+oid mapping for the model
 	if !ok {
-		errMsg := function + "Can't find mute group OID (X48) for: " + name + " on model: " + model
+		errMsg := function + " - can't find mute group OID (X48) for: " + name + " on model: " + model
 		framework.AddToErrors(socketKey, errMsg)
 		return errMsg, errors.New(errMsg)
 	}
@@ -478,7 +547,7 @@ func getAudioMuteDo(socketKey string, endpoint string, name string, _ string, _
 func getMatrixMuteDo(socketKey string, endpoint string, input string, output string, _ string) (string, error) {
 	function := "getMatrixMuteDo"
 
-	mixPointNumber, err := calculateDmpMixPointNumber(input, output)
+	mixPointNumber, err := deviceMixPointNumber(socketKey, input, output)
 	if err != nil {
 		errMsg := function + " - error calculating mix point number: " + err.Error()
 		framework.AddToErrors(socketKey, errMsg)
@@ -487,7 +556,7 @@ func getMatrixMuteDo(socketKey string, endpoint string, input string, output str
 
 	resp, err := deviceTypeDependantCommand(socketKey, "matrixmute", "GET", mixPointNumber, "", "")
 	if err != nil {
-		errMsg := function + "- error getting matrix mute status: " + err.Error()
+		errMsg := function + "- error getting matrix mute status of mix point " + dmpMixPointLabel(deviceModel(socketKey), mixPointNumber) + ": " + err.Error()
 		framework.AddToErrors(socketKey, errMsg)
 		return errMsg, errors.New(errMsg)
 	}
@@ -497,16 +566,53 @@ func getMatrixMuteDo(socketKey string, endpoint string, input string, output str
 	} else if resp == "0" {
 		return `"false"`, nil
 	} else {
-		errMsg := function + " - invalid response for matrix mute: " + resp
+		errMsg := function + " - invalid response for matrix mute of mix point " + dmpMixPointLabel(deviceModel(socketKey), mixPointNumber) + ": " + resp
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+}
+
+// Same as volume, in decibels with one decimal, ex: "-3.5", passed straight through instead of going through a volume curve.
+// The IN1804 only takes whole dB.
+func getVolumeDbDo(socketKey string, endpoint string, name string, _ string, _ string) (string, error) {
+	function := "getVolumeDbDo"
+
+	profile, oid, err := findVolumeGroup(socketKey, name)
+	if err != nil {
+		errMsg := function + " - " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	resp, err := deviceTypeDependantCommand(socketKey, "volume", "GET", oid, "", "")
+	if err != nil {
+		errMsg := function + " - error getting volume " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	// tenths of dB, or "Vol" and whole dB on the IN1804
+	if strings.Contains(resp, "error") {
+		return resp, errors.New(resp) // device returned an error code
+	}
+	resp = strings.TrimPrefix(strings.ReplaceAll(resp, `"`, ``), "Vol")
+	tenths, err := strconv.Atoi(resp)
+	if err != nil {
+		errMsg := function + " - invalid response for volume: " + resp
+		disconnectAfterBadData(socketKey, function)
 		framework.AddToErrors(socketKey, errMsg)
 		return errMsg, errors.New(errMsg)
 	}
+	if profile.CommandVariant == "IN1804" {
+		tenths *= 10
+	}
+	return tenthsToDb(tenths), nil
 }
 
 func getMatrixVolumeDo(socketKey string, endpoint string, input string, output string, _ string) (string, error) {
 	function := "getMatrixVolumeDo"
 
-	mixPointNumber, err := calculateDmpMixPointNumber(input, output)
+	mixPointNumber, err := deviceMixPointNumber(socketKey, input, output)
 	if err != nil {
 		errMsg := function + " - error calculating mix point number: " + err.Error()
 		framework.AddToErrors(socketKey, errMsg)
@@ -515,16 +621,16 @@ func getMatrixVolumeDo(socketKey string, endpoint string, input string, output s
 
 	resp, err := deviceTypeDependantCommand(socketKey, "matrixvolume", "GET", mixPointNumber, "", "")
 	if err != nil {
-		errMsg := function + "- error getting matrix volume status: " + err.Error()
+		errMsg := function + "- error getting matrix volume status of mix point " + dmpMixPointLabel(deviceModel(socketKey), mixPointNumber) + ": " + err.Error()
 		framework.AddToErrors(socketKey, errMsg)
 		return errMsg, errors.New(errMsg)
 	}
 
 	// Valid returns are in 10th of DB's.  Ex: -3.5db is '-35'. Range: -100db to 12db.
 	// Presume the rest of the system wants to get a 0-100 value where 0 is -100db and 100 is 12db
-	// Convert device value (tenths dB) to a 0-100 percentage (logarithmic mapping)
+	// Convert device value (tenths dB) to a 0-100 percentage along the endpoint's volume curve (logarithmic by default)
 	resp = strings.ReplaceAll(resp, `"`, ``)
-	percent, convErr := newUnTransformVolume(resp)
+	percent, convErr := newUnTransformVolume(volumeCurveFor(socketKey, "matrixvolume"), resp)
 	if convErr != nil {
 		errMsg := function + " - error converting device volume to percent: " + convErr.Error()
 		framework.AddToErrors(socketKey, errMsg)
@@ -533,394 +639,2857 @@ func getMatrixVolumeDo(socketKey string, endpoint string, input string, output s
 	return percent, nil
 }
 
-// Set functions //
-
-// Used for group volume control of non-matrix devices.
-// For dedicated DSP units and matrix switchers, you'll want to use "matrixvolume" instead.
-// Note: IN1804 breaks all the patterns and is not supported yet.
-func setVolumeDo(socketKey string, endpoint string, name string, level string, _ string) (string, error) {
-	function := "setVolumeDo"
+// Same as matrixvolume, in decibels with one decimal, ex: "-3.5", passed straight through instead of going through a volume curve
+func getMatrixVolumeDbDo(socketKey string, endpoint string, input string, output string, _ string) (string, error) {
+	function := "getMatrixVolumeDbDo"
 
-	model, err := findModelName(socketKey)
+	mixPointNumber, err := deviceMixPointNumber(socketKey, input, output)
 	if err != nil {
-		modelErr := function + " - can not find model for: " + socketKey
-		framework.AddToErrors(socketKey, modelErr)
-		return modelErr, errors.New(modelErr)
+		errMsg := function + " - error calculating mix point number: " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
 	}
 
-	var oid string // mix point number
-	ok := false
-
-	// Check if model is supported
-	switch {
-	case strings.Contains(model, "160") && strings.Contains(model, "IN"): // IN 160x series
-		oid, ok = in160xGroupAudioVolumeMap[name] // Only group voloumes on 160x series (firmware bug)
-	default:
-		notImpMsg := function + "Model: " + model + " is not implemented or does not support 'volume'"
-		framework.AddToErrors(socketKey, notImpMsg)
-		return notImpMsg, errors.New(notImpMsg)
+	resp, err := deviceTypeDependantCommand(socketKey, "matrixvolume", "GET", mixPointNumber, "", "")
+	if err != nil {
+		errMsg := function + "- error getting matrix volume status of mix point " + dmpMixPointLabel(deviceModel(socketKey), mixPointNumber) + ": " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
 	}
 
-	// Check if we have the channel name+oid mapping for the model
-	if !ok {
-		errMsg := function + "Can't find group OID (X47) for: " + name + " on model: " + model
+	if strings.Contains(resp, "error") {
+		return resp, errors.New(resp) // device returned an error code
+	}
+	resp = strings.ReplaceAll(resp, `"`, ``)
+	tenths, err := strconv.Atoi(resp)
+	if err != nil {
+		errMsg := function + " - invalid response for matrix volume of mix point " + dmpMixPointLabel(deviceModel(socketKey), mixPointNumber) + ": " + resp
+		disconnectAfterBadData(socketKey, function)
 		framework.AddToErrors(socketKey, errMsg)
 		return errMsg, errors.New(errMsg)
 	}
+	return tenthsToDb(tenths), nil
+}
 
-	// Convert percent to device volume (tenths of DB)
-	deviceVolume, err := newTransformVolume(level)
+// The mix point a DMP object ID is, ex: from a DSP Configurator export, a log or a notification.
+// Ex: "21205" on a DMP 128 Plus AT returns {"oid":"21205","table":"MicToOut","input":"MicToOut13","output":"6","name":"AT input 1 to output 6"}
+// Input and output can be passed straight to matrixmute and matrixvolume.  Does not talk to the device once the model is known.
+func getDmpMixPointDo(socketKey string, endpoint string, oid string, _ string, _ string) (string, error) {
+	function := "getDmpMixPointDo"
+
+	model, err := findModelName(socketKey)
 	if err != nil {
-		errMsg := function + " - error converting percent to device volume: " + err.Error()
+		errMsg := function + " - error finding model: " + err.Error()
 		framework.AddToErrors(socketKey, errMsg)
 		return errMsg, errors.New(errMsg)
 	}
 
-	// Try sending the command
-	resp, err := deviceTypeDependantCommand(socketKey, "volume", "SET", oid, deviceVolume, "")
+	mixPoint, err := decodeDmpMixPoint(model, strings.TrimSpace(oid))
 	if err != nil {
-		errMsg := function + " - error setting volume " + err.Error()
+		errMsg := function + " - error decoding mix point: " + err.Error()
 		framework.AddToErrors(socketKey, errMsg)
 		return errMsg, errors.New(errMsg)
 	}
 
-	// Good response is "GrpmD<mixPointNumber>*<volume>"
-	resp = strings.ReplaceAll(resp, `"`, ``)
-	expectedResp := "GrpmD" + oid + "*" + deviceVolume
-	if resp != expectedResp {
-		errMsg := function + " - invalid response for setting volume: " + resp + ", expected: " + expectedResp
-		disconnectAfterBadData(socketKey, function)
+	data, err := json.Marshal(mixPoint)
+	if err != nil {
+		errMsg := function + " - error encoding mix point: " + err.Error()
 		framework.AddToErrors(socketKey, errMsg)
 		return errMsg, errors.New(errMsg)
 	}
-	return "ok", nil
+	return string(data), nil
 }
 
-// Group mutes. Used for non-matrix devices
-func setAudioMuteDo(socketKey string, endpoint string, name string, mute string, _ string) (string, error) {
-	function := "setAudioMuteDo"
-
-	mute = strings.ReplaceAll(mute, "\"", "")
-	mute = strings.ReplaceAll(mute, "'", "")
+// DMP gain blocks outside the mix matrix: dmpinputgain, dmppremixergain, dmpvirtualreturngain and dmpoutputvolume.
+// arg1 is the channel: a number, or a letter for virtual returns.  Returns 0-100 percent over the block's own range (see dmpGainBlocks).
+func getDmpGainDo(socketKey string, endpoint string, channel string, _ string, _ string) (string, error) {
+	function := "getDmpGainDo"
 
-	muteCmd := "0"
-	if mute == "true" {
-		muteCmd = "1"
+	block, exists := dmpGainBlocks[endpoint]
+	if !exists {
+		errMsg := function + " - no DMP gain block for endpoint: " + endpoint
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
 	}
-
-	model, err := findModelName(socketKey)
+	oid, err := deviceGainBlockNumber(socketKey, block, channel)
 	if err != nil {
-		modelErr := function + " - can not find model for: " + socketKey
-		framework.AddToErrors(socketKey, modelErr)
-		return modelErr, errors.New(modelErr)
+		errMsg := function + " - error calculating object ID: " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
 	}
 
-	var oid string // mix point number
-	ok := false
-
-	// Check if model is supported
-	switch {
-	case strings.Contains(model, "160") && strings.Contains(model, "IN"): // IN 160x series
-		oid, ok = in160xGroupAudioMuteMap[name]
-	default:
-		notImpMsg := function + "Model: " + model + " is not implemented or does not support 'mute'"
-		framework.AddToErrors(socketKey, notImpMsg)
-		return notImpMsg, errors.New(notImpMsg)
+	// same command as mix points
+	resp, err := deviceTypeDependantCommand(socketKey, "matrixvolume", "GET", oid, "", "")
+	if err != nil {
+		errMsg := function + " - error getting " + block.Name + " of channel " + channel + ": " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
 	}
 
-	// Check if we have the channel name+oid mapping for the model
-	if !ok {
-		errMsg := function + "Can't find mute group OID (X48) for: " + name + " on model: " + model
+	resp = strings.ReplaceAll(resp, `"`, ``)
+	percent, err := unTransformVolume(volumeCurveFor(socketKey, block.Endpoint), resp, block.MinTenths, block.MaxTenths)
+	if err != nil {
+		errMsg := function + " - error converting device volume to percent: " + err.Error()
 		framework.AddToErrors(socketKey, errMsg)
 		return errMsg, errors.New(errMsg)
 	}
+	return percent, nil
+}
 
-	// Try sending the command
-	resp, err := deviceTypeDependantCommand(socketKey, "audiomute", "SET", oid, muteCmd, "")
+// DMP gain block mutes: dmpinputmute, dmppremixermute, dmpvirtualreturnmute and dmpoutputmute.  arg1 is the channel
+func getDmpMuteDo(socketKey string, endpoint string, channel string, _ string, _ string) (string, error) {
+	function := "getDmpMuteDo"
+
+	block, exists := dmpGainBlocks[endpoint]
+	if !exists {
+		errMsg := function + " - no DMP gain block for endpoint: " + endpoint
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+	oid, err := deviceGainBlockNumber(socketKey, block, channel)
 	if err != nil {
-		errMsg := function + " - error setting volume " + err.Error()
+		errMsg := function + " - error calculating object ID: " + err.Error()
 		framework.AddToErrors(socketKey, errMsg)
 		return errMsg, errors.New(errMsg)
 	}
 
-	// Good response is "GrpmD<oid>*<muteCmd>"
-	resp = strings.ReplaceAll(resp, `"`, ``)
-	expectedResp := "GrpmD" + oid + "*" + muteCmd
-	if resp != expectedResp {
-		errMsg := function + " - invalid response for setting group mute: " + resp + ", expected: " + expectedResp
-		disconnectAfterBadData(socketKey, function)
+	resp, err := deviceTypeDependantCommand(socketKey, "matrixmute", "GET", oid, "", "")
+	if err != nil {
+		errMsg := function + " - error getting " + block.Name + " mute of channel " + channel + ": " + err.Error()
 		framework.AddToErrors(socketKey, errMsg)
 		return errMsg, errors.New(errMsg)
 	}
-	return "ok", nil
+	resp = strings.ReplaceAll(resp, `"`, ``)
+	switch resp {
+	case "1":
+		return `"true"`, nil
+	case "0":
+		return `"false"`, nil
+	}
+	errMsg := function + " - invalid response for " + block.Name + " mute: " + resp
+	framework.AddToErrors(socketKey, errMsg)
+	return errMsg, errors.New(errMsg)
 }
 
-func setVideoRouteDo(socketKey string, endpoint string, output string, input string, _ string) (string, error) {
-	function := "setVideoRouteDo"
+// DMP group masters, arg1: group number, or a name from the profile's volumeGroups.
+// Returns 0-100 percent inside the group's soft limits, so 0 is the lower limit and 100 the upper limit the audio designer set.
+func getGroupVolumeDo(socketKey string, endpoint string, group string, _ string, _ string) (string, error) {
+	function := "getGroupVolumeDo"
 
-	// Yes, calling this here results in findDeviceType being called twice in a flow,
-	// But we need to throw away the 'output' arg for devices with only one output before formatting
-	deviceType, err := findDeviceType(socketKey)
+	number, err := findDmpGroup(socketKey, group, false)
 	if err != nil {
-		errMsg := fmt.Sprintf(function+" - error finding device type: %s", err.Error())
+		errMsg := function + " - " + err.Error()
 		framework.AddToErrors(socketKey, errMsg)
 		return errMsg, errors.New(errMsg)
 	}
-	if deviceType != "Matrix Switcher" {
-		output = ""
+	limits, err := readGroupSoftLimits(socketKey, number)
+	if err != nil {
+		errMsg := function + " - error reading soft limits of group " + number + ": " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
 	}
 
-	input = strings.ReplaceAll(input, "\"", "")
-	input = strings.ReplaceAll(input, "'", "")
+	value, err := readGroupValue(socketKey, number)
+	if err != nil {
+		errMsg := function + " - error getting group " + number + " volume: " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+	percent, err := unTransformVolume(volumeCurveFor(socketKey, "groupvolume"), strconv.Itoa(value), limits.Lower, limits.Upper)
+	if err != nil {
+		errMsg := function + " - error converting device volume to percent: " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+	return percent, nil
+}
 
-	resp, err := deviceTypeDependantCommand(socketKey, "videoroute", "SET", input, output, "")
+// DMP group master mutes, arg1: group number, or a name from the profile's muteGroups
+func getGroupMuteDo(socketKey string, endpoint string, group string, _ string, _ string) (string, error) {
+	function := "getGroupMuteDo"
+
+	number, err := findDmpGroup(socketKey, group, true)
 	if err != nil {
-		errMsg := function + "- error setting video route: " + err.Error()
+		errMsg := function + " - " + err.Error()
 		framework.AddToErrors(socketKey, errMsg)
 		return errMsg, errors.New(errMsg)
 	}
-	// Good response example is "Out4 In6 Vid" for a matrix switcher "In6 RGB" for scaler
-	// Any errors will have been formatted in formatDeviceErrMessage
 
-	switch {
-	case strings.Contains(resp, "error"):
-		return resp, errors.New(resp) // device returned an error code
-	case strings.Contains(resp, "In") && strings.Contains(resp, input):
-		return "ok", nil
-	default:
-		disconnectAfterBadData(socketKey, function)
-		return "unknown response: " + resp, nil
+	value, err := readGroupValue(socketKey, number)
+	if err != nil {
+		errMsg := function + " - error getting group " + number + " mute: " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
 	}
+	switch value {
+	case 1:
+		rememberDmpGroup(socketKey, number, dmpGroup{Mute: true})
+		return `"true"`, nil
+	case 0:
+		rememberDmpGroup(socketKey, number, dmpGroup{Mute: true})
+		return `"false"`, nil
+	}
+	errMsg := fmt.Sprintf("%s - group %s is not a mute group, its value is %d", function, number, value)
+	framework.AddToErrors(socketKey, errMsg)
+	return errMsg, errors.New(errMsg)
 }
 
-func setAudioAndVideoRoute(socketKey string, endpoint string, output string, input string, _ string) (string, error) {
-	function := "setAudioAndVideoRoute"
+// Power Controllers (IPL T PCS4, PC1) report per outlet.  Use "all" (or leave the outlet off) for every outlet,
+// which is only "true" when every outlet is on.
+// Switchers and scalers have no display power control, they get an error pointing at powersave.
+func getPowerDo(socketKey string, endpoint string, outlet string, _ string, _ string) (string, error) {
+	function := "getPowerDo"
 
-	// Yes, calling this here results in findDeviceType being called twice in a flow,
-	// But we need to throw away the 'output' arg for devices with only one output before formatting
 	deviceType, err := findDeviceType(socketKey)
 	if err != nil {
 		errMsg := fmt.Sprintf(function+" - error finding device type: %s", err.Error())
 		framework.AddToErrors(socketKey, errMsg)
 		return errMsg, errors.New(errMsg)
 	}
-	if deviceType != "Matrix Switcher" {
-		output = ""
+
+	if deviceType != "Power Controller" {
+		errMsg := function + " - " + noOutletPower(socketKey, deviceType)
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
 	}
 
-	input = strings.ReplaceAll(input, "\"", "")
-	input = strings.ReplaceAll(input, "'", "")
+	outlets, err := findPowerOutlets(socketKey, outlet)
+	if err != nil {
+		errMsg := function + " - " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	allOn := true
+	for _, o := range outlets {
+		resp, err := deviceTypeDependantCommand(socketKey, "power", "GET", o, "", "")
+		if err != nil {
+			errMsg := function + " - error getting power for outlet " + o + ": " + err.Error()
+			framework.AddToErrors(socketKey, errMsg)
+			return errMsg, errors.New(errMsg)
+		}
+
+		// Good response is "1" (on) or "0" (off)
+		resp = strings.ReplaceAll(resp, `"`, ``)
+		state, err := stringIntToStringBool(socketKey, resp)
+		if err != nil {
+			errMsg := function + " - invalid response for outlet " + o + ": " + resp
+			disconnectAfterBadData(socketKey, function)
+			framework.AddToErrors(socketKey, errMsg)
+			return errMsg, errors.New(errMsg)
+		}
+		if state == "false" {
+			allOn = false
+		}
+	}
+
+	if allOn {
+		return `"true"`, nil
+	}
+	return `"false"`, nil
+}
+
+// Power save mode of the Extron unit itself, not the display.  "true" is in power save.
+func getPowerSaveDo(socketKey string, endpoint string, _ string, _ string, _ string) (string, error) {
+	function := "getPowerSaveDo"
+
+	resp, err := deviceTypeDependantCommand(socketKey, "powersave", "GET", "", "", "")
+	if err != nil {
+		errMsg := function + " - error getting power save mode: " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	// Good response is the power save mode, ex: "0" (normal) or "Psav1"
+	resp = strings.ReplaceAll(resp, `"`, ``)
+	switch strings.TrimPrefix(resp, "Psav") {
+	case "0":
+		return `"false"`, nil
+	case "1", "2", "3":
+		return `"true"`, nil
+	}
+	if strings.Contains(resp, "error") {
+		return resp, errors.New(resp) // device returned an error code
+	}
+	errMsg := function + " - invalid response for power save mode: " + resp
+	disconnectAfterBadData(socketKey, function)
+	framework.AddToErrors(socketKey, errMsg)
+	return errMsg, errors.New(errMsg)
+}
+
+// Privacy / blank: "true" only when both the video and the audio of the output are muted.
+// See setAudioAndVideoMuteDo for what the audio leg is on each device.
+func getAudioAndVideoMuteDo(socketKey string, endpoint string, output string, _ string, _ string) (string, error) {
+	function := "getAudioAndVideoMuteDo"
+
+	videoMuted, err := getVideoMuteDo(socketKey, "videomute", output, "", "")
+	if err != nil {
+		errMsg := function + " - error getting video leg: " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	audioMuted, err := getOutputAudioMute(socketKey, output)
+	if err != nil {
+		errMsg := function + " - error getting audio leg: " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	videoMuted = strings.ReplaceAll(videoMuted, `"`, ``)
+	audioMuted = strings.ReplaceAll(audioMuted, `"`, ``)
+	if videoMuted == "true" && audioMuted == "true" {
+		return `"true"`, nil
+	}
+	return `"false"`, nil
+}
+
+// Occupancy sensor on a controller digital input or contact closure port.
+// Normal polarity: closed (1) is occupied.  Use "inverted" for sensors that close when the room is vacant.
+// The port keeps the polarity it was given, leave it off to use what the port has (normal unless the profile's invertedInputs lists it).
+// The result is debounced, see occupancy.go.
+// Ex: curl "http://<containerIP>/telnet|admin:pw@<deviceAddr>/occupancystatus/2/inverted"
+func getOccupancyStatusDo(socketKey string, endpoint string, port string, polarity string, _ string) (string, error) {
+	function := "getOccupancyStatusDo"
+
+	deviceType, err := findDeviceType(socketKey)
+	if err != nil {
+		errMsg := fmt.Sprintf(function+" - error finding device type: %s", err.Error())
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+	if deviceType != "Controller" {
+		errMsg := function + " - occupancy inputs are only supported on controllers, device type is: " + deviceType
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	portNum, err := strconv.Atoi(port)
+	if err != nil || portNum < 1 {
+		errMsg := function + " - invalid input port: " + port
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+	port = strconv.Itoa(portNum)
+
+	switch strings.ToLower(strings.Trim(polarity, `"'`)) {
+	case "": // keep what the port has, from the profile's invertedInputs or an earlier get
+	case "normal":
+		setOccupancyPolarity(socketKey, port, false, "get")
+	case "inverted":
+		setOccupancyPolarity(socketKey, port, true, "get")
+	default:
+		errMsg := function + " - polarity must be 'normal' or 'inverted'.  Got: " + polarity
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	resp, err := deviceTypeDependantCommand(socketKey, "occupancystatus", "GET", port, "", "")
+	if err != nil {
+		errMsg := function + " - error getting input " + port + ": " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	// Good response ends in "1" (closed) or "0" (open), ex: "1" or "Cpn2 Sio1"
+	resp = strings.TrimSpace(strings.ReplaceAll(resp, `"`, ``))
+	if len(resp) == 0 || (!strings.HasSuffix(resp, "1") && !strings.HasSuffix(resp, "0")) {
+		errMsg := function + " - invalid response for input " + port + ": " + resp
+		disconnectAfterBadData(socketKey, function)
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	if observeOccupancyInput(socketKey, port, resp[len(resp)-1:], "get") {
+		return `"true"`, nil
+	}
+	return `"false"`, nil
+}
+
+// Relay state on a controller. "true" is closed.
+func getStateDo(socketKey string, endpoint string, relay string, _ string, _ string) (string, error) {
+	function := "getStateDo"
+
+	relay, err := findRelay(socketKey, relay)
+	if err != nil {
+		errMsg := function + " - " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	state, err := readRelayState(socketKey, relay)
+	if err != nil {
+		errMsg := function + " - " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+	return `"` + state + `"`, nil
+}
+
+// Every video and audio tie of a matrix switcher in one call (one command per 16 outputs, per media).
+// Keyed by output name from the device profile, so "3A" and "3B" both show output 3's tie.  "0" is untied.
+// Ex: {"video":{"1":"2","2":"1","3A":"4","3B":"4"},"audio":{"1":"2","2":"1","3A":"4","3B":"4"}}
+func getMatrixTiesDo(socketKey string, endpoint string, _ string, _ string, _ string) (string, error) {
+	function := "getMatrixTiesDo"
+
+	model, err := findModelName(socketKey)
+	if err != nil {
+		modelErr := function + " - can not find model for: " + socketKey
+		framework.AddToErrors(socketKey, modelErr)
+		return modelErr, errors.New(modelErr)
+	}
+	_, outMap := ioMaps(socketKey, profileForModel(socketKey, model))
+
+	tables := make(map[string]map[string]string, 2)
+	for _, media := range []struct{ name, arg string }{{"video", "1"}, {"audio", "2"}} {
+		ties, err := readMatrixTies(socketKey, media.arg, tieOutputCount(socketKey, outMap, media.arg))
+		if err != nil {
+			errMsg := function + " - error getting " + media.name + " ties: " + err.Error()
+			framework.AddToErrors(socketKey, errMsg)
+			return errMsg, errors.New(errMsg)
+		}
+
+		table := make(map[string]string, len(ties))
+		if len(outMap) == 0 { // no names known, use the output numbers
+			for i, input := range ties {
+				table[strconv.Itoa(i+1)] = input
+			}
+		}
+		for name := range outMap {
+			outputNum, err := strconv.Atoi(strings.TrimRight(name, "AB")) // "3A" and "3B" are both output 3
+			if err != nil || outputNum < 1 || outputNum > len(ties) {
+				continue
+			}
+			table[name] = ties[outputNum-1]
+		}
+		tables[media.name] = table
+
+		names := make([]string, 0, len(table))
+		for name := range table {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names { // the same outputs as the answer, ex: "3A" and "3B" rather than "3"
+			recordRoute(socketKey, "matrixties", name, table[name], media.name)
+		}
+	}
+
+	data, err := json.Marshal(tables)
+	if err != nil {
+		errMsg := function + " - error encoding ties: " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+	return string(data), nil
+}
+
+// Names of the global presets, ex: {"1":"Lecture","2":"Divided","3":"[unassigned]"}
+func getPresetsDo(socketKey string, endpoint string, _ string, _ string, _ string) (string, error) {
+	function := "getPresetsDo"
+
+	names, err := readPresetNames(socketKey)
+	if err != nil {
+		errMsg := function + " - " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	presets := make(map[string]string, len(names))
+	for i, name := range names {
+		presets[strconv.Itoa(i+1)] = name
+	}
+	data, err := json.Marshal(presets)
+	if err != nil {
+		errMsg := function + " - error encoding preset names: " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+	return string(data), nil
+}
+
+func getInputNameDo(socketKey string, endpoint string, input string, _ string, _ string) (string, error) {
+	return getIONameDo(socketKey, "getInputNameDo", "inputname", input)
+}
+
+func getOutputNameDo(socketKey string, endpoint string, output string, _ string, _ string) (string, error) {
+	return getIONameDo(socketKey, "getOutputNameDo", "outputname", output)
+}
+
+// Every input and output name, ex: {"inputs":{"1":"Laptop","2":"Doc Cam"},"outputs":{"1":"Projector"}}
+// Scalers only have inputs.  Keyed by number, "3A" and "3B" are both output 3.
+func getNamesDo(socketKey string, endpoint string, _ string, _ string, _ string) (string, error) {
+	function := "getNamesDo"
+
+	deviceType, err := findDeviceType(socketKey)
+	if err != nil {
+		errMsg := fmt.Sprintf(function+" - error finding device type: %s", err.Error())
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	names := make(map[string]map[string]string, 2)
+	for _, table := range []struct{ key, endpoint string }{{"inputs", "inputname"}, {"outputs", "outputname"}} {
+		if _, exists := internalGetCmdMap[table.endpoint][deviceType]; !exists {
+			continue // ex: scalers don't name their output
+		}
+		numbers, err := ioNumbers(socketKey, strings.TrimSuffix(table.endpoint, "name"))
+		if err != nil {
+			errMsg := function + " - " + err.Error()
+			framework.AddToErrors(socketKey, errMsg)
+			return errMsg, errors.New(errMsg)
+		}
+
+		names[table.key] = make(map[string]string, len(numbers))
+		for _, number := range numbers {
+			name, err := readIOName(socketKey, table.endpoint, strconv.Itoa(number))
+			if err != nil {
+				errMsg := function + " - " + err.Error()
+				framework.AddToErrors(socketKey, errMsg)
+				return errMsg, errors.New(errMsg)
+			}
+			names[table.key][strconv.Itoa(number)] = name
+
+			ev := deviceEvent{SocketKey: socketKey, Kind: valueChanged, Source: "get", Endpoint: table.endpoint,
+				Output: strconv.Itoa(number), State: name, Time: time.Now()}
+			if updateShadow(ev) {
+				publishDeviceEvent(ev)
+			}
+		}
+	}
+
+	data, err := json.Marshal(names)
+	if err != nil {
+		errMsg := function + " - error encoding names: " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+	return string(data), nil
+}
+
+// Ex: {"input":"2","sourcePresent":true,"hdcp":"1.4","authorized":true}
+func getHDCPInputStatusDo(socketKey string, endpoint string, input string, _ string, _ string) (string, error) {
+	function := "getHDCPInputStatusDo"
+
+	input, err := findIONumber(socketKey, "input", input)
+	if err != nil {
+		errMsg := function + " - " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+	status, err := readHDCPInputStatus(socketKey, input)
+	if err != nil {
+		errMsg := function + " - " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	data, _ := json.Marshal(status)
+	return string(data), nil
+}
+
+// Ex: {"output":"1","sinkConnected":true,"hdcp":"none"}
+func getHDCPOutputStatusDo(socketKey string, endpoint string, output string, _ string, _ string) (string, error) {
+	function := "getHDCPOutputStatusDo"
+
+	output, err := findIONumber(socketKey, "output", output)
+	if err != nil {
+		errMsg := function + " - " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+	status, err := readHDCPOutputStatus(socketKey, output)
+	if err != nil {
+		errMsg := function + " - " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	data, _ := json.Marshal(status)
+	return string(data), nil
+}
+
+// Every input and output, ex: {"inputs":[{"input":"1",...}],"outputs":[{"output":"1",...}]}
+// For the helpdesk's "laptop shows nothing": is the laptop seen, is it encrypting, is the display there and can it decrypt
+func getHDCPStatusDo(socketKey string, endpoint string, _ string, _ string, _ string) (string, error) {
+	function := "getHDCPStatusDo"
+
+	inputs, err := ioNumbers(socketKey, "input")
+	if err != nil {
+		errMsg := function + " - " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+	outputs, err := ioNumbers(socketKey, "output")
+	if err != nil {
+		errMsg := function + " - " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	all := struct {
+		Inputs  []*hdcpInputStatus  `json:"inputs"`
+		Outputs []*hdcpOutputStatus `json:"outputs"`
+	}{}
+	for _, input := range inputs {
+		status, err := readHDCPInputStatus(socketKey, strconv.Itoa(input))
+		if err != nil {
+			errMsg := function + " - " + err.Error()
+			framework.AddToErrors(socketKey, errMsg)
+			return errMsg, errors.New(errMsg)
+		}
+		all.Inputs = append(all.Inputs, status)
+		data, _ := json.Marshal(status)
+		recordHDCPStatus(socketKey, "hdcpinputstatus", status.Input, string(data))
+	}
+	for _, output := range outputs {
+		status, err := readHDCPOutputStatus(socketKey, strconv.Itoa(output))
+		if err != nil {
+			errMsg := function + " - " + err.Error()
+			framework.AddToErrors(socketKey, errMsg)
+			return errMsg, errors.New(errMsg)
+		}
+		all.Outputs = append(all.Outputs, status)
+		data, _ := json.Marshal(status)
+		recordHDCPStatus(socketKey, "hdcpoutputstatus", status.Output, string(data))
+	}
+
+	data, err := json.Marshal(all)
+	if err != nil {
+		errMsg := function + " - error encoding HDCP status: " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+	return string(data), nil
+}
+
+// Whether an input tells sources it accepts HDCP
+func getHDCPAuthorizationDo(socketKey string, endpoint string, input string, _ string, _ string) (string, error) {
+	function := "getHDCPAuthorizationDo"
+
+	input, err := findIONumber(socketKey, "input", input)
+	if err != nil {
+		errMsg := function + " - " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+	authorized, err := readHDCPAuthorization(socketKey, input)
+	if err != nil {
+		errMsg := function + " - " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+	return `"` + strconv.FormatBool(authorized) + `"`, nil
+}
+
+// The EDID table, ex: [{"slot":"1","description":"Output 1","followsOutput":"1"},{"slot":"5","description":"1920x1080 @60Hz 2ch","resolution":"1920x1080","refreshRate":60,"audio":"2ch"}]
+func getEDIDPresetsDo(socketKey string, endpoint string, _ string, _ string, _ string) (string, error) {
+	function := "getEDIDPresetsDo"
+
+	edids, err := readEDIDTable(socketKey, "")
+	if err != nil {
+		errMsg := function + " - " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	data, err := json.Marshal(edids)
+	if err != nil {
+		errMsg := function + " - error encoding EDID table: " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+	return string(data), nil
+}
+
+// The EDID assigned to an input, ex: {"input":"2","slot":"5","description":"1920x1080 @60Hz 2ch","resolution":"1920x1080","refreshRate":60,"audio":"2ch"}
+func getEDIDDo(socketKey string, endpoint string, input string, _ string, _ string) (string, error) {
+	function := "getEDIDDo"
+
+	input, err := findIONumber(socketKey, "input", input)
+	if err != nil {
+		errMsg := function + " - " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	resp, err := deviceTypeDependantCommand(socketKey, "edid", "GET", input, "", "")
+	if err != nil {
+		errMsg := function + " - error getting EDID of input " + input + ": " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+	resp = strings.TrimSpace(strings.ReplaceAll(resp, `"`, ``))
+	if strings.Contains(resp, "error") {
+		return resp, errors.New(resp) // device returned an error code
+	}
+	slot, err := strconv.Atoi(resp)
+	if err != nil {
+		errMsg := function + " - invalid EDID slot for input " + input + ": " + resp
+		disconnectAfterBadData(socketKey, function)
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	edid, err := readEDID(socketKey, strconv.Itoa(slot))
+	if err != nil {
+		errMsg := function + " - " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+	data, _ := json.Marshal(struct {
+		Input string `json:"input"`
+		*edidInfo
+	}{input, edid})
+	return string(data), nil
+}
+
+// Set functions //
+
+// Used for group volume control of non-matrix devices.
+// For dedicated DSP units and matrix switchers, you'll want to use "matrixvolume" instead.
+// The IN1804 has one program volume in whole dB on a dBFS scale, see transformVolumeIN1804.
+func setVolumeDo(socketKey string, endpoint string, name string, level string, _ string) (string, error) {
+	function := "setVolumeDo"
+
+	model, err := findModelName(socketKey)
+	if err != nil {
+		modelErr := function + " - can not find model for: " + socketKey
+		framework.AddToErrors(socketKey, modelErr)
+		return modelErr, errors.New(modelErr)
+	}
+
+	// Check if model is supported
+	profile := profileForModel(socketKey, model)
+	if profile == nil || len(profile.VolumeGroups) == 0 {
+		notImpMsg := function + " - model " + model + " is not implemented or does not support 'volume'"
+		framework.AddToErrors(socketKey, notImpMsg)
+		return notImpMsg, errors.New(notImpMsg)
+	}
+	oid, ok := profile.VolumeGroups[name] // mix point number
+
+	// Check if we have the channel name+oid mapping for the model
+	if !ok {
+		errMsg := function + " - can't find group OID (X47) for: " + name + " on model: " + model
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	// Convert percent to device volume (tenths of DB)
+	var deviceVolume string
+	if profile.CommandVariant == "IN1804" {
+		deviceVolume, err = transformVolumeIN1804(level)
+	} else {
+		deviceVolume, err = newTransformVolume(volumeCurveFor(socketKey, "volume"), level)
+	}
+	if err != nil {
+		errMsg := function + " - error converting percent to device volume: " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	// Try sending the command
+	resp, err := deviceTypeDependantCommand(socketKey, "volume", "SET", oid, deviceVolume, "")
+	if err != nil {
+		errMsg := function + " - error setting volume " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	// Good response is "GrpmD<mixPointNumber>*<volume>"
+	resp = strings.ReplaceAll(resp, `"`, ``)
+	expectedResp := "GrpmD" + oid + "*" + deviceVolume
+	if profile.CommandVariant == "IN1804" {
+		expectedResp = "Vol" + deviceVolume // one program volume, no groups
+	}
+	if resp != expectedResp {
+		errMsg := function + " - invalid response for setting volume: " + resp + ", expected: " + expectedResp
+		disconnectAfterBadData(socketKey, function)
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+	return "ok", nil
+}
+
+// Group mutes. Used for non-matrix devices
+func setAudioMuteDo(socketKey string, endpoint string, name string, mute string, _ string) (string, error) {
+	function := "setAudioMuteDo"
+
+	mute = strings.ReplaceAll(mute, "\"", "")
+	mute = strings.ReplaceAll(mute, "'", "")
+
+	muteCmd := "0"
+	if mute == "true" {
+		muteCmd = "1"
+	}
+
+	model, err := findModelName(socketKey)
+	if err != nil {
+		modelErr := function + " - can not find model for: " + socketKey
+		framework.AddToErrors(socketKey, modelErr)
+		return modelErr, errors.New(modelErr)
+	}
+
+	// Check if model is supported
+	profile := profileForModel(socketKey, model)
+	if profile == nil || len(profile.MuteGroups) == 0 {
+		notImpMsg := function + " - model " + model + " is not implemented or does not support 'audiomute'"
+		framework.AddToErrors(socketKey, notImpMsg)
+		return notImpMsg, errors.New(notImpMsg)
+	}
+	oid, ok := profile.MuteGroups[name] // mix point number
+
+	// Check if we have the channel name+oid mapping for the model
+	if !ok {
+		errMsg := function + " - can't find mute group OID (X48) for: " + name + " on model: " + model
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	// Try sending the command
+	resp, err := deviceTypeDependantCommand(socketKey, "audiomute", "SET", oid, muteCmd, "")
+	if err != nil {
+		errMsg := function + " - error setting volume " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	// Good response is "GrpmD<oid>*<muteCmd>"
+	resp = strings.ReplaceAll(resp, `"`, ``)
+	expectedResp := "GrpmD" + oid + "*" + muteCmd
+	if profile.CommandVariant == "IN1804" {
+		expectedResp = "Amt" + muteCmd // one program mute, no groups
+	}
+	if resp != expectedResp {
+		errMsg := function + " - invalid response for setting group mute: " + resp + ", expected: " + expectedResp
+		disconnectAfterBadData(socketKey, function)
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+	return "ok", nil
+}
+
+// Same as volume, in decibels with one decimal, ex: "-3.5".  Levels outside the group's range are rejected, not clamped:
+// -100 to +12 dB, or whole dB from -100 to 0 on the IN1804.
+func setVolumeDbDo(socketKey string, endpoint string, name string, level string, _ string) (string, error) {
+	function := "setVolumeDbDo"
+
+	profile, oid, err := findVolumeGroup(socketKey, name)
+	if err != nil {
+		errMsg := function + " - " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	minTenths, maxTenths := minVolumeTenths, maxVolumeTenths
+	if profile.CommandVariant == "IN1804" {
+		minTenths, maxTenths = -1000, 0
+	}
+	tenths, err := dbToTenths(level, minTenths, maxTenths)
+	if err != nil {
+		errMsg := function + " - " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	deviceVolume := strconv.Itoa(tenths)
+	expectedResp := "GrpmD" + oid + "*" + deviceVolume
+	if profile.CommandVariant == "IN1804" {
+		if tenths%10 != 0 {
+			errMsg := function + " - " + profile.Name + " only takes whole dB, got " + tenthsToDb(tenths)
+			framework.AddToErrors(socketKey, errMsg)
+			return errMsg, errors.New(errMsg)
+		}
+		deviceVolume = strconv.Itoa(tenths / 10)
+		expectedResp = "Vol" + deviceVolume // one program volume, no groups
+	}
+
+	resp, err := deviceTypeDependantCommand(socketKey, "volume", "SET", oid, deviceVolume, "")
+	if err != nil {
+		errMsg := function + " - error setting volume " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	if strings.Contains(resp, "error") {
+		return resp, errors.New(resp) // device returned an error code
+	}
+	resp = strings.ReplaceAll(resp, `"`, ``)
+	if resp != expectedResp {
+		errMsg := function + " - invalid response for setting volume: " + resp + ", expected: " + expectedResp
+		disconnectAfterBadData(socketKey, function)
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+	return "ok", nil
+}
+
+func setVideoRouteDo(socketKey string, endpoint string, output string, input string, _ string) (string, error) {
+	function := "setVideoRouteDo"
+
+	// Yes, calling this here results in findDeviceType being called twice in a flow,
+	// But we need to translate or throw away the 'output' arg for non-matrix devices before formatting
+	deviceType, err := findDeviceType(socketKey)
+	if err != nil {
+		errMsg := fmt.Sprintf(function+" - error finding device type: %s", err.Error())
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+	output, err = tieOutput(socketKey, deviceType, output)
+	if err != nil {
+		errMsg := function + " - " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	input = strings.ReplaceAll(input, "\"", "")
+	input = strings.ReplaceAll(input, "'", "")
+
+	resp, err := deviceTypeDependantCommand(socketKey, "videoroute", "SET", input, output, "")
+	if err != nil {
+		errMsg := function + "- error setting video route: " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+	// Good response example is "Out4 In6 Vid" for a matrix switcher or IN 180x, "In6 RGB" for other scalers
+	// Any errors will have been formatted in formatDeviceErrMessage
+	if strings.Contains(resp, "error") {
+		return resp, errors.New(resp) // device returned an error code
+	}
+	if err := checkTieEcho(resp, input, output, "Vid", "RGB"); err != nil {
+		errMsg := function + " - invalid response for video route: " + err.Error()
+		disconnectAfterBadData(socketKey, function)
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+	return "ok", nil
+}
+
+func setAudioAndVideoRoute(socketKey string, endpoint string, output string, input string, _ string) (string, error) {
+	function := "setAudioAndVideoRoute"
+
+	// Yes, calling this here results in findDeviceType being called twice in a flow,
+	// But we need to translate or throw away the 'output' arg for non-matrix devices before formatting
+	deviceType, err := findDeviceType(socketKey)
+	if err != nil {
+		errMsg := fmt.Sprintf(function+" - error finding device type: %s", err.Error())
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+	output, err = tieOutput(socketKey, deviceType, output)
+	if err != nil {
+		errMsg := function + " - " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	input = strings.ReplaceAll(input, "\"", "")
+	input = strings.ReplaceAll(input, "'", "")
+
+	resp, err := deviceTypeDependantCommand(socketKey, "audioandvideoroute", "SET", input, output, "")
+	if err != nil {
+		errMsg := function + "- error setting audio and video route: " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	// Matrix and IN 180x good response: "Out4 In2 All"
+	// Other scalers and switchers good response: "In02 All"
+	if strings.Contains(resp, "error") {
+		return resp, errors.New(resp) // device returned an error code
+	}
+	if err := checkTieEcho(resp, input, output, "All"); err != nil {
+		errMsg := function + " - invalid response for audio and video route: " + err.Error()
+		disconnectAfterBadData(socketKey, function)
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+	return "ok", nil
+}
+
+func setAudioRouteDo(socketKey string, endpoint string, output string, input string, _ string) (string, error) {
+	function := "setAudioRouteDo"
+
+	// translate or throw away the 'output' arg for non-matrix devices before formatting
+	deviceType, err := findDeviceType(socketKey)
+	if err != nil {
+		errMsg := fmt.Sprintf(function+" - error finding device type: %s", err.Error())
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+	output, err = tieOutput(socketKey, deviceType, output)
+	if err != nil {
+		errMsg := function + " - " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	input = strings.ReplaceAll(input, "\"", "")
+	input = strings.ReplaceAll(input, "'", "")
+
+	resp, err := deviceTypeDependantCommand(socketKey, "audioroute", "SET", input, output, "")
+	if err != nil {
+		errMsg := function + "- error setting audio route: " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	// Matrix and IN 180x good response: "Out4 In2 Aud"
+	// Other scalers good response: "In02 Aud"
+	if strings.Contains(resp, "error") {
+		return resp, errors.New(resp) // device returned an error code
+	}
+	if err := checkTieEcho(resp, input, output, "Aud"); err != nil {
+		errMsg := function + " - invalid response for audio route: " + err.Error()
+		disconnectAfterBadData(socketKey, function)
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+	return "ok", nil
+}
+
+// The profile of a device that has volume groups, and the group number (X46) of one of them
+func findVolumeGroup(socketKey string, name string) (*deviceProfile, string, error) {
+	model, err := findModelName(socketKey)
+	if err != nil {
+		return nil, "", errors.New("can not find model for: " + socketKey)
+	}
+	profile := profileForModel(socketKey, model)
+	if profile == nil || len(profile.VolumeGroups) == 0 {
+		return nil, "", errors.New("model " + model + " is not implemented or does not support 'volume'")
+	}
+	oid, ok := profile.VolumeGroups[strings.Trim(name, `"'`)]
+	if !ok {
+		return nil, "", errors.New("can't find OID for: " + name + " on model: " + model)
+	}
+	return profile, oid, nil
+}
+
+// The output arg of the tie commands.  Matrix switchers take the output as given.
+// Scalers that tie per output (tieOutputs in the profile, ex: IN 180x 1A/1B and LoopOut) take the output's tie number,
+// with no output meaning the main one.  Other devices have one output, so there is nothing to send.
+func tieOutput(socketKey string, deviceType string, output string) (string, error) {
+	if deviceType == "Matrix Switcher" {
+		return output, nil
+	}
+	profile := profileForModel(socketKey, deviceModel(socketKey))
+	if profile == nil || len(profile.TieOutputs) == 0 {
+		return "", nil
+	}
+
+	output = strings.Trim(output, `"'`)
+	if output == "" {
+		return "1", nil
+	}
+	if number, exists := profile.TieOutputs[output]; exists {
+		return number, nil
+	}
+	names := make([]string, 0, len(profile.TieOutputs))
+	for name, number := range profile.TieOutputs {
+		if number == output { // already a tie number
+			return output, nil
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return "", fmt.Errorf("unknown output '%s', %s has outputs: %s", output, profile.Name, strings.Join(names, ", "))
+}
+
+// Tie echoes, ex: "Out4 In6 Vid" from matrix switchers and the IN 180x, "In6 RGB" or "In02 All" from other scalers and switchers
+var tieEchoPattern = regexp.MustCompile(`^(?:Out(\d+) )?In(\d+) (Vid|RGB|Aud|All)$`)
+
+// Checks that a tie echo is for the input and output that were sent, with one of the expected media suffixes.
+// output is "" for devices with one output.
+func checkTieEcho(resp string, input string, output string, media ...string) error {
+	resp = strings.TrimSpace(strings.ReplaceAll(resp, `"`, ``))
+	match := tieEchoPattern.FindStringSubmatch(resp)
+	switch {
+	case match == nil:
+		return fmt.Errorf("unexpected tie echo '%s'", resp)
+	case trimZeros(match[2]) != trimZeros(input):
+		return fmt.Errorf("'%s' is for input %s, expected input %s", resp, trimZeros(match[2]), input)
+	case output != "" && trimZeros(match[1]) != trimZeros(output):
+		return fmt.Errorf("'%s' is not for output %s", resp, output)
+	case !containsString(media, match[3]):
+		return fmt.Errorf("'%s' is not a %s tie", resp, strings.Join(media, "/"))
+	}
+	return nil
+}
+
+func setVideoMuteDo(socketKey string, endpoint string, output string, state string, _ string) (string, error) {
+	state = strings.ReplaceAll(state, "\"", "")
+	state = strings.ReplaceAll(state, "'", "")
+
+	stateCmd := "0"
+	if state == "true" {
+		stateCmd = "1"
+	}
+	return writeVideoMute(socketKey, output, stateCmd)
+}
+
+// Sets the video mute of an output to what the device calls it: "0" (not muted), "1" (muted with sync) or "2" (sync mute)
+func writeVideoMute(socketKey string, output string, stateCmd string) (string, error) {
+	function := "writeVideoMute"
+
+	model, err := findModelName(socketKey)
+	if err != nil {
+		modelErr := function + " - can not find model for: " + socketKey
+		framework.AddToErrors(socketKey, modelErr)
+		return modelErr, errors.New(modelErr)
+	}
+	profile := profileForModel(socketKey, model)
+	if profile != nil && len(profile.VideoMutes) > 0 { // ex: IN 160x mutes "1A" as "1"
+		outputNum, ok := profile.VideoMutes[output]
+		output = outputNum
+		if !ok {
+			errMsg := function + " - can't find video mute mapping" + output + "for provided output on model: " + model
+			framework.AddToErrors(socketKey, errMsg)
+			return errMsg, errors.New(errMsg)
+		}
+	} // other devices like crosspoints you can call the named output directly ex: "3A"
+
+	// TODO: DA mapping (ex: /loopthrough should be command 0)
+	resp, err := deviceTypeDependantCommand(socketKey, "videomute", "SET", output, stateCmd, "")
+	if err != nil {
+		errMsg := function + "- error setting video mute: " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	// Good response for 'all' devices: Vmt(output int)*(status int)
+	if strings.Contains(resp, "Vmt") && strings.Contains(resp, output) {
+		return "ok", nil
+	} else {
+		errMsg := function + " - invalid response for video mute: " + resp
+		disconnectAfterBadData(socketKey, function)
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+}
+
+// func setVideoSyncMuteDo(socketKey string, endpoint string, output string, state string, _ string) (string, error) {
+// 	function := "setVideoSyncMuteDo"
+
+// 	var cmd string
+// 	if state == "true" {
+// 		cmd = "videosyncmute"
+// 	} else {
+// 		cmd = "videounmute"
+// 	}
+
+// 	// DA with loop through, loop through is output "0"
+// 	// Matrix switchers need to refer to output by name (ex: "3B")
+// 	// IN 180x needs to refer to output by number (ex: 1B would be "2")
+// 	// Non IN 180x Scalers or switchers can just call "1"
+// 	resp, err := deviceTypeDependantCommand(socketKey, cmd, "SET", output, "", "")
+// 	if err != nil {
+// 		errMsg := function + "- error setting video sync mute: " + err.Error()
+// 		framework.AddToErrors(socketKey, errMsg)
+// 		return errMsg, errors.New(errMsg)
+// 	}
+
+// 	// Good response for 'all' devices: Vmt(output int)*(status int)
+// 	if strings.Contains(resp, "Vmt") && strings.Contains(resp, output) {
+// 		return "ok", nil
+// 	} else {
+// 		errMsg := function + " - invalid response for video sync mute: " + resp
+// 		disconnectAfterBadData(socketKey, function)
+// 		framework.AddToErrors(socketKey, errMsg)
+// 		return errMsg, errors.New(errMsg)
+// 	}
+// }
+
+// Built for DMP DSP's
+// Note, this is a 3 arg funciton.  The last argument (state) true or false is in the request body
+// Ex: curl -X PUT "http://<containerIP>/telnet|admin:pw@<deviceAddr>/matrixmute/MicToOut3/4" -H "Content-Type: application/json" -d true
+func setMatrixMuteDo(socketKey string, endpoint string, input string, output string, state string) (string, error) {
+	function := "setMatrixMuteDo"
+
+	if state == "" || state == "null" || state == "\"null\"" {
+		emptyStateMsg := function + "- Arg3 is required but not found in the request body"
+		framework.AddToErrors(socketKey, emptyStateMsg)
+		return emptyStateMsg, errors.New(emptyStateMsg)
+	}
+	var cmdState string
+	switch {
+	case strings.Contains(state, "false"):
+		cmdState = "0"
+	case strings.Contains(state, "true"):
+		cmdState = "1"
+	default:
+		stateErrMsg := function + " - Arg 3 must be 'true', or 'false'.  Got: " + state
+		framework.AddToErrors(socketKey, stateErrMsg)
+		return stateErrMsg, errors.New(stateErrMsg)
+	}
+
+	mixPointNumber, err := deviceMixPointNumber(socketKey, input, output)
+	if err != nil {
+		errMsg := function + " - error calculating mix point number: " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	resp, err := deviceTypeDependantCommand(socketKey, "matrixmute", "SET", mixPointNumber, cmdState, "")
+	if err != nil {
+		errMsg := function + "- error setting matrix mute of mix point " + dmpMixPointLabel(deviceModel(socketKey), mixPointNumber) + ": " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	// Successful response is "DsM<mixPointNumber>*<1|0>"
+	if strings.Contains(resp, "DsM") && strings.Contains(resp, cmdState) && strings.Contains(resp, mixPointNumber) {
+		return "ok", nil
+	} else {
+		badRespMsg := function + " - unexpected device response for mix point " + dmpMixPointLabel(deviceModel(socketKey), mixPointNumber) + ": " + resp
+		disconnectAfterBadData(socketKey, function)
+		framework.AddToErrors(socketKey, badRespMsg)
+		return badRespMsg, errors.New(badRespMsg)
+	}
+}
+
+// Note, this is a 3 arg funciton.  The last argument (level 0-100) is in the request body
+// Ex: curl -X PUT "http://<containerIP>/telnet|admin:pw@<deviceAddr>/matrixvolume/MicToOut3/4" -H "Content-Type: application/json" -d 76
+func setMatrixVolumeDo(socketKey string, endpoint string, input string, output string, level string) (string, error) {
+	function := "setMatrixVolumeDo"
+
+	mixPointNumber, err := deviceMixPointNumber(socketKey, input, output)
+	if err != nil {
+		errMsg := function + " - error calculating mix point number: " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	// Framework enqueues arg3 wrapped in quotes (e.g., "50"). Sanitize before converting.
+	levelSanitized := strings.TrimSpace(strings.Trim(level, `"`))
+	if levelSanitized == "" {
+		errMsg := function + " - level (0-100) required in request body"
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	levelVal, err := newTransformVolume(volumeCurveFor(socketKey, "matrixvolume"), levelSanitized)
+	if err != nil {
+		errMsg := function + " - error converting percent volume to device volume: " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	resp, err := deviceTypeDependantCommand(socketKey, "matrixvolume", "SET", mixPointNumber, levelVal, "")
+	if err != nil {
+		errMsg := function + "- error setting matrix volume of mix point " + dmpMixPointLabel(deviceModel(socketKey), mixPointNumber) + ": " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	// Valid response is "DsG<mixPointNumber>*<levelVal>"
+	if strings.Contains(resp, "DsG") && strings.Contains(resp, mixPointNumber) && strings.Contains(resp, levelVal) {
+		return "ok", nil
+	} else {
+		errMsg := function + " - invalid response for setting matrix volume of mix point " + dmpMixPointLabel(deviceModel(socketKey), mixPointNumber) + ": " + resp
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+}
+
+// Same as matrixvolume, in decibels with one decimal, ex: "-3.5".  Levels outside the mix point range (-100 to +12 dB) are rejected, not clamped.
+// Ex: curl -X PUT "http://<containerIP>/telnet|admin:pw@<deviceAddr>/matrixvolumedb/MicToOut3/4" -H "Content-Type: application/json" -d -3.5
+func setMatrixVolumeDbDo(socketKey string, endpoint string, input string, output string, level string) (string, error) {
+	function := "setMatrixVolumeDbDo"
+
+	mixPointNumber, err := deviceMixPointNumber(socketKey, input, output)
+	if err != nil {
+		errMsg := function + " - error calculating mix point number: " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	tenths, err := dbToTenths(level, minVolumeTenths, maxVolumeTenths)
+	if err != nil {
+		errMsg := function + " - " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+	levelVal := strconv.Itoa(tenths)
+
+	resp, err := deviceTypeDependantCommand(socketKey, "matrixvolume", "SET", mixPointNumber, levelVal, "")
+	if err != nil {
+		errMsg := function + "- error setting matrix volume of mix point " + dmpMixPointLabel(deviceModel(socketKey), mixPointNumber) + ": " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	// Valid response is "DsG<mixPointNumber>*<levelVal>"
+	if strings.Contains(resp, "error") {
+		return resp, errors.New(resp) // device returned an error code
+	}
+	resp = strings.ReplaceAll(resp, `"`, ``)
+	if strings.Contains(resp, "DsG"+mixPointNumber+"*") && strings.HasSuffix(resp, "*"+levelVal) {
+		return "ok", nil
+	}
+	errMsg := function + " - invalid response for setting matrix volume of mix point " + dmpMixPointLabel(deviceModel(socketKey), mixPointNumber) + ": " + resp
+	disconnectAfterBadData(socketKey, function)
+	framework.AddToErrors(socketKey, errMsg)
+	return errMsg, errors.New(errMsg)
+}
+
+// DMP gain blocks, arg1: channel, arg2: level 0-100 over the block's own range, ex: input gain can go to +80 dB, outputs only attenuate.
+// Ex: curl -X PUT "http://<containerIP>/telnet|admin:pw@<deviceAddr>/dmpoutputvolume/3/60"
+func setDmpGainDo(socketKey string, endpoint string, channel string, level string, _ string) (string, error) {
+	function := "setDmpGainDo"
+
+	block, exists := dmpGainBlocks[endpoint]
+	if !exists {
+		errMsg := function + " - no DMP gain block for endpoint: " + endpoint
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+	oid, err := deviceGainBlockNumber(socketKey, block, channel)
+	if err != nil {
+		errMsg := function + " - error calculating object ID: " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	level = strings.TrimSpace(strings.Trim(level, `"`))
+	if level == "" {
+		errMsg := function + " - level (0-100) required"
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+	levelVal, err := transformVolume(volumeCurveFor(socketKey, block.Endpoint), level, block.MinTenths, block.MaxTenths)
+	if err != nil {
+		errMsg := function + " - error converting percent volume to device volume: " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	resp, err := deviceTypeDependantCommand(socketKey, "matrixvolume", "SET", oid, levelVal, "")
+	if err != nil {
+		errMsg := function + " - error setting " + block.Name + " of channel " + channel + ": " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	// Valid response is "DsG<oid>*<levelVal>"
+	if strings.Contains(resp, "error") {
+		return resp, errors.New(resp) // device returned an error code
+	}
+	resp = strings.ReplaceAll(resp, `"`, ``)
+	if strings.Contains(resp, "DsG"+oid+"*") && strings.HasSuffix(resp, "*"+levelVal) {
+		return "ok", nil
+	}
+	errMsg := function + " - invalid response for setting " + block.Name + ": " + resp
+	disconnectAfterBadData(socketKey, function)
+	framework.AddToErrors(socketKey, errMsg)
+	return errMsg, errors.New(errMsg)
+}
+
+// DMP gain block mutes, arg1: channel, arg2: true or false
+func setDmpMuteDo(socketKey string, endpoint string, channel string, state string, _ string) (string, error) {
+	function := "setDmpMuteDo"
+
+	block, exists := dmpGainBlocks[endpoint]
+	if !exists {
+		errMsg := function + " - no DMP gain block for endpoint: " + endpoint
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+	var cmdState string
+	switch strings.TrimSpace(strings.Trim(state, `"`)) {
+	case "true":
+		cmdState = "1"
+	case "false":
+		cmdState = "0"
+	default:
+		errMsg := function + " - arg2 must be 'true' or 'false'.  Got: " + state
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+	oid, err := deviceGainBlockNumber(socketKey, block, channel)
+	if err != nil {
+		errMsg := function + " - error calculating object ID: " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	resp, err := deviceTypeDependantCommand(socketKey, "matrixmute", "SET", oid, cmdState, "")
+	if err != nil {
+		errMsg := function + " - error setting " + block.Name + " mute of channel " + channel + ": " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	// Successful response is "DsM<oid>*<1|0>"
+	resp = strings.ReplaceAll(resp, `"`, ``)
+	if strings.Contains(resp, "DsM"+oid+"*"+cmdState) {
+		return "ok", nil
+	}
+	errMsg := function + " - unexpected device response: " + resp
+	disconnectAfterBadData(socketKey, function)
+	framework.AddToErrors(socketKey, errMsg)
+	return errMsg, errors.New(errMsg)
+}
+
+// DMP group masters, arg1: group number or name, arg2: 0-100 percent inside the group's soft limits.
+// 100 is the upper soft limit, never more.
+// Ex: curl -X PUT "http://<containerIP>/telnet|admin:pw@<deviceAddr>/groupvolume/1/60"
+func setGroupVolumeDo(socketKey string, endpoint string, group string, level string, _ string) (string, error) {
+	function := "setGroupVolumeDo"
+
+	number, err := findDmpGroup(socketKey, group, false)
+	if err != nil {
+		errMsg := function + " - " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+	limits, err := readGroupSoftLimits(socketKey, number)
+	if err != nil {
+		errMsg := function + " - error reading soft limits of group " + number + ": " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	level = strings.TrimSpace(strings.Trim(level, `"`))
+	deviceVolume, err := transformVolume(volumeCurveFor(socketKey, "groupvolume"), level, limits.Lower, limits.Upper)
+	if err != nil {
+		errMsg := function + " - error converting percent to device volume: " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	resp, err := deviceTypeDependantCommand(socketKey, "groupvolume", "SET", number, deviceVolume, "")
+	if err != nil {
+		errMsg := function + " - error setting group " + number + " volume: " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+	return checkGroupResponse(socketKey, function, number, deviceVolume, resp)
+}
+
+// DMP group master mutes, arg1: group number or name, arg2: true or false
+func setGroupMuteDo(socketKey string, endpoint string, group string, state string, _ string) (string, error) {
+	function := "setGroupMuteDo"
+
+	var cmdState string
+	switch strings.TrimSpace(strings.Trim(state, `"`)) {
+	case "true":
+		cmdState = "1"
+	case "false":
+		cmdState = "0"
+	default:
+		errMsg := function + " - arg2 must be 'true' or 'false'.  Got: " + state
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+	number, err := findDmpGroup(socketKey, group, true)
+	if err != nil {
+		errMsg := function + " - " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	resp, err := deviceTypeDependantCommand(socketKey, "groupmute", "SET", number, cmdState, "")
+	if err != nil {
+		errMsg := function + " - error setting group " + number + " mute: " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+	value, err := checkGroupResponse(socketKey, function, number, cmdState, resp)
+	if err == nil {
+		rememberDmpGroup(socketKey, number, dmpGroup{Mute: true})
+	}
+	return value, err
+}
+
+// Power Controllers: arg1 is the outlet number or "all", arg2 is true (on) or false (off).
+// Other devices refuse, so a room shutdown doesn't put the switcher to sleep and leave the projector on.
+// Ex: curl -X PUT "http://<containerIP>/telnet|admin:pw@<deviceAddr>/power/3/false"
+func setPowerDo(socketKey string, endpoint string, outlet string, state string, _ string) (string, error) {
+	function := "setPowerDo"
+
+	deviceType, err := findDeviceType(socketKey)
+	if err != nil {
+		errMsg := fmt.Sprintf(function+" - error finding device type: %s", err.Error())
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	if deviceType != "Power Controller" {
+		errMsg := function + " - " + noOutletPower(socketKey, deviceType)
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	state = strings.ReplaceAll(state, "\"", "")
+	state = strings.ReplaceAll(state, "'", "")
+	if state != "true" && state != "false" {
+		errMsg := function + " - power state must be 'true' or 'false'.  Got: " + state
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	outlets, err := findPowerOutlets(socketKey, outlet)
+	if err != nil {
+		errMsg := function + " - " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	stateCmd := "0"
+	if state == "true" {
+		stateCmd = "1"
+	}
+
+	for _, o := range outlets {
+		resp, err := deviceTypeDependantCommand(socketKey, "power", "SET", o, stateCmd, "")
+		if err != nil {
+			errMsg := function + " - error setting power for outlet " + o + ": " + err.Error()
+			framework.AddToErrors(socketKey, errMsg)
+			return errMsg, errors.New(errMsg)
+		}
+
+		// Good response is "Cpn<outlet> Ppc<state>"
+		resp = strings.TrimSpace(strings.ReplaceAll(resp, `"`, ``))
+		if strings.Contains(resp, "error") {
+			return resp, errors.New(resp) // device returned an error code
+		}
+		if !isPortEcho(resp, o, "Ppc", stateCmd) {
+			errMsg := function + " - invalid response for outlet " + o + ": " + resp
+			disconnectAfterBadData(socketKey, function)
+			framework.AddToErrors(socketKey, errMsg)
+			return errMsg, errors.New(errMsg)
+		}
+	}
+	return "ok", nil
+}
+
+// Puts the Extron unit itself in power save (true) or back to normal (false).  Displays are not affected.
+func setPowerSaveDo(socketKey string, endpoint string, state string, _ string, _ string) (string, error) {
+	function := "setPowerSaveDo"
+
+	state = strings.Trim(state, `"'`)
+	if state != "true" && state != "false" {
+		errMsg := function + " - power save must be 'true' or 'false'.  Got: " + state
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+	saveMode := "0"
+	if state == "true" {
+		saveMode = "1"
+	}
+
+	resp, err := deviceTypeDependantCommand(socketKey, "powersave", "SET", saveMode, "", "")
+	if err != nil {
+		errMsg := function + " - error setting power save mode: " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	// Good response is "Psav<mode>"
+	resp = strings.ReplaceAll(resp, `"`, ``)
+	switch {
+	case strings.Contains(resp, "error"):
+		return resp, errors.New(resp) // device returned an error code
+	case resp != "Psav"+saveMode:
+		errMsg := function + " - invalid response for setting power save mode: " + resp
+		disconnectAfterBadData(socketKey, function)
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+	return "ok", nil
+}
+
+// Privacy / blank: mutes video and audio of an output in one call.
+// The video leg is the same as "videomute".  The audio leg is:
+// Matrix Switchers: the audio output mute, IN 160x: the profile's outputMuteGroup, DA's and Switchers: AFMT.
+// Nothing is sent unless the device has an audio leg.  If the audio leg fails the video leg is put back the way it was, sync mute included.
+// Returns how each leg went, ex: {"video":"ok","audio":"ok"}, or {"video":"rolled back","audio":"<error>"}
+func setAudioAndVideoMuteDo(socketKey string, endpoint string, output string, state string, _ string) (string, error) {
+	function := "setAudioAndVideoMuteDo"
+
+	state = strings.ReplaceAll(state, "\"", "")
+	state = strings.ReplaceAll(state, "'", "")
+	if state != "true" && state != "false" {
+		errMsg := function + " - mute state must be 'true' or 'false'.  Got: " + state
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	if err := checkOutputAudioMute(socketKey, output); err != nil {
+		errMsg := function + " - no audio leg, nothing was muted: " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	// Remember the video leg as the device has it, so it can be rolled back
+	previousVideo, err := readVideoMute(socketKey, output)
+	if err != nil {
+		errMsg := function + " - error getting video leg before setting it: " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	_, err = setVideoMuteDo(socketKey, "videomute", output, state, "")
+	if err != nil {
+		errMsg := function + " - video leg failed, audio leg not attempted: " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return avMuteReport(err.Error(), "not attempted"), errors.New(errMsg)
+	}
+
+	_, err = setOutputAudioMute(socketKey, output, state)
+	if err != nil {
+		errMsg := function + " - video leg ok, audio leg failed: " + err.Error()
+		video := "ok"
+		if wanted := map[string]string{"true": "1", "false": "0"}[state]; previousVideo != wanted {
+			_, rollbackErr := writeVideoMute(socketKey, output, previousVideo)
+			if rollbackErr != nil {
+				errMsg += ".  Rolling back the video leg failed: " + rollbackErr.Error()
+				video = "rollback failed: " + rollbackErr.Error()
+			} else {
+				errMsg += ".  Video leg rolled back"
+				video = "rolled back"
+			}
+		}
+		framework.AddToErrors(socketKey, errMsg)
+		return avMuteReport(video, err.Error()), errors.New(errMsg)
+	}
+
+	framework.Log(fmt.Sprintf("%s - %s - output %s video leg ok, audio leg ok", function, socketKey, output))
+	return avMuteReport("ok", "ok"), nil
+}
+
+// What setAudioAndVideoMuteDo did on each leg, as JSON
+func avMuteReport(video string, audio string) string {
+	data, _ := json.Marshal(map[string]string{"video": video, "audio": audio})
+	return string(data)
+}
+
+// Latches a controller relay closed (true) or open (false)
+// Ex: curl -X PUT "http://<containerIP>/telnet|admin:pw@<deviceAddr>/setstate/2/true"
+func setStateDo(socketKey string, endpoint string, relay string, state string, _ string) (string, error) {
+	function := "setStateDo"
+
+	state = strings.ReplaceAll(state, "\"", "")
+	state = strings.ReplaceAll(state, "'", "")
+	if state != "true" && state != "false" {
+		errMsg := function + " - relay state must be 'true' (closed) or 'false' (open).  Got: " + state
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+	stateCmd := "0"
+	if state == "true" {
+		stateCmd = "1"
+	}
+
+	relay, err := findRelay(socketKey, relay)
+	if err != nil {
+		errMsg := function + " - " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	resp, err := deviceTypeDependantCommand(socketKey, "setstate", "SET", relay, stateCmd, "")
+	if err != nil {
+		errMsg := function + " - error setting relay " + relay + ": " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	if strings.Contains(resp, "error") {
+		return resp, errors.New(resp) // device returned an error code
+	}
+
+	// Good response is "Cpn<relay> Rly<state>", the relay number may be zero padded
+	resp = strings.ReplaceAll(resp, `"`, ``)
+	if !isPortEcho(resp, relay, "Rly", stateCmd) {
+		errMsg := function + " - invalid response for setting relay " + relay + ": " + resp
+		disconnectAfterBadData(socketKey, function)
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	// Confirm
+	readBack, err := readRelayState(socketKey, relay)
+	if err != nil {
+		errMsg := function + " - error reading relay " + relay + " back: " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+	if readBack != state {
+		errMsg := function + " - relay " + relay + " reads back " + readBack + " after setting it to " + state
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+	return "ok", nil
+}
+
+// Pulses a controller relay for relayPulseDuration, ex: a screen or lift control input
+func triggerStateDo(socketKey string, endpoint string, relay string, _ string, _ string) (string, error) {
+	return pulseRelay(socketKey, "triggerStateDo", relay, relayPulseDuration)
+}
+
+// Pulses a controller relay for a number of seconds, up to maxRelayPulse
+// Ex: curl -X PUT "http://<containerIP>/telnet|admin:pw@<deviceAddr>/timedtriggerstate/2/1.5"
+func timedTriggerStateDo(socketKey string, endpoint string, relay string, seconds string, _ string) (string, error) {
+	function := "timedTriggerStateDo"
+
+	seconds = strings.Trim(seconds, `"'`)
+	secondsFloat, err := strconv.ParseFloat(seconds, 64)
+	duration := time.Duration(secondsFloat * float64(time.Second))
+	if err != nil || duration < 20*time.Millisecond || duration > maxRelayPulse {
+		errMsg := fmt.Sprintf("%s - duration must be 0.02 to %g seconds.  Got: %s", function, maxRelayPulse.Seconds(), seconds)
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+	return pulseRelay(socketKey, function, relay, duration)
+}
+
+// Recalls a global preset by number, or by name for room modes, ex: "lecture" or "divided"
+func setPresetDo(socketKey string, endpoint string, preset string, _ string, _ string) (string, error) {
+	function := "setPresetDo"
+
+	preset, err := findPreset(socketKey, preset)
+	if err != nil {
+		errMsg := function + " - " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	resp, err := deviceTypeDependantCommand(socketKey, "preset", "SET", preset, "", "")
+	if err != nil {
+		errMsg := function + " - error recalling preset " + preset + ": " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	// Good response is "Rpr<preset>", the preset number may be zero padded
+	resp, err = checkPresetResponse(socketKey, function, "Rpr", preset, resp)
+	if err != nil {
+		return resp, err
+	}
+	ev := deviceEvent{SocketKey: socketKey, Kind: valueChanged, Source: "set", Endpoint: "preset", State: preset, Time: time.Now()}
+	if updateShadow(ev) {
+		publishDeviceEvent(ev)
+	}
+	return resp, nil
+}
+
+// Saves the current ties to a global preset.  Matrix switchers and scalers only.
+func savePresetDo(socketKey string, endpoint string, preset string, _ string, _ string) (string, error) {
+	function := "savePresetDo"
+
+	preset = strings.Trim(preset, `"'`)
+	presetNum, err := strconv.Atoi(preset)
+	if err != nil || presetNum < 1 {
+		errMsg := function + " - preset must be a number, 1 or more.  Got: " + preset
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+	preset = strconv.Itoa(presetNum)
+
+	resp, err := deviceTypeDependantCommand(socketKey, "savepreset", "SET", preset, "", "")
+	if err != nil {
+		errMsg := function + " - error saving preset " + preset + ": " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	deviceTablesMutex.Lock()
+	delete(presetNames, socketKey) // an unassigned preset gets a name when it's saved
+	deviceTablesMutex.Unlock()
+
+	// Good response is "Spr<preset>"
+	return checkPresetResponse(socketKey, function, "Spr", preset, resp)
+}
+
+func setInputNameDo(socketKey string, endpoint string, input string, name string, _ string) (string, error) {
+	return setIONameDo(socketKey, "setInputNameDo", "inputname", input, name)
+}
+
+func setOutputNameDo(socketKey string, endpoint string, output string, name string, _ string) (string, error) {
+	return setIONameDo(socketKey, "setOutputNameDo", "outputname", output, name)
+}
+
+// Turns HDCP authorization for an input on or off.  Off lets a source that doesn't need HDCP
+// send unencrypted video, ex: so it can be recorded or streamed.
+func setHDCPAuthorizationDo(socketKey string, endpoint string, input string, state string, _ string) (string, error) {
+	function := "setHDCPAuthorizationDo"
+
+	state = strings.Trim(state, `"'`)
+	if state != "true" && state != "false" {
+		errMsg := function + " - state must be 'true' or 'false'.  Got: " + state
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+	stateCmd := "0"
+	if state == "true" {
+		stateCmd = "1"
+	}
+
+	input, err := findIONumber(socketKey, "input", input)
+	if err != nil {
+		errMsg := function + " - " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	resp, err := deviceTypeDependantCommand(socketKey, "hdcpauthorization", "SET", input, stateCmd, "")
+	if err != nil {
+		errMsg := function + " - error setting HDCP authorization for input " + input + ": " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	// Good response is "HdcpE<input>*<state>"
+	resp = strings.TrimSpace(strings.ReplaceAll(resp, `"`, ``))
+	echoInput, echoState, _ := strings.Cut(strings.TrimPrefix(resp, "HdcpE"), "*")
+	switch {
+	case strings.Contains(resp, "E10") || strings.Contains(resp, "E14"):
+		errMsg := function + " - this model does not support HDCP authorization: " + resp
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	case strings.Contains(resp, "error"):
+		return resp, errors.New(resp) // device returned an error code
+	case strings.HasPrefix(resp, "HdcpE") && trimZeros(echoInput) == input && echoState == stateCmd:
+		return "ok", nil
+	default:
+		errMsg := function + " - invalid response for HDCP authorization of input " + input + ": " + resp
+		disconnectAfterBadData(socketKey, function)
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+}
+
+// Assigns an EDID to an input: a slot from edidpresets, or "outputfollow" and an output
+// to pass through the EDID of the display on that output.
+// Ex: curl -X PUT "http://<containerIP>/telnet|admin:pw@<deviceAddr>/edid/2/outputfollow/1"
+func setEDIDDo(socketKey string, endpoint string, input string, slot string, output string) (string, error) {
+	function := "setEDIDDo"
+
+	input, err := findIONumber(socketKey, "input", input)
+	if err != nil {
+		errMsg := function + " - " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	slot = strings.Trim(slot, `"'`)
+	if strings.EqualFold(slot, "outputfollow") {
+		output, err = findIONumber(socketKey, "output", output)
+		if err != nil {
+			errMsg := function + " - " + err.Error()
+			framework.AddToErrors(socketKey, errMsg)
+			return errMsg, errors.New(errMsg)
+		}
+		edids, err := readEDIDTable(socketKey, output)
+		if err != nil {
+			errMsg := function + " - " + err.Error()
+			framework.AddToErrors(socketKey, errMsg)
+			return errMsg, errors.New(errMsg)
+		}
+		if len(edids) == 0 || edids[len(edids)-1].FollowsOutput != output {
+			errMsg := function + " - this device has no EDID slot that follows output " + output
+			framework.AddToErrors(socketKey, errMsg)
+			return errMsg, errors.New(errMsg)
+		}
+		slot = edids[len(edids)-1].Slot
+	}
+	slotNum, err := strconv.Atoi(slot)
+	if err != nil || slotNum < 1 {
+		errMsg := function + " - EDID must be a slot number or 'outputfollow'.  Got: " + slot
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+	slot = strconv.Itoa(slotNum)
+
+	resp, err := deviceTypeDependantCommand(socketKey, "edid", "SET", input, slot, "")
+	if err != nil {
+		errMsg := function + " - error setting EDID of input " + input + ": " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	// Good response is "EdidA<input>*<slot>"
+	return checkEDIDResponse(socketKey, function, "EdidA", input, slot, resp)
+}
+
+// EDID Minder: saves the EDID of the display on an output to a user slot, which can then be assigned to inputs.
+// For replacing a display without a trip to the room.
+func setEDIDCaptureDo(socketKey string, endpoint string, output string, slot string, _ string) (string, error) {
+	function := "setEDIDCaptureDo"
+
+	output, err := findIONumber(socketKey, "output", output)
+	if err != nil {
+		errMsg := function + " - " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+	slot = strings.Trim(slot, `"'`)
+	slotNum, err := strconv.Atoi(slot)
+	if err != nil || slotNum < 1 {
+		errMsg := function + " - EDID user slot must be a number.  Got: " + slot
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+	slot = strconv.Itoa(slotNum)
+
+	resp, err := deviceTypeDependantCommand(socketKey, "edidcapture", "SET", output, slot, "")
+	if err != nil {
+		errMsg := function + " - error capturing EDID from output " + output + ": " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	deviceTablesMutex.Lock()
+	delete(edidTables, socketKey) // the slot's description is the captured display's now
+	deviceTablesMutex.Unlock()
+
+	// Good response is "EdidS<output>*<slot>"
+	return checkEDIDResponse(socketKey, function, "EdidS", output, slot, resp)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// Helper functions //
+///////////////////////////////////////////////////////////////////////////////
+
+func isEven(n int) bool {
+	return n%2 == 0
+}
+
+var powerOutletCount = regexp.MustCompile(`PCS?(\d+)`) // "IPL T PCS4" has 4 outlets, "IPL T PC1" has 1
+
+// Why power doesn't work on a device without outlets
+func noOutletPower(socketKey string, deviceType string) string {
+	return fmt.Sprintf("power switches outlets on IPL T power controllers, %s (%s) has no display power control.  "+
+		"powersave puts the Extron unit itself in power save", deviceModel(socketKey), deviceType)
+}
+
+// Returns the outlets to act on for a Power Controller.  "" or "all" means every outlet on the model.
+func findPowerOutlets(socketKey string, outlet string) ([]string, error) {
+	model, err := findModelName(socketKey)
+	if err != nil {
+		return nil, errors.New("can not find model for: " + socketKey)
+	}
+	match := powerOutletCount.FindStringSubmatch(model)
+	if match == nil {
+		return nil, errors.New("unknown number of outlets on model: " + model)
+	}
+	count, _ := strconv.Atoi(match[1])
+
+	outlet = strings.Trim(outlet, `"'`)
+	if outlet == "" || strings.ToLower(outlet) == "all" {
+		outlets := make([]string, 0, count)
+		for i := 1; i <= count; i++ {
+			outlets = append(outlets, strconv.Itoa(i))
+		}
+		return outlets, nil
+	}
+
+	outletNum, err := strconv.Atoi(outlet)
+	if err != nil || outletNum < 1 || outletNum > count {
+		return nil, fmt.Errorf("outlet must be 1-%d or 'all' on model %s, got: %s", count, model, outlet)
+	}
+	return []string{strconv.Itoa(outletNum)}, nil
+}
+
+// Checks the relay number against the model and returns it without padding
+func findRelay(socketKey string, relay string) (string, error) {
+	deviceType, err := findDeviceType(socketKey)
+	if err != nil {
+		return "", errors.New("error finding device type: " + err.Error())
+	}
+	if deviceType != "Controller" {
+		return "", errors.New("relays are only supported on controllers, device type is: " + deviceType)
+	}
+
+	model, err := findModelName(socketKey)
+	if err != nil {
+		return "", errors.New("can not find model for: " + socketKey)
+	}
+	count := 0
+	for name, relays := range controllerRelayCounts {
+		if strings.Contains(model, name) {
+			count = relays
+			break
+		}
+	}
+	if count == 0 {
+		return "", errors.New("unknown number of relays on model: " + model)
+	}
+
+	relay = strings.Trim(relay, `"'`)
+	relayNum, err := strconv.Atoi(relay)
+	if err != nil || relayNum < 1 || relayNum > count {
+		return "", fmt.Errorf("relay must be 1-%d on model %s, got: %s", count, model, relay)
+	}
+	return strconv.Itoa(relayNum), nil
+}
+
+// Returns "true" (closed) or "false" (open)
+func readRelayState(socketKey string, relay string) (string, error) {
+	resp, err := deviceTypeDependantCommand(socketKey, "setstate", "GET", relay, "", "")
+	if err != nil {
+		return "", errors.New("error getting relay " + relay + ": " + err.Error())
+	}
+
+	// Good response is "1" (closed) or "0" (open)
+	resp = strings.TrimSpace(strings.ReplaceAll(resp, `"`, ``))
+	state, err := stringIntToStringBool(socketKey, resp)
+	if err != nil {
+		disconnectAfterBadData(socketKey, "readRelayState")
+		return "", errors.New("invalid response for relay " + relay + ": " + resp)
+	}
+	return state, nil
+}
+
+// "Cpn<port> <kind><state>", ex: "Cpn02 Rly1" for a relay or "Cpn3 Ppc0" for an outlet.  Any state when state is ""
+func isPortEcho(resp string, port string, kind string, state string) bool {
+	fields := strings.Fields(resp)
+	if len(fields) != 2 || !strings.HasPrefix(fields[0], "Cpn") || trimZeros(strings.TrimPrefix(fields[0], "Cpn")) != port {
+		return false
+	}
+	if state == "" {
+		return strings.HasPrefix(fields[1], kind)
+	}
+	return fields[1] == kind+state
+}
+
+// Pulses relay for duration, then confirms it went back to where it was.
+// The device does the timing; this waits out the pulse so the read back means something.
+func pulseRelay(socketKey string, function string, relay string, duration time.Duration) (string, error) {
+	relay, err := findRelay(socketKey, relay)
+	if err != nil {
+		errMsg := function + " - " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	before, err := readRelayState(socketKey, relay)
+	if err != nil {
+		errMsg := function + " - " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	ticks := int(duration / (20 * time.Millisecond)) // pulse length is in 20ms ticks
+	if ticks < 1 {
+		ticks = 1
+	}
+	if ticks > 65535 {
+		ticks = 65535
+	}
+
+	resp, err := deviceTypeDependantCommand(socketKey, "triggerstate", "SET", relay, strconv.Itoa(ticks), "")
+	if err != nil {
+		errMsg := function + " - error pulsing relay " + relay + ": " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	if strings.Contains(resp, "error") {
+		return resp, errors.New(resp) // device returned an error code
+	}
+
+	// Good response is "Cpn<relay> Rly<state>", the state during the pulse
+	resp = strings.ReplaceAll(resp, `"`, ``)
+	if !isPortEcho(resp, relay, "Rly", "") {
+		errMsg := function + " - invalid response for pulsing relay " + relay + ": " + resp
+		disconnectAfterBadData(socketKey, function)
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	time.Sleep(time.Duration(ticks)*20*time.Millisecond + 100*time.Millisecond)
+
+	after, err := readRelayState(socketKey, relay)
+	if err != nil {
+		errMsg := function + " - error reading relay " + relay + " back: " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+	if after != before {
+		errMsg := function + " - relay " + relay + " reads back " + after + " after the pulse, it was " + before
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+	return "ok", nil
+}
+
+// Input tied to each output, in output order, for video ("1") or audio ("2").
+// The device answers for up to 16 outputs at a time, so larger matrixes take more than one command.
+func readMatrixTies(socketKey string, media string, outputs int) ([]string, error) {
+	var ties []string
+	for {
+		resp, err := deviceTypeDependantCommand(socketKey, "matrixties", "GET", strconv.Itoa(len(ties)+1), media, "")
+		if err != nil {
+			return nil, err
+		}
+
+		// Good response is the input for each output, optionally followed by the media, ex: "02 01 04 04 Vid"
+		resp = strings.ReplaceAll(resp, `"`, ``)
+		got := 0
+		for _, field := range strings.Fields(resp) {
+			if _, err := strconv.Atoi(field); err != nil {
+				if field == "Vid" || field == "Aud" {
+					continue
+				}
+				disconnectAfterBadData(socketKey, "readMatrixTies")
+				return nil, errors.New("invalid response for ties: " + resp)
+			}
+			ties = append(ties, trimZeros(field))
+			got++
+		}
+		if got == 0 {
+			return nil, errors.New("no ties in response: " + resp)
+		}
+		if got < 16 || len(ties) >= outputs {
+			return ties, nil
+		}
+	}
+}
+
+// A preset number from a number or a preset name (case-insensitive, the lowest numbered match wins)
+func findPreset(socketKey string, preset string) (string, error) {
+	preset = strings.Trim(preset, `"'`)
+	if presetNum, err := strconv.Atoi(preset); err == nil {
+		if presetNum < 1 {
+			return "", errors.New("preset must be 1 or more, got: " + preset)
+		}
+		return strconv.Itoa(presetNum), nil
+	}
+
+	names, err := readPresetNames(socketKey)
+	if err != nil {
+		return "", errors.New("can't look up preset '" + preset + "' by name: " + err.Error())
+	}
+	for i, name := range names {
+		if strings.EqualFold(name, preset) {
+			return strconv.Itoa(i + 1), nil
+		}
+	}
+	return "", errors.New("no preset named: " + preset)
+}
+
+// Global preset names in preset order.  Asks for every preset the profile says the model has,
+// stopping early if the device runs out first (E11).  Kept per socketKey like the EDID table.
+func readPresetNames(socketKey string) ([]string, error) {
+	deviceTablesMutex.Lock()
+	cached, exists := presetNames[socketKey]
+	deviceTablesMutex.Unlock()
+	if exists {
+		return cached, nil
+	}
+
+	count := defaultPresetCount
+	if profile := profileForModel(socketKey, deviceModel(socketKey)); profile != nil && profile.Presets > 0 {
+		count = profile.Presets
+	}
+
+	names := make([]string, 0, count)
+	for presetNum := 1; presetNum <= count; presetNum++ {
+		preset := strconv.Itoa(presetNum)
+		resp, err := deviceTypeDependantCommand(socketKey, "presets", "GET", preset, "", "")
+		if err != nil {
+			return nil, errors.New("error getting preset " + preset + " name: " + err.Error())
+		}
+		resp = strings.ReplaceAll(resp, `"`, ``)
+		if strings.Contains(resp, "E11") {
+			break // no more presets
+		}
+		if strings.Contains(resp, "error") {
+			return nil, errors.New("error getting preset " + preset + " name: " + resp)
+		}
+		names = append(names, strings.TrimSpace(resp))
+	}
+
+	deviceTablesMutex.Lock()
+	presetNames[socketKey] = names
+	deviceTablesMutex.Unlock()
+	return names, nil
+}
+
+// Checks a preset recall or save answered with prefix (Rpr or Spr) and the preset number.
+// E11 gets its own message, it's the one a config writer will actually see.
+func checkPresetResponse(socketKey string, function string, prefix string, preset string, resp string) (string, error) {
+	resp = strings.TrimSpace(strings.ReplaceAll(resp, `"`, ``))
+	switch {
+	case strings.Contains(resp, "E11"):
+		errMsg := function + " - preset " + preset + " does not exist on this device (E11: Invalid preset number)"
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	case strings.Contains(resp, "error"):
+		return resp, errors.New(resp) // device returned an error code
+	case strings.HasPrefix(resp, prefix) && trimZeros(strings.TrimPrefix(resp, prefix)) == preset:
+		return "ok", nil
+	default:
+		errMsg := function + " - invalid response for preset " + preset + ": " + resp
+		disconnectAfterBadData(socketKey, function)
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+}
+
+// inputname and outputname GET
+func getIONameDo(socketKey string, function string, endpoint string, number string) (string, error) {
+	number, err := findIONumber(socketKey, strings.TrimSuffix(endpoint, "name"), number)
+	if err != nil {
+		errMsg := function + " - " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	name, err := readIOName(socketKey, endpoint, number)
+	if err != nil {
+		errMsg := function + " - " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+	return `"` + name + `"`, nil
+}
+
+// inputname and outputname SET.  The name is checked against the device's limits first,
+// a name the device rejects or cuts short is worse than an error here.
+func setIONameDo(socketKey string, function string, endpoint string, number string, name string) (string, error) {
+	kind := strings.TrimSuffix(endpoint, "name")
+
+	number, err := findIONumber(socketKey, kind, number)
+	if err != nil {
+		errMsg := function + " - " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	deviceType, err := findDeviceType(socketKey)
+	if err != nil {
+		errMsg := fmt.Sprintf(function+" - error finding device type: %s", err.Error())
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+	name = strings.TrimSpace(strings.Trim(name, `"'`))
+	if err := checkIOName(deviceType, name); err != nil {
+		errMsg := function + " - invalid " + kind + " name: " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	resp, err := deviceTypeDependantCommand(socketKey, endpoint, "SET", number, name, "")
+	if err != nil {
+		errMsg := function + " - error naming " + kind + " " + number + ": " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
+	// Good response is "Nmi<input>,<name>" or "Nmo<output>,<name>"
+	resp = strings.ReplaceAll(resp, `"`, ``)
+	prefix := "Nm" + kind[:1]
+	echoNumber, echoName, _ := strings.Cut(strings.TrimPrefix(resp, prefix), ",")
+	switch {
+	case strings.Contains(resp, "error"):
+		return resp, errors.New(resp) // device returned an error code
+	case strings.HasPrefix(resp, prefix) && trimZeros(echoNumber) == number && echoName == name:
+		return "ok", nil
+	default:
+		errMsg := function + " - invalid response for naming " + kind + " " + number + ": " + resp
+		disconnectAfterBadData(socketKey, function)
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+}
+
+func readIOName(socketKey string, endpoint string, number string) (string, error) {
+	kind := strings.TrimSuffix(endpoint, "name")
+
+	resp, err := deviceTypeDependantCommand(socketKey, endpoint, "GET", number, "", "")
+	if err != nil {
+		return "", errors.New("error getting " + kind + " " + number + " name: " + err.Error())
+	}
+	resp = strings.ReplaceAll(resp, `"`, ``)
+	if strings.Contains(resp, "error") {
+		return "", errors.New("error getting " + kind + " " + number + " name: " + resp)
+	}
+	return strings.TrimSpace(resp), nil
+}
+
+// The input or output number SIS commands take (kind is "input" or "output").
+// Outputs can be given by their profile name, "3A" is output 3.
+func findIONumber(socketKey string, kind string, number string) (string, error) {
+
+	number = strings.Trim(number, `"'`)
+	if kind == "output" {
+		number = strings.TrimRight(number, "AB")
+	}
+	num, err := strconv.Atoi(number)
+	if err != nil || num < 1 {
+		return "", errors.New(kind + " must be a number, 1 or more.  Got: " + number)
+	}
+
+	numbers, err := ioNumbers(socketKey, kind)
+	if err != nil {
+		framework.Log("findIONumber - " + socketKey + " - not checking the " + kind + " number: " + err.Error())
+		return strconv.Itoa(num), nil // let the device decide
+	}
+	for _, known := range numbers {
+		if known == num {
+			return strconv.Itoa(num), nil
+		}
+	}
+	return "", fmt.Errorf("%s %d does not exist on this device, it has %d", kind, num, len(numbers))
+}
+
+// The input or output numbers of a device, in order (kind is "input" or "output")
+func ioNumbers(socketKey string, kind string) ([]int, error) {
+
+	deviceType, err := findDeviceType(socketKey)
+	if err != nil {
+		return nil, errors.New("error finding device type: " + err.Error())
+	}
+	model, err := findModelName(socketKey)
+	if err != nil {
+		return nil, errors.New("can not find model for: " + socketKey)
+	}
+
+	if deviceType == "Audio Processor" { // DMP's don't report a size (see discovery.go)
+		dmp, err := findDmpModel(model)
+		if err != nil {
+			return nil, err
+		}
+		count := dmp.Outputs
+		if kind == "input" {
+			count = dmp.MicInputs
+		}
+		numbers := make([]int, count)
+		for i := range numbers {
+			numbers[i] = i + 1
+		}
+		return numbers, nil
+	}
+
+	inMap, outMap := ioMaps(socketKey, profileForModel(socketKey, model))
+	names := inMap
+	if kind == "output" {
+		names = outMap
+	}
+	if len(names) == 0 {
+		return nil, errors.New("unknown number of " + kind + "s on model: " + model)
+	}
+
+	seen := make(map[int]bool, len(names))
+	var numbers []int
+	for name := range names {
+		num, err := strconv.Atoi(strings.TrimRight(name, "AB")) // "3A" and "3B" are both output 3
+		if err != nil || seen[num] {
+			continue
+		}
+		seen[num] = true
+		numbers = append(numbers, num)
+	}
+	sort.Ints(numbers)
+	return numbers, nil
+}
+
+// Name limits for a device type: length, printable ASCII and no SIS delimiters
+func checkIOName(deviceType string, name string) error {
+	if name == "" {
+		return errors.New("name is empty")
+	}
+	if maxLength, exists := maxNameLengths[deviceType]; exists && len(name) > maxLength {
+		return fmt.Errorf("%q is %d characters, the limit is %d", name, len(name), maxLength)
+	}
+	for _, c := range name {
+		if c < ' ' || c > '~' {
+			return fmt.Errorf("%q has a character that isn't printable ASCII", name)
+		}
+		if strings.ContainsRune(invalidNameCharacters, c) {
+			return fmt.Errorf("%q has %q, names can't use any of %s", name, c, invalidNameCharacters)
+		}
+	}
+	return nil
+}
+
+type hdcpInputStatus struct {
+	Input         string `json:"input"`
+	SourcePresent bool   `json:"sourcePresent"`
+	HDCP          string `json:"hdcp"`                 // none, 1.4, 2.x or "not authorized"
+	Authorized    *bool  `json:"authorized,omitempty"` // left out on models without HDCP authorization
+}
+
+type hdcpOutputStatus struct {
+	Output        string `json:"output"`
+	SinkConnected bool   `json:"sinkConnected"`
+	HDCP          string `json:"hdcp"` // none, 1.4 or 2.x
+}
+
+func readHDCPInputStatus(socketKey string, input string) (*hdcpInputStatus, error) {
+	code, err := readHDCPCode(socketKey, "hdcpinputstatus", "input", input)
+	if err != nil {
+		return nil, err
+	}
+	status := &hdcpInputStatus{Input: input, SourcePresent: code.Present, HDCP: code.HDCP}
+
+	authorized, err := readHDCPAuthorization(socketKey, input)
+	if errors.Is(err, errHDCPAuthorizationNotSupported) {
+		return status, nil // leave authorized out
+	}
+	if err != nil {
+		return nil, err
+	}
+	status.Authorized = &authorized
+	if !authorized && status.SourcePresent {
+		status.HDCP = "not authorized" // the source was told this input can't decrypt
+	}
+	return status, nil
+}
+
+func readHDCPOutputStatus(socketKey string, output string) (*hdcpOutputStatus, error) {
+	code, err := readHDCPCode(socketKey, "hdcpoutputstatus", "output", output)
+	if err != nil {
+		return nil, err
+	}
+	return &hdcpOutputStatus{Output: output, SinkConnected: code.Present, HDCP: code.HDCP}, nil
+}
+
+// One input or output HDCP status digit, looked up in hdcpStatusCodes
+func readHDCPCode(socketKey string, endpoint string, kind string, number string) (hdcpCode, error) {
+	code := hdcpStatusCodes["0"]
+	resp, err := deviceTypeDependantCommand(socketKey, endpoint, "GET", number, "", "")
+	if err != nil {
+		return code, errors.New("error getting HDCP status of " + kind + " " + number + ": " + err.Error())
+	}
+	resp = strings.TrimSpace(strings.ReplaceAll(resp, `"`, ``))
+	if strings.Contains(resp, "error") {
+		return code, errors.New("error getting HDCP status of " + kind + " " + number + ": " + resp)
+	}
+	code, ok := hdcpStatusCodes[resp]
+	if !ok {
+		disconnectAfterBadData(socketKey, "readHDCPCode")
+		return code, errors.New("invalid HDCP status for " + kind + " " + number + ": " + resp)
+	}
+	return code, nil
+}
+
+// E10 or E14 to the authorization command: the model can't be asked, which is not a failure
+var errHDCPAuthorizationNotSupported = errors.New("this model does not support HDCP authorization")
+
+func readHDCPAuthorization(socketKey string, input string) (bool, error) {
+	resp, err := deviceTypeDependantCommand(socketKey, "hdcpauthorization", "GET", input, "", "")
+	if err != nil {
+		return false, errors.New("error getting HDCP authorization of input " + input + ": " + err.Error())
+	}
+	resp = strings.TrimSpace(strings.ReplaceAll(resp, `"`, ``))
+	switch {
+	case strings.Contains(resp, "E10") || strings.Contains(resp, "E14"):
+		return false, errHDCPAuthorizationNotSupported
+	case strings.Contains(resp, "error"):
+		return false, errors.New("error getting HDCP authorization of input " + input + ": " + resp)
+	case resp == "1":
+		return true, nil
+	case resp == "0":
+		return false, nil
+	default:
+		disconnectAfterBadData(socketKey, "readHDCPAuthorization")
+		return false, errors.New("invalid HDCP authorization for input " + input + ": " + resp)
+	}
+}
+
+// Puts one port's HDCP status in the device shadow, for getHDCPStatusDo
+func recordHDCPStatus(socketKey string, endpoint string, port string, status string) {
+	ev := deviceEvent{SocketKey: socketKey, Kind: valueChanged, Source: "get", Endpoint: endpoint,
+		Output: port, State: status, Time: time.Now()}
+	if updateShadow(ev) {
+		publishDeviceEvent(ev)
+	}
+}
+
+type edidInfo struct {
+	Slot          string  `json:"slot"`
+	Description   string  `json:"description"` // as the device reports it
+	Resolution    string  `json:"resolution,omitempty"`
+	RefreshRate   float64 `json:"refreshRate,omitempty"`   // Hz
+	Audio         string  `json:"audio,omitempty"`         // 2ch, multichannel or lpcm
+	FollowsOutput string  `json:"followsOutput,omitempty"` // output follow slots: the output whose display EDID is passed through
+}
+
+// Reads the EDID table from slot 1 until the device runs out of slots (or maxEDIDSlots).
+// With stopAtFollow set, stops at the slot following that output instead.
+// A whole table is kept per socketKey, so it's only read again after a reconnect or a reconfigure.
+func readEDIDTable(socketKey string, stopAtFollow string) ([]*edidInfo, error) {
+	deviceTablesMutex.Lock()
+	cached, exists := edidTables[socketKey]
+	deviceTablesMutex.Unlock()
+	if exists {
+		for i, edid := range cached {
+			if stopAtFollow != "" && edid.FollowsOutput == stopAtFollow {
+				return cached[:i+1], nil
+			}
+		}
+		return cached, nil
+	}
+
+	var edids []*edidInfo
+	for slot := 1; slot <= maxEDIDSlots; slot++ {
+		edid, err := readEDID(socketKey, strconv.Itoa(slot))
+		if errors.Is(err, errNoEDIDSlot) && len(edids) > 0 {
+			break // past the last slot
+		}
+		if err != nil {
+			return nil, err
+		}
+		edids = append(edids, edid)
+		if stopAtFollow != "" && edid.FollowsOutput == stopAtFollow {
+			return edids, nil // not the whole table, don't keep it
+		}
+	}
+
+	deviceTablesMutex.Lock()
+	edidTables[socketKey] = edids
+	deviceTablesMutex.Unlock()
+	return edids, nil
+}
+
+// Drops the tables kept for socketKey, and its discovered size.  Called on a new login, after bad data and when the device reports a reconfigure.
+func forgetDeviceTables(socketKey string) {
+	forgetIOSize(socketKey)
+
+	deviceTablesMutex.Lock()
+	defer deviceTablesMutex.Unlock()
+
+	delete(edidTables, socketKey)
+	delete(presetNames, socketKey)
+}
+
+// E13 to an EDID slot: the slot doesn't exist, the table ends before it.  Any other error code is a failure
+var errNoEDIDSlot = errors.New("no such EDID slot")
+
+func readEDID(socketKey string, slot string) (*edidInfo, error) {
+	resp, err := deviceTypeDependantCommand(socketKey, "edidpresets", "GET", slot, "", "")
+	if err != nil {
+		return nil, errors.New("error getting EDID " + slot + ": " + err.Error())
+	}
+	resp = strings.TrimSpace(strings.ReplaceAll(resp, `"`, ``))
+	switch {
+	case strings.Contains(resp, "E13"):
+		return nil, fmt.Errorf("EDID %s: %w", slot, errNoEDIDSlot)
+	case strings.Contains(resp, "error"):
+		return nil, errors.New("error getting EDID " + slot + ": " + resp)
+	}
+	return parseEDIDDescription(slot, resp), nil
+}
+
+// Pulls resolution, refresh rate and audio format out of an EDID description, ex: "1920x1080 @60Hz 2ch"
+func parseEDIDDescription(slot string, description string) *edidInfo {
+	edid := &edidInfo{Slot: slot, Description: description}
+	if m := edidFollowPattern.FindStringSubmatch(description); m != nil {
+		edid.FollowsOutput = trimZeros(m[1])
+		return edid
+	}
+	if m := edidResolutionPattern.FindStringSubmatch(description); m != nil {
+		edid.Resolution = m[1] + "x" + m[2]
+	}
+	if m := edidRefreshPattern.FindStringSubmatch(description); m != nil {
+		edid.RefreshRate, _ = strconv.ParseFloat(m[1], 64)
+	}
+	if m := edidAudioPattern.FindStringSubmatch(description); m != nil {
+		switch audio := strings.ToLower(strings.ReplaceAll(m[1], " ", "")); {
+		case audio == "2ch" || audio == "lpcm":
+			edid.Audio = audio
+		default:
+			edid.Audio = "multichannel"
+		}
+	}
+	return edid
+}
+
+// Checks an EDID Minder echo: prefix (EdidA or EdidS), then "<port>*<slot>", both may be zero padded
+func checkEDIDResponse(socketKey string, function string, prefix string, port string, slot string, resp string) (string, error) {
+	resp = strings.TrimSpace(strings.ReplaceAll(resp, `"`, ``))
+	echoPort, echoSlot, _ := strings.Cut(strings.TrimPrefix(resp, prefix), "*")
+	switch {
+	case strings.Contains(resp, "E13"):
+		errMsg := function + " - EDID slot " + slot + " does not exist or can't be used here (E13: Invalid value)"
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	case strings.Contains(resp, "error"):
+		return resp, errors.New(resp) // device returned an error code
+	case strings.HasPrefix(resp, prefix) && trimZeros(echoPort) == port && trimZeros(echoSlot) == slot:
+		return "ok", nil
+	default:
+		errMsg := function + " - invalid EDID response: " + resp
+		disconnectAfterBadData(socketKey, function)
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+}
+
+// A DMP group number from arg1: the number itself, or a name from the profile's muteGroups (mute) or volumeGroups.
+// Checked against the number of groups the model has.  A group's value doesn't tell a mute group from a gain group
+// sitting at 0.0 or +0.1 dB, so mute groups must be listed in the profile's muteGroups, and those are not gain groups.
+func findDmpGroup(socketKey string, group string, mute bool) (string, error) {
+	model, err := findModelName(socketKey)
+	if err != nil {
+		return "", errors.New("can not find model for: " + socketKey)
+	}
+	dmp, err := findDmpModel(model)
+	if err != nil {
+		return "", err
+	}
 
-	resp, err := deviceTypeDependantCommand(socketKey, "audioandvideoroute", "SET", input, output, "")
+	profile := profileForModel(socketKey, model)
+	if mute && profile == nil {
+		return "", fmt.Errorf("no profile for %s at %s, mute groups must be listed in the muteGroups of a profile for the model or, per room, one with this device in devices", model, deviceAddress(socketKey))
+	}
+	if profile != nil {
+		names := profile.VolumeGroups
+		if mute {
+			names = profile.MuteGroups
+		}
+		if number, exists := names[group]; exists {
+			group = number
+		}
+	}
+	num, err := strconv.Atoi(group)
 	if err != nil {
-		errMsg := function + "- error setting audio and video route: " + err.Error()
-		framework.AddToErrors(socketKey, errMsg)
-		return errMsg, errors.New(errMsg)
+		return "", fmt.Errorf("group %s is not a group number or a known group name", group)
+	}
+	if num < 1 || num > dmp.Groups {
+		return "", fmt.Errorf("group %d is out of range, %s has groups 1-%d", num, dmp.Name, dmp.Groups)
 	}
 
-	// Matrix good response: "Out4 In2 All"
-	// Scaler good response: "In02 All"
+	number := strconv.Itoa(num)
+	isMuteGroup := false
+	if profile != nil {
+		_, isMuteGroup = groupName(profile.MuteGroups, number)
+	}
 	switch {
-	case strings.Contains(resp, "error"):
-		return resp, errors.New(resp) // device returned an error code
-	case strings.Contains(resp, "In") && strings.Contains(resp, input) && strings.Contains(resp, "All"):
-		return "ok", nil // return with confidence
-	case strings.Contains(resp, input): // some scalers
-		return "ok", nil // less confident
-	default:
-		disconnectAfterBadData(socketKey, function)
-		return "unknown response: " + resp, nil
+	case mute && !isMuteGroup:
+		return "", fmt.Errorf("group %s is not one of the muteGroups of profile %s", number, profile.Name)
+	case !mute && isMuteGroup:
+		return "", fmt.Errorf("group %s is a mute group (muteGroups of profile %s)", number, profile.Name)
 	}
+	return number, nil
 }
 
-func setVideoMuteDo(socketKey string, endpoint string, output string, state string, _ string) (string, error) {
-	function := "setVideoMuteDo"
-
-	state = strings.ReplaceAll(state, "\"", "")
-	state = strings.ReplaceAll(state, "'", "")
+// The soft limits of a DMP gain group, as set in DSP Configurator.  Remembered for notifications (see updateShadow)
+func readGroupSoftLimits(socketKey string, group string) (dmpGroup, error) {
+	resp, err := deviceTypeDependantCommand(socketKey, "groupsoftlimits", "GET", group, "", "")
+	if err != nil {
+		return dmpGroup{}, err
+	}
 
-	stateCmd := "0"
-	if state == "true" {
-		stateCmd = "1"
+	// Good response is "<upper>*<lower>", ex: "-00100*-00800", some firmware echoes "GrpmL<group>*" first
+	resp = strings.TrimSpace(strings.ReplaceAll(resp, `"`, ``))
+	if strings.Contains(resp, "error") {
+		return dmpGroup{}, errors.New(resp)
+	}
+	resp = strings.TrimPrefix(resp, "GrpmL"+group+"*")
+	upper, lower, found := strings.Cut(resp, "*")
+	upperInt, upperErr := strconv.Atoi(upper)
+	lowerInt, lowerErr := strconv.Atoi(lower)
+	if !found || upperErr != nil || lowerErr != nil {
+		disconnectAfterBadData(socketKey, "readGroupSoftLimits")
+		return dmpGroup{}, errors.New("invalid soft limits response: " + resp)
+	}
+	if upperInt < lowerInt {
+		upperInt, lowerInt = lowerInt, upperInt
+	}
+	if upperInt == lowerInt {
+		return dmpGroup{}, fmt.Errorf("soft limits leave no range, both are %d", upperInt)
 	}
 
-	model, err := findModelName(socketKey)
+	limits := dmpGroup{Upper: upperInt, Lower: lowerInt}
+	rememberDmpGroup(socketKey, group, limits)
+	return limits, nil
+}
+
+// The raw value of a DMP group master: tenths of dB for gain groups, 1 or 0 for mute groups
+func readGroupValue(socketKey string, group string) (int, error) {
+	resp, err := deviceTypeDependantCommand(socketKey, "groupvolume", "GET", group, "", "")
 	if err != nil {
-		modelErr := function + " - can not find model for: " + socketKey
-		framework.AddToErrors(socketKey, modelErr)
-		return modelErr, errors.New(modelErr)
+		return 0, err
 	}
-	switch {
-	case strings.Contains(model, "160") && strings.Contains(model, "IN"): // IN 160x series special handling
-		outputNum, ok := in160xVideoMuteMap[output]
-		output = outputNum
-		if !ok {
-			errMsg := function + " - can't find video mute mapping" + output + "for provided output on model: " + model
-			framework.AddToErrors(socketKey, errMsg)
-			return errMsg, errors.New(errMsg)
-		}
-	} // other devices like crosspoints you can call the named output directly ex: "3A"
-
-	// TODO: DA mapping (ex: /loopthrough should be command 0)
-	resp, err := deviceTypeDependantCommand(socketKey, "videomute", "SET", output, stateCmd, "")
+	resp = strings.TrimSpace(strings.ReplaceAll(resp, `"`, ``))
+	if strings.Contains(resp, "error") {
+		return 0, errors.New(resp)
+	}
+	resp = strings.TrimPrefix(resp, "GrpmD"+group+"*")
+	value, err := strconv.Atoi(resp)
 	if err != nil {
-		errMsg := function + "- error setting video mute: " + err.Error()
-		framework.AddToErrors(socketKey, errMsg)
-		return errMsg, errors.New(errMsg)
+		disconnectAfterBadData(socketKey, "readGroupValue")
+		return 0, errors.New("invalid group response: " + resp)
 	}
+	return value, nil
+}
 
-	// Good response for 'all' devices: Vmt(output int)*(status int)
-	if strings.Contains(resp, "Vmt") && strings.Contains(resp, output) {
-		return "ok", nil
-	} else {
-		errMsg := function + " - invalid response for video mute: " + resp
+// Good response to a group master set is "GrpmD<group>*<value>", the value may be zero padded
+func checkGroupResponse(socketKey string, function string, group string, value string, resp string) (string, error) {
+	resp = strings.TrimSpace(strings.ReplaceAll(resp, `"`, ``))
+	if strings.Contains(resp, "error") {
+		return resp, errors.New(resp) // device returned an error code
+	}
+	echo, found := strings.CutPrefix(resp, "GrpmD"+group+"*")
+	echoInt, echoErr := strconv.Atoi(echo)
+	valueInt, _ := strconv.Atoi(value)
+	if !found || echoErr != nil || echoInt != valueInt {
+		errMsg := function + " - invalid response for group " + group + ": " + resp
 		disconnectAfterBadData(socketKey, function)
 		framework.AddToErrors(socketKey, errMsg)
 		return errMsg, errors.New(errMsg)
 	}
+	return "ok", nil
 }
 
-// func setVideoSyncMuteDo(socketKey string, endpoint string, output string, state string, _ string) (string, error) {
-// 	function := "setVideoSyncMuteDo"
+// Remembers what kind of group a DMP group number is, so its notifications can be recorded as a volume or a mute
+func rememberDmpGroup(socketKey string, group string, kind dmpGroup) {
+	dmpGroupsMutex.Lock()
+	defer dmpGroupsMutex.Unlock()
 
-// 	var cmd string
-// 	if state == "true" {
-// 		cmd = "videosyncmute"
-// 	} else {
-// 		cmd = "videounmute"
-// 	}
+	if _, exists := dmpGroups[socketKey]; !exists {
+		dmpGroups[socketKey] = make(map[string]dmpGroup)
+	}
+	dmpGroups[socketKey][group] = kind
+}
 
-// 	// DA with loop through, loop through is output "0"
-// 	// Matrix switchers need to refer to output by name (ex: "3B")
-// 	// IN 180x needs to refer to output by number (ex: 1B would be "2")
-// 	// Non IN 180x Scalers or switchers can just call "1"
-// 	resp, err := deviceTypeDependantCommand(socketKey, cmd, "SET", output, "", "")
-// 	if err != nil {
-// 		errMsg := function + "- error setting video sync mute: " + err.Error()
-// 		framework.AddToErrors(socketKey, errMsg)
-// 		return errMsg, errors.New(errMsg)
-// 	}
+// What is known about a DMP group, see rememberDmpGroup
+func knownDmpGroup(socketKey string, group string) (dmpGroup, bool) {
+	dmpGroupsMutex.Lock()
+	defer dmpGroupsMutex.Unlock()
 
-// 	// Good response for 'all' devices: Vmt(output int)*(status int)
-// 	if strings.Contains(resp, "Vmt") && strings.Contains(resp, output) {
-// 		return "ok", nil
-// 	} else {
-// 		errMsg := function + " - invalid response for video sync mute: " + resp
-// 		disconnectAfterBadData(socketKey, function)
-// 		framework.AddToErrors(socketKey, errMsg)
-// 		return errMsg, errors.New(errMsg)
-// 	}
-// }
+	kind, exists := dmpGroups[socketKey][group]
+	return kind, exists
+}
 
-// Built for DMP DSP's
-// Note, this is a 3 arg funciton.  The last argument (state) true or false is in the request body
-// Ex: curl -X PUT "http://<containerIP>/telnet|admin:pw@<deviceAddr>/matrixmute/MicToOut3/4" -H "Content-Type: application/json" -d true
-func setMatrixMuteDo(socketKey string, endpoint string, input string, output string, state string) (string, error) {
-	function := "setMatrixMuteDo"
+// Puts one route in the device shadow and publishes it if it changed.
+// For endpoints that answer with several routes at once (matrixties, a broken away audioandvideoroute).
+func recordRoute(socketKey string, endpoint string, output string, input string, media string) {
+	ev := deviceEvent{SocketKey: socketKey, Kind: routeChanged, Source: "get", Endpoint: endpoint,
+		Output: output, Input: input, Media: media, Time: time.Now()}
+	if updateShadow(ev) {
+		publishDeviceEvent(ev)
+	}
+}
 
-	if state == "" || state == "null" || state == "\"null\"" {
-		emptyStateMsg := function + "- Arg3 is required but not found in the request body"
-		framework.AddToErrors(socketKey, emptyStateMsg)
-		return emptyStateMsg, errors.New(emptyStateMsg)
+// Audio half of audioandvideomute.  Returns "true" or "false".
+func getOutputAudioMute(socketKey string, output string) (string, error) {
+	model, err := findModelName(socketKey)
+	if err != nil {
+		return "", errors.New("can not find model for: " + socketKey)
 	}
-	var cmdState string
-	switch {
-	case strings.Contains(state, "false"):
-		cmdState = "0"
-	case strings.Contains(state, "true"):
-		cmdState = "1"
-	default:
-		stateErrMsg := function + " - Arg 3 must be 'true', or 'false'.  Got: " + state
-		framework.AddToErrors(socketKey, stateErrMsg)
-		return stateErrMsg, errors.New(stateErrMsg)
+	if profile := profileForModel(socketKey, model); profile != nil && profile.OutputMuteGroup != "" { // ex: IN 160x outputs share one mute group
+		return getAudioMuteDo(socketKey, "audiomute", profile.OutputMuteGroup, "", "")
 	}
 
-	mixPointNumber, err := calculateDmpMixPointNumber(input, output)
+	audioOutput, err := audioMuteOutput(socketKey, output)
 	if err != nil {
-		errMsg := function + " - error calculating mix point number: " + err.Error()
-		framework.AddToErrors(socketKey, errMsg)
-		return errMsg, errors.New(errMsg)
+		return "", err
 	}
-
-	resp, err := deviceTypeDependantCommand(socketKey, "matrixmute", "SET", mixPointNumber, cmdState, "")
+	resp, err := deviceTypeDependantCommand(socketKey, "audioandvideomute", "GET", audioOutput, "", "")
 	if err != nil {
-		errMsg := function + "- error getting matrix mute status: " + err.Error()
-		framework.AddToErrors(socketKey, errMsg)
-		return errMsg, errors.New(errMsg)
+		return "", err
 	}
 
-	// Successful response is "DsM<mixPointNumber>*<1|0>"
-	if strings.Contains(resp, "DsM") && strings.Contains(resp, cmdState) && strings.Contains(resp, mixPointNumber) {
-		return "ok", nil
-	} else {
-		badRespMsg := function + " - unexpected device response: " + resp
-		disconnectAfterBadData(socketKey, function)
-		framework.AddToErrors(socketKey, badRespMsg)
-		return badRespMsg, errors.New(badRespMsg)
+	// Good response ends in "1" (muted) or "0", ex: "1" or "Amt2*1"
+	resp = strings.ReplaceAll(resp, `"`, ``)
+	if len(resp) == 0 {
+		disconnectAfterBadData(socketKey, "getOutputAudioMute")
+		return "", errors.New("empty response for audio mute")
 	}
+	return stringIntToStringBool(socketKey, resp[len(resp)-1:])
 }
 
-// Note, this is a 3 arg funciton.  The last argument (level 0-100) is in the request body
-// Ex: curl -X PUT "http://<containerIP>/telnet|admin:pw@<deviceAddr>/matrixvolume/MicToOut3/4" -H "Content-Type: application/json" -d 76
-func setMatrixVolumeDo(socketKey string, endpoint string, input string, output string, level string) (string, error) {
-	function := "setMatrixVolumeDo"
-
-	mixPointNumber, err := calculateDmpMixPointNumber(input, output)
+// Errors if the device has no audio leg for output, without sending anything
+func checkOutputAudioMute(socketKey string, output string) error {
+	model, err := findModelName(socketKey)
 	if err != nil {
-		errMsg := function + " - error calculating mix point number: " + err.Error()
-		framework.AddToErrors(socketKey, errMsg)
-		return errMsg, errors.New(errMsg)
+		return errors.New("can not find model for: " + socketKey)
+	}
+	if profile := profileForModel(socketKey, model); profile != nil && profile.OutputMuteGroup != "" {
+		return nil
 	}
+	_, err = audioMuteOutput(socketKey, output)
+	return err
+}
 
-	// Framework enqueues arg3 wrapped in quotes (e.g., "50"). Sanitize before converting.
-	levelSanitized := strings.TrimSpace(strings.Trim(level, `"`))
-	if levelSanitized == "" {
-		errMsg := function + " - level (0-100) required in request body"
-		framework.AddToErrors(socketKey, errMsg)
-		return errMsg, errors.New(errMsg)
+func setOutputAudioMute(socketKey string, output string, state string) (string, error) {
+	muteCmd := "0"
+	if state == "true" {
+		muteCmd = "1"
 	}
 
-	levelVal, err := newTransformVolume(levelSanitized)
+	model, err := findModelName(socketKey)
 	if err != nil {
-		errMsg := function + " - error converting percent volume to device volume: " + err.Error()
-		framework.AddToErrors(socketKey, errMsg)
-		return errMsg, errors.New(errMsg)
+		return "", errors.New("can not find model for: " + socketKey)
+	}
+	if profile := profileForModel(socketKey, model); profile != nil && profile.OutputMuteGroup != "" {
+		return setAudioMuteDo(socketKey, "audiomute", profile.OutputMuteGroup, state, "")
 	}
 
-	resp, err := deviceTypeDependantCommand(socketKey, "matrixvolume", "SET", mixPointNumber, levelVal, "")
+	audioOutput, err := audioMuteOutput(socketKey, output)
 	if err != nil {
-		errMsg := function + "- error setting matrix volume: " + err.Error()
-		framework.AddToErrors(socketKey, errMsg)
-		return errMsg, errors.New(errMsg)
+		return "", err
 	}
-
-	// Valid response is "DsG<mixPointNumber>*<levelVal>"
-	if strings.Contains(resp, "DsG") && strings.Contains(resp, mixPointNumber) && strings.Contains(resp, levelVal) {
-		return "ok", nil
+	var resp string
+	if audioOutput == "" { // Switchers only have the one output
+		resp, err = deviceTypeDependantCommand(socketKey, "audioandvideomute", "SET", muteCmd, "", "")
 	} else {
-		errMsg := function + " - invalid response for setting matrix volume: " + resp
-		framework.AddToErrors(socketKey, errMsg)
-		return errMsg, errors.New(errMsg)
+		resp, err = deviceTypeDependantCommand(socketKey, "audioandvideomute", "SET", audioOutput, muteCmd, "")
+	}
+	if err != nil {
+		return "", err
 	}
-}
 
-///////////////////////////////////////////////////////////////////////////////
-// Helper functions //
-///////////////////////////////////////////////////////////////////////////////
+	// Good response is "Amt<output>*<muteCmd>", or "Amt<muteCmd>" on Switchers
+	resp = strings.ReplaceAll(resp, `"`, ``)
+	if !strings.HasPrefix(resp, "Amt") || !strings.HasSuffix(resp, muteCmd) {
+		disconnectAfterBadData(socketKey, "setOutputAudioMute")
+		return "", errors.New("invalid response for audio mute: " + resp)
+	}
+	return "ok", nil
+}
 
-func isEven(n int) bool {
-	return n%2 == 0
+// Output to send with the audio mute commands.
+// Matrix audio outputs are numbered, so the video output "3A" is audio output "3".
+// DA loop through is output "0".  Switchers take no output.
+func audioMuteOutput(socketKey string, output string) (string, error) {
+	deviceType, err := findDeviceType(socketKey)
+	if err != nil {
+		return "", err
+	}
+	switch deviceType {
+	case "Matrix Switcher":
+		return strings.TrimRight(output, "AB"), nil
+	case "Distribution Amplifier":
+		if output == "LoopThrough" {
+			return "0", nil
+		}
+		return output, nil
+	case "Switcher":
+		return "", nil
+	default:
+		return "", errors.New("audio mute by output is not supported on device type: " + deviceType)
+	}
 }
 
-// The following two functions convert between:
+// The following functions convert between:
 // - Device volume in tenths-of-dB (range -1000 to +120 representing -100 dB to +12 dB)
-// - Our API volume in 0-100 percent using a logarithmic curve (similar feel to Shure). Unity gain at 76
-// Mapping is normalized so 0% -> -1000 and 100% -> +120, with a curve parameter k controlling shape.
-// Note: 1804 does not follow the pattern and uses 1db steps on dbFS, -100 to +000. Ugh!
+// - Our API volume in 0-100 percent, along a volume curve (see volume_curves.go).
+// The default curve is logarithmic (similar feel to Shure), unity gain at 76.
+// transformVolume and unTransformVolume do the same over another range, for DMP gain blocks (see dmpGainBlocks) and groups.
+// Note: 1804 does not follow the pattern and uses 1db steps on dbFS, -100 to +000, see transformVolumeIN1804.
+
+// Mix points and scaler volume groups, -100 to +12 dB
+const minVolumeTenths, maxVolumeTenths = -1000, 120
 
 // Convert percent (0 to 100) into Extron-style tenth's of decibels
-func newTransformVolume(percent string) (string, error) {
-	function := "newTransformVolume"
+func newTransformVolume(curve *volumeCurve, percent string) (string, error) {
+	return transformVolume(curve, percent, minVolumeTenths, maxVolumeTenths)
+}
+
+// Converts Extron-style tenths of decibels to percentage 0 to 100
+func newUnTransformVolume(curve *volumeCurve, tenthsDb string) (string, error) {
+	return unTransformVolume(curve, tenthsDb, minVolumeTenths, maxVolumeTenths)
+}
+
+// Convert percent (0 to 100) into tenths of decibels between minTenthsDb and maxTenthsDb
+func transformVolume(curve *volumeCurve, percent string, minTenthsDb int, maxTenthsDb int) (string, error) {
+	function := "transformVolume"
 	// percent is expected 0-100 (string)
 	// sanitize input in case it arrives quoted
 	percent = strings.TrimSpace(strings.Trim(percent, `"`))
@@ -928,39 +3497,16 @@ func newTransformVolume(percent string) (string, error) {
 	if err != nil {
 		return "", errors.New(fmt.Sprintf("%s - error converting percent to int: %v", function, err))
 	}
-	if percentInt < 0 {
-		percentInt = 0
-	}
-	if percentInt > 100 {
-		percentInt = 100
-	}
-	// Bounds in tenths dB
-	minTenthsDb := -1000.0
-	maxTenthsDb := 120.0
-	tenthsDbRange := maxTenthsDb - minTenthsDb // 1120
-	// Log curve similar to Shure, normalized to [0,1]
-	k := 11.0
-	denom := math.Log10(1.0 + 100.0/k)
-	var normalized float64
-	if denom == 0 {
-		normalized = float64(percentInt) / 100.0
-	} else {
-		normalized = math.Log10(1.0+float64(percentInt)/k) / denom
-	}
-	if normalized < 0 {
-		normalized = 0
-	}
-	if normalized > 1 {
-		normalized = 1
+	tenthsDb, err := curve.toTenths(percentInt, minTenthsDb, maxTenthsDb)
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("%s - %v", function, err))
 	}
-	tenthsDb := minTenthsDb + normalized*tenthsDbRange
-	tenthsDbInt := int(math.Round(tenthsDb))
-	return strconv.Itoa(tenthsDbInt), nil
+	return strconv.Itoa(tenthsDb), nil
 }
 
-// Converts Extron-style tenths of decibels to percentage 0 to 100
-func newUnTransformVolume(tenthsDb string) (string, error) {
-	function := "newUnTransformVolume"
+// Converts tenths of decibels between minTenthsDb and maxTenthsDb to percentage 0 to 100
+func unTransformVolume(curve *volumeCurve, tenthsDb string, minTenthsDb int, maxTenthsDb int) (string, error) {
+	function := "unTransformVolume"
 	// tenthsDb is a string like "-35" (=-3.5 dB) or "-1000" (=-100 dB)
 
 	// sanitize input in case it arrives quoted from framework
@@ -969,38 +3515,48 @@ func newUnTransformVolume(tenthsDb string) (string, error) {
 	if err != nil {
 		return "", errors.New(fmt.Sprintf("%s - error converting tenths dB to int: %v", function, err))
 	}
-	minTenthsDb := -1000.0
-	maxTenthsDb := 120.0
-	tenthsDbRange := maxTenthsDb - minTenthsDb // 1120
-	// Clamp to expected device range
-	clampedTenthsDb := float64(tenthsDbInt)
-	if clampedTenthsDb < minTenthsDb {
-		clampedTenthsDb = minTenthsDb
-	}
-	if clampedTenthsDb > maxTenthsDb {
-		clampedTenthsDb = maxTenthsDb
-	}
-	// Normalize to [0,1]
-	normalized := (clampedTenthsDb - minTenthsDb) / tenthsDbRange
-	if normalized < 0 {
-		normalized = 0
-	}
-	if normalized > 1 {
-		normalized = 1
-	}
-	// Invert the normalized log curve back to percent
-	k := 11.0
-	base := 1.0 + 100.0/k
-	percent := k * (math.Pow(base, normalized) - 1.0)
-	// Round to nearest integer and clamp 0..100
-	percentInt := int(math.Round(percent))
+	percent, err := curve.toPercent(tenthsDbInt, minTenthsDb, maxTenthsDb)
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("%s - %v", function, err))
+	}
+	return strconv.Itoa(percent), nil
+}
+
+// The IN1804 sets its program volume in whole dB from -100 to 0 dBFS, with no gain above 0.
+// That is 101 steps, so each percent is one dB (0% is -100 dB, 100% is 0 dB) and every value round-trips exactly
+func transformVolumeIN1804(percent string) (string, error) {
+	function := "transformVolumeIN1804"
+
+	percent = strings.TrimSpace(strings.Trim(percent, `"`))
+	percentInt, err := strconv.Atoi(percent)
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("%s - error converting percent to int: %v", function, err))
+	}
 	if percentInt < 0 {
 		percentInt = 0
 	}
 	if percentInt > 100 {
 		percentInt = 100
 	}
-	return strconv.Itoa(percentInt), nil
+	return strconv.Itoa(percentInt - 100), nil
+}
+
+// Converts IN1804 whole dB, ex: "-35" or "Vol-35", to percentage 0 to 100
+func unTransformVolumeIN1804(db string) (string, error) {
+	function := "unTransformVolumeIN1804"
+
+	db = strings.TrimPrefix(strings.TrimSpace(strings.Trim(db, `"`)), "Vol")
+	dbInt, err := strconv.Atoi(db)
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("%s - error converting dB to int: %v", function, err))
+	}
+	if dbInt < -100 {
+		dbInt = -100
+	}
+	if dbInt > 0 {
+		dbInt = 0
+	}
+	return strconv.Itoa(dbInt + 100), nil
 }
 
 // Placeholder for not implemented functions
@@ -1042,6 +3598,7 @@ func telnetLoginNegotiation(socketKey string) (success bool) {
 	function := "telnetLoginNegotiation"
 
 	framework.Log(function + " - Starting telnet login for: " + socketKey)
+	forgetDeviceTables(socketKey) // a new session, the device may have been changed while we were away
 	// Get password. Extron Telnet connection assumes 'admin' as username
 	password := "" // device expects empty string if no password is set
 	if strings.Count(socketKey, "@") == 1 {
@@ -1056,7 +3613,7 @@ func telnetLoginNegotiation(socketKey string) (success bool) {
 	// Breaks if the negotiations go over 7 rounds to avoid an infinite loop.
 	for count < 7 {
 		count += 1
-		negotiationResp := framework.ReadLineFromSocket(socketKey)
+		negotiationResp := transportFor(socketKey).readLine(socketKey)
 
 		// make use of the information presented at the login screen
 		// Needed because not every device has a command to return model name, but they present it here
@@ -1065,9 +3622,9 @@ func telnetLoginNegotiation(socketKey string) (success bool) {
 			if commas == 4 { // copywright, company, model name, firmware, part number
 				modelName := strings.TrimSpace(strings.Split(negotiationResp, ",")[2])
 				framework.Log(function + "- Model name: " + modelName)
-				deviceModels[socketKey] = modelName
+				setDeviceModel(socketKey, modelName)
 				modelNameFound = true
-			} else if commas != 1 || commas != 0 { // unexpected response (date line contains 1 comma)
+			} else if commas != 1 && commas != 0 { // unexpected response (date line contains 1 comma)
 				framework.AddToErrors(socketKey, function+" - Help! does this line contain the model name? "+negotiationResp)
 			}
 		}
@@ -1076,7 +3633,7 @@ func telnetLoginNegotiation(socketKey string) (success bool) {
 
 		if password != "" {
 			if strings.Contains(negotiationResp, "Password:") {
-				sent := framework.WriteLineToSocket(socketKey, password+"\r")
+				sent := transportFor(socketKey).writeLine(socketKey, password+"\r")
 				if !sent {
 					errMsg := function + " - k4j5d3m - Failed to send password"
 					framework.AddToErrors(socketKey, errMsg)
@@ -1119,12 +3676,20 @@ func ensureActiveConnection(socketKey string) error {
 				framework.AddToErrors(socketKey, errMsg)
 				return errors.New(errMsg)
 			}
+			if verboseMode {
+				enableVerboseMode(socketKey)
+			}
+			discoverIOSize(socketKey)
 		}
 	}
 	if framework.KeepAlivePolling {
 		// startKeepAlivePoll will not add new goroutines if they already exist for the socketKey
 		startKeepAlivePoll(socketKey, keepAlivePollingInterval, keepAliveCmd)
 	}
+	if verboseMode {
+		// same as above, one reader per socketKey
+		startEventReader(socketKey)
+	}
 	return nil
 }
 
@@ -1149,7 +3714,9 @@ func formatDeviceErrMessage(socketKey string, resp string) string {
 // so we treat bad data as a desync in order to start fresh.
 func disconnectAfterBadData(socketKey string, callingFuncName string) {
 	function := "disconnectAfterBadData"
-	framework.CloseSocketConnection(socketKey)
+	transportFor(socketKey).close(socketKey)
+	markShadowStale(socketKey)
+	forgetDeviceTables(socketKey)
 	framework.Log(function + " - Disconnecting: " + socketKey + "after getting bad data in: " + callingFuncName)
 }
 
@@ -1165,12 +3732,14 @@ func formatCommand(command string, arg1 string, arg2 string, arg3 string) string
 	// Count the number of non-empty arguments
 	verbCount := strings.Count(command, "%s")
 
-	switch verbCount {
-	case 3:
+	switch {
+	case strings.Contains(command, "%["): // explicit argument indexes, ex: "%[2]sV\r" only takes arg2
+		cmd = fmt.Sprintf(command, arg1, arg2, arg3)
+	case verbCount == 3:
 		cmd = fmt.Sprintf(command, arg1, arg2, arg3)
-	case 2:
+	case verbCount == 2:
 		cmd = fmt.Sprintf(command, arg1, arg2)
-	case 1:
+	case verbCount == 1:
 		cmd = fmt.Sprintf(command, arg1)
 	default:
 		cmd = command
@@ -1184,7 +3753,7 @@ func formatCommand(command string, arg1 string, arg2 string, arg3 string) string
 func findDeviceType(socketKey string) (string, error) {
 	function := "findDeviceType"
 
-	if deviceType, exists := deviceTypes[socketKey]; exists {
+	if deviceType := knownDeviceType(socketKey); deviceType != "" {
 		framework.Log(fmt.Sprintf("%s - %s - Device type found in cache: %s", function, socketKey, deviceType))
 		return deviceType, nil // cache hit
 	}
@@ -1246,31 +3815,68 @@ func categorizeDeviceType(socketKey string, modelDescriptionResp string) string
 
 	case strings.Contains(resp, "110v ac"):
 		deviceType = "Power Controller"
+
+	case strings.Contains(resp, "contact closure") || strings.Contains(resp, "relay") || strings.Contains(resp, "control processor"):
+		deviceType = "Controller" // ex: IPL T CR48, IPCP Pro
 	default:
 		deviceType = "unknown"
 	}
 
-	deviceTypes[socketKey] = deviceType
+	if profile := profileForModel(socketKey, deviceModel(socketKey)); profile != nil && profile.DeviceType != "" {
+		deviceType = profile.DeviceType // the profile knows better, ex: a description that doesn't follow the pattern
+	}
+
+	setDeviceType(socketKey, deviceType)
 	framework.Log(fmt.Sprintf("%s - %s - Device type determined: %s", function, socketKey, deviceType))
 
 	return deviceType
 
 }
 
+// The device type worked out by categorizeDeviceType, "" until it has been
+func knownDeviceType(socketKey string) string {
+	deviceTypesMutex.Lock()
+	defer deviceTypesMutex.Unlock()
+	return deviceTypes[socketKey]
+}
+
+func setDeviceType(socketKey string, deviceType string) {
+	deviceTypesMutex.Lock()
+	defer deviceTypesMutex.Unlock()
+	deviceTypes[socketKey] = deviceType
+}
+
+// The model name from the login banner (or a replayed transcript), "" until it has been read
+func deviceModel(socketKey string) string {
+	deviceModelsMutex.Lock()
+	defer deviceModelsMutex.Unlock()
+	return deviceModels[socketKey]
+}
+
+func setDeviceModel(socketKey string, model string) {
+	deviceModelsMutex.Lock()
+	defer deviceModelsMutex.Unlock()
+	deviceModels[socketKey] = model
+}
+
 // Internal: returns the model name from a package-level cache
 // Model name is cached at initial connection as it's in the welcome banner
 func findModelName(socketKey string) (string, error) {
 	function := "findModelName"
 
-	if modelName, exists := deviceModels[socketKey]; exists {
+	if modelName := deviceModel(socketKey); modelName != "" {
 		framework.Log(fmt.Sprintf("%s - %s - Device model found in cache: %s", function, socketKey, modelName))
 		return modelName, nil // cache hit
 	}
 
 	// It's possible we don't have a connection to the device yet.  Try connect then try again.
-	err := ensureActiveConnection(socketKey)
+	// Logging in talks to the socket, so it waits its turn like any command
+	mu := getSocketMutex(socketKey)
+	mu.Lock()
+	err := transportFor(socketKey).ensureActiveConnection(socketKey)
+	mu.Unlock()
 	_ = err
-	if modelName, exists := deviceModels[socketKey]; exists {
+	if modelName := deviceModel(socketKey); modelName != "" {
 		framework.Log(fmt.Sprintf("%s - %s - Device model found in cache: %s", function, socketKey, modelName))
 		return modelName, nil // cache hit
 	}
@@ -1315,6 +3921,9 @@ func startKeepAlivePoll(socketKey string, interval time.Duration, keepAliveCmd s
 				if resp == "" || strings.Contains(resp, "error") {
 					framework.AddToErrors(socketKey, fmt.Sprintf("%s - unexpected keepalive response: %s", function, resp))
 				}
+				if hasEventSubscribers(socketKey) {
+					refreshObservedValues(socketKey)
+				}
 			case <-stopCh:
 				framework.Log(fmt.Sprintf("%s - stopped for %s", function, socketKey))
 				return
@@ -1339,6 +3948,7 @@ func stopAllKeepAlivePolling() (string, error) {
 		delete(keepAlivePollRoutines, socketKey)
 		framework.Log(fmt.Sprintf("%s - stopped for %s", function, socketKey))
 	}
+	stopAllEventReaders()
 	return "ok", nil
 }
 
@@ -1376,6 +3986,11 @@ func deviceTypeDependantCommand(socketKey string, endpoint string, method string
 	}
 
 	cmdTemplate := cmdMap[endpoint][deviceType]
+	if profile := profileForModel(socketKey, deviceModel(socketKey)); profile != nil && profile.CommandVariant != "" {
+		if variantTemplate, exists := cmdMap[endpoint][profile.CommandVariant]; exists { // ex: IN1804
+			cmdTemplate = variantTemplate
+		}
+	}
 	cmdString := ""
 	cmdString = formatCommand(cmdTemplate, arg1, arg2, arg3)
 
@@ -1418,8 +4033,17 @@ func specialEndpointGet(socketKey string, endpoint string, arg1 string, arg2 str
 	value := `"unknown"`
 	err := error(nil)
 
+	if profile := profileForModel(socketKey, deviceModel(socketKey)); profile != nil && !profile.supports(endpoint) {
+		errMsg := fmt.Sprintf(function+" - endpoint %s is not supported on %s (profile: %s)", endpoint, deviceModel(socketKey), profile.Name)
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
 	if fn, exists := getFunctionsMap[endpoint]; exists {
 		value, err = fn(socketKey, endpoint, arg1, arg2, arg3)
+		if err == nil {
+			observeGet(socketKey, endpoint, "get", value, arg1, arg2, arg3)
+		}
 	} else {
 		errMsg := fmt.Sprintf(function+" - 7s5ce - no special get function found for endpoint: %s", endpoint)
 		framework.AddToErrors(socketKey, errMsg)
@@ -1436,8 +4060,17 @@ func specialEndpointSet(socketKey string, endpoint string, arg1 string, arg2 str
 	value := `"unknown"`
 	err := error(nil)
 
+	if profile := profileForModel(socketKey, deviceModel(socketKey)); profile != nil && !profile.supports(endpoint) {
+		errMsg := fmt.Sprintf(function+" - endpoint %s is not supported on %s (profile: %s)", endpoint, deviceModel(socketKey), profile.Name)
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+
 	if fn, exists := setFunctionsMap[endpoint]; exists {
 		value, err = fn(socketKey, endpoint, arg1, arg2, arg3)
+		if err == nil && (value == "ok" || value == avMuteReport("ok", "ok")) {
+			observeSet(socketKey, endpoint, arg1, arg2, arg3)
+		}
 	} else {
 		errMsg := fmt.Sprintf(function+" - kh6na - no special set function found for endpoint: %s", endpoint)
 		framework.AddToErrors(socketKey, errMsg)
@@ -1492,19 +4125,36 @@ func sendBasicCommandDo(socketKey string, cmdString string) (string, error) {
 	mu.Lock()
 	defer mu.Unlock()
 
-	err := ensureActiveConnection(socketKey)
+	transport := transportFor(socketKey)
+	err := transport.ensureActiveConnection(socketKey)
 	if err != nil {
 		framework.AddToErrors(socketKey, err.Error())
 		return "", err
 	}
 
-	sent := framework.WriteLineToSocket(socketKey, cmdString)
+	start := time.Now()
+	sent := transport.writeLine(socketKey, cmdString)
 	if sent != true {
 		errMsg := fmt.Sprintf(function + " - i5kcfoe - error sending command")
 		framework.AddToErrors(socketKey, errMsg)
 		return errMsg, errors.New(errMsg)
 	}
-	resp := framework.ReadLineFromSocket(socketKey)
+	resp := transport.readLine(socketKey)
+
+	// In verbose mode the device may push notifications before our reply. Publish them and keep reading.
+	var unsolicited []string
+	for skipped := 0; isUnsolicited(socketKey, cmdString, resp); skipped++ {
+		publishNotification(socketKey, resp)
+		unsolicited = append(unsolicited, resp)
+		if skipped == maxUnsolicitedLines {
+			errMsg := fmt.Sprintf(function+" - 8vtq2m - gave up waiting for reply after %d notifications", skipped)
+			framework.AddToErrors(socketKey, errMsg)
+			disconnectAfterBadData(socketKey, function)
+			return errMsg, errors.New(errMsg)
+		}
+		resp = transport.readLine(socketKey)
+	}
+	recordTranscript(socketKey, cmdString, unsolicited, resp, time.Since(start))
 
 	if framework.GetDeviceProtocol(socketKey) == "ssh" {
 		resp = processSSHOutput(resp)
@@ -0,0 +1,491 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Adds a site profile for the length of a test, like one from SIS_PROFILE_DIR
+func withProfile(t *testing.T, file string, data string) {
+	t.Helper()
+	profile, err := parseDeviceProfile(file, []byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := deviceProfiles
+	deviceProfiles = append([]*deviceProfile{profile}, deviceProfiles...)
+	t.Cleanup(func() { deviceProfiles = saved })
+}
+
+func TestDmpGroupMute(t *testing.T) {
+	withProfile(t, "room101.json", `{"name": "Room 101 DMP", "match": "DMP 128 Plus", "muteGroups": {"programmute": "2"}}`)
+
+	replayCalls(t, "telnet_dmp128plus_23.jsonl", []replayCall{
+		{"GET", "groupmute", "programmute", "", `"true"`},
+		{"SET", "groupmute", "2", "false", "ok"},
+	})
+
+	// Refused before anything is sent, the capture has no answers for these
+	socketKey := "telnet|admin:extron@dmp_groups"
+	setDeviceModel(socketKey, "DMP 128 Plus C V")
+	if _, err := getGroupMuteDo(socketKey, "groupmute", "3", "", ""); err == nil {
+		t.Error("group 3 is not in muteGroups, groupmute should refuse it")
+	}
+	if _, err := getGroupVolumeDo(socketKey, "groupvolume", "2", "", ""); err == nil {
+		t.Error("group 2 is a mute group, groupvolume should refuse it")
+	}
+}
+
+// A profile for the device comes before the one for its model, so each room's design has its own groups
+func TestDmpGroupMutePerDevice(t *testing.T) {
+	withProfile(t, "room101.json", `{"name": "Room 101 DMP", "match": "DMP 128 Plus", "devices": ["dmp_room101"], "muteGroups": {"programmute": "2"}}`)
+	withProfile(t, "room102.json", `{"name": "Room 102 DMP", "match": "DMP 128 Plus", "devices": ["dmp_room102"], "muteGroups": {"programmute": "7"}}`)
+
+	for socketKey, want := range map[string]string{"telnet|admin:extron@dmp_room101": "2", "telnet|admin:extron@dmp_room102": "7"} {
+		setDeviceModel(socketKey, "DMP 128 Plus C V")
+		if got, err := findDmpGroup(socketKey, "programmute", true); err != nil || got != want {
+			t.Errorf("%s: programmute is group %s, %v, want %s", socketKey, got, err, want)
+		}
+	}
+	socketKey := "telnet|admin:extron@dmp_room103"
+	setDeviceModel(socketKey, "DMP 128 Plus C V")
+	if _, err := findDmpGroup(socketKey, "programmute", true); err == nil || !strings.Contains(err.Error(), "dmp_room103") {
+		t.Errorf("a device without a profile: got %v, want the no profile error naming it", err)
+	}
+}
+
+func TestDmpGroupMuteWithoutProfile(t *testing.T) {
+	socketKey := "telnet|admin:extron@dmp_no_profile"
+	setDeviceModel(socketKey, "DMP 128 Plus C V")
+	if _, err := getGroupMuteDo(socketKey, "groupmute", "2", "", ""); err == nil {
+		t.Error("without muteGroups, groupmute can't tell a mute group from a gain group and should refuse")
+	}
+}
+
+func TestAudioAndVideoMute(t *testing.T) {
+	socketKey := "telnet|admin:extron@telnet_da4_avmute_23"
+	if err := startTranscriptReplay(socketKey, filepath.Join("testdata", "transcripts", "telnet_da4_avmute_23.jsonl"), false); err != nil {
+		t.Fatal(err)
+	}
+	defer stopTranscriptReplay(socketKey)
+
+	got, err := doDeviceSpecificSet(socketKey, "audioandvideomute", "3", "true", "")
+	if err != nil || got != `{"audio":"ok","video":"ok"}` {
+		t.Errorf("both legs: got %s, %v", got, err)
+	}
+
+	// Output 1 was sync muted (2), the DA refuses the audio leg, so the video leg goes back to sync mute
+	got, err = doDeviceSpecificSet(socketKey, "audioandvideomute", "1", "true", "")
+	if err == nil || !strings.Contains(got, `"video":"rolled back"`) {
+		t.Errorf("audio leg failed: got %s, %v", got, err)
+	}
+	replay, _ := transports.Load(socketKey)
+	for i, used := range replay.(*replayTransport).used {
+		if !used {
+			t.Errorf("command %q in the capture was never sent", replay.(*replayTransport).entries[i].Command)
+		}
+	}
+}
+
+func TestAudioAndVideoMuteWithoutAudioLeg(t *testing.T) {
+	// The IN1808 has no outputMuteGroup (and its profile leaves the endpoint out).  The capture has no video mute commands, so any sent would fail too
+	socketKey := "telnet|admin:extron@telnet_in1808_23"
+	if err := startTranscriptReplay(socketKey, filepath.Join("testdata", "transcripts", "telnet_in1808_23.jsonl"), false); err != nil {
+		t.Fatal(err)
+	}
+	defer stopTranscriptReplay(socketKey)
+
+	got, err := setAudioAndVideoMuteDo(socketKey, "audioandvideomute", "1A", "true", "")
+	if err == nil || !strings.Contains(got, "no audio leg") {
+		t.Errorf("got %s, %v, want no audio leg", got, err)
+	}
+}
+
+func TestTieOutputCount(t *testing.T) {
+	crosspoint84 := map[string]int{"1": 0, "2": 1, "3A": 2, "3B": 3, "4A": 4, "4B": 5}
+
+	socketKey := "telnet|admin:extron@tie_outputs_from_names"
+	if got := tieOutputCount(socketKey, crosspoint84, "1"); got != 4 {
+		t.Errorf("from the profile's names: got %d, want 4", got)
+	}
+
+	socketKey = "telnet|admin:extron@tie_outputs_discovered"
+	storeIOSize(socketKey, "V8X4 A8X2")
+	if got := tieOutputCount(socketKey, crosspoint84, "1"); got != 4 {
+		t.Errorf("video from the discovered size: got %d, want 4", got)
+	}
+	if got := tieOutputCount(socketKey, crosspoint84, "2"); got != 2 {
+		t.Errorf("audio from the discovered size: got %d, want 2", got)
+	}
+}
+
+// Only E10 and E14 to the authorization command leave authorized out, anything else is an error
+func TestHDCPInputStatusAuthorization(t *testing.T) {
+	socketKey := "telnet|admin:extron@telnet_cp84_hdcp_23"
+	if err := startTranscriptReplay(socketKey, filepath.Join("testdata", "transcripts", "telnet_cp84_hdcp_23.jsonl"), false); err != nil {
+		t.Fatal(err)
+	}
+	defer stopTranscriptReplay(socketKey)
+
+	got, err := doDeviceSpecificGet(socketKey, "hdcpinputstatus", "1", "")
+	if err != nil || got != `{"input":"1","sourcePresent":true,"hdcp":"1.4"}` {
+		t.Errorf("E14, not supported: got %s, %v", got, err)
+	}
+	if got, err := doDeviceSpecificGet(socketKey, "hdcpinputstatus", "2", ""); err == nil {
+		t.Errorf("invalid authorization response: got %s, want an error", got)
+	}
+	if got, err := doDeviceSpecificGet(socketKey, "hdcpinputstatus", "3", ""); err == nil {
+		t.Errorf("E13: got %s, want an error", got)
+	}
+}
+
+// The EDID table is read once, and again after a reconfigure.  The capture answers each slot once, so a second read fails.
+func TestEDIDTableKept(t *testing.T) {
+	socketKey := "telnet|admin:extron@telnet_cp84_edid_23"
+	if err := startTranscriptReplay(socketKey, filepath.Join("testdata", "transcripts", "telnet_cp84_edid_23.jsonl"), false); err != nil {
+		t.Fatal(err)
+	}
+	defer stopTranscriptReplay(socketKey)
+	defer forgetDeviceTables(socketKey)
+
+	want := `[{"slot":"1","description":"Output 1","followsOutput":"1"},{"slot":"2","description":"Output 2","followsOutput":"2"},{"slot":"3","description":"1920x1080 @60Hz 2ch","resolution":"1920x1080","refreshRate":60,"audio":"2ch"}]`
+	for _, read := range []string{"first", "kept"} {
+		got, err := doDeviceSpecificGet(socketKey, "edidpresets", "", "")
+		if err != nil || got != want {
+			t.Errorf("%s read: got %s, %v", read, got, err)
+		}
+	}
+	if edids, err := readEDIDTable(socketKey, "1"); err != nil || len(edids) != 1 {
+		t.Errorf("stopping at output 1's follow slot from the kept table: got %d slots, %v", len(edids), err)
+	}
+
+	publishNotification(socketKey, "Reconfig")
+	if _, err := doDeviceSpecificGet(socketKey, "edidpresets", "", ""); err == nil {
+		t.Error("after a reconfigure the table should be read from the device again")
+	}
+}
+
+// Only E13 ends the table, any other error code fails the read and nothing is kept
+func TestEDIDTableDeviceError(t *testing.T) {
+	socketKey := "telnet|admin:extron@telnet_cp84_edid_busy_23"
+	if err := startTranscriptReplay(socketKey, filepath.Join("testdata", "transcripts", "telnet_cp84_edid_busy_23.jsonl"), false); err != nil {
+		t.Fatal(err)
+	}
+	defer stopTranscriptReplay(socketKey)
+	defer forgetDeviceTables(socketKey)
+
+	if edids, err := readEDIDTable(socketKey, ""); err == nil || !strings.Contains(err.Error(), "E22") {
+		t.Errorf("E22 (busy) on slot 2: got %d slots, %v, want the error", len(edids), err)
+	}
+	deviceTablesMutex.Lock()
+	_, kept := edidTables[socketKey]
+	deviceTablesMutex.Unlock()
+	if kept {
+		t.Error("a table cut short by an error should not be kept")
+	}
+}
+
+// Preset names are read once for listing and recalling by name, and again after a save
+func TestPresetNamesKept(t *testing.T) {
+	socketKey := "telnet|admin:extron@telnet_cp84_presets_23"
+	if err := startTranscriptReplay(socketKey, filepath.Join("testdata", "transcripts", "telnet_cp84_presets_23.jsonl"), false); err != nil {
+		t.Fatal(err)
+	}
+	defer stopTranscriptReplay(socketKey)
+	defer forgetDeviceTables(socketKey)
+
+	if got, err := doDeviceSpecificGet(socketKey, "presets", "", ""); err != nil || got != `{"1":"Lecture","2":"Divided"}` {
+		t.Errorf("presets: got %s, %v", got, err)
+	}
+	if got, err := doDeviceSpecificSet(socketKey, "preset", "divided", "", ""); err != nil || got != "ok" {
+		t.Errorf("recall by name from the kept names: got %s, %v", got, err)
+	}
+	if got, err := doDeviceSpecificSet(socketKey, "savepreset", "1", "", ""); err != nil || got != "ok" {
+		t.Errorf("savepreset: got %s, %v", got, err)
+	}
+	if _, err := doDeviceSpecificGet(socketKey, "presets", "", ""); err == nil {
+		t.Error("after a save the names should be read from the device again")
+	}
+}
+
+// power is outlet power, a scaler refuses it without sending anything.  Its own power save is powersave.
+func TestPowerOnScaler(t *testing.T) {
+	replayCalls(t, "telnet_in1606_psav_23.jsonl", []replayCall{
+		{"SET", "powersave", "true", "", "ok"},
+		{"GET", "powersave", "", "", `"true"`},
+	})
+
+	socketKey := "telnet|admin:extron@telnet_in1606_psav_23"
+	if err := startTranscriptReplay(socketKey, filepath.Join("testdata", "transcripts", "telnet_in1606_psav_23.jsonl"), false); err != nil {
+		t.Fatal(err)
+	}
+	defer stopTranscriptReplay(socketKey)
+	if got, err := setPowerDo(socketKey, "power", "false", "", ""); err == nil || !strings.Contains(got, "no display power control") {
+		t.Errorf("SET power: got %s, %v", got, err)
+	}
+	if got, err := getPowerDo(socketKey, "power", "", "", ""); err == nil || !strings.Contains(got, "no display power control") {
+		t.Errorf("GET power: got %s, %v", got, err)
+	}
+	replay, _ := transports.Load(socketKey)
+	if missed := replay.(*replayTransport).missed; len(missed) > 0 {
+		t.Errorf("power sent %q", missed)
+	}
+}
+
+// The PDU pads the outlet in its echo ("Cpn03 Ppc0"), and an E-code is the device's answer, not bad data
+func TestPowerOutlets(t *testing.T) {
+	replayCalls(t, "telnet_pcs4_23.jsonl", []replayCall{
+		{"SET", "power", "3", "false", "ok"},
+		{"GET", "power", "3", "", `"false"`},
+		{"SET", "power", "all", "true", "ok"},
+		{"GET", "power", "all", "", `"true"`},
+	})
+
+	socketKey := "telnet|admin:extron@telnet_pcs4_error_23"
+	if err := startTranscriptReplay(socketKey, filepath.Join("testdata", "transcripts", "telnet_pcs4_error_23.jsonl"), false); err != nil {
+		t.Fatal(err)
+	}
+	defer stopTranscriptReplay(socketKey)
+	if got, err := setPowerDo(socketKey, "power", "4", "false", ""); err == nil || !strings.Contains(got, "E13") || strings.Contains(got, "invalid response") {
+		t.Errorf("SET power 4 false: got %s, %v, want the device's E13", got, err)
+	}
+}
+
+func TestCheckTieEcho(t *testing.T) {
+	tests := []struct {
+		resp   string
+		input  string
+		output string
+		media  []string
+		ok     bool
+	}{
+		{"Out1 In3 Vid", "3", "1", []string{"Vid", "RGB"}, true}, // IN 180x and matrix switchers
+		{"In4 RGB", "4", "", []string{"Vid", "RGB"}, true},       // IN 160x
+		{"In02 All", "2", "", []string{"All"}, true},             // switchers pad the input
+		{"Out2 In3 Vid", "3", "1", []string{"Vid", "RGB"}, false},
+		{"Out1 In4 Vid", "3", "1", []string{"Vid", "RGB"}, false},
+		{"Out1 In3 Aud", "3", "1", []string{"Vid", "RGB"}, false},
+		{"Vmt1*1", "1", "", []string{"All"}, false},
+	}
+	for _, tt := range tests {
+		if err := checkTieEcho(tt.resp, tt.input, tt.output, tt.media...); (err == nil) != tt.ok {
+			t.Errorf("checkTieEcho(%q, %s, %s): %v, want ok %v", tt.resp, tt.input, tt.output, err, tt.ok)
+		}
+	}
+}
+
+// An E-code to a level query or set is the device's answer, returned as it is instead of read as a bad level
+func TestVolumeDbDeviceError(t *testing.T) {
+	tests := []struct {
+		transcript string
+		endpoint   string
+		get        [2]string
+		set        [3]string
+	}{
+		{"telnet_in1606_volumedb_error_23.jsonl", "volumedb", [2]string{"programvolume", ""}, [3]string{"programvolume", "-3.5", ""}},
+		{"telnet_dmp128plus_volumedb_error_23.jsonl", "matrixvolumedb", [2]string{"MicToOut1", "1"}, [3]string{"MicToOut1", "1", "-3.5"}},
+	}
+	for _, tt := range tests {
+		socketKey := "telnet|admin:extron@" + strings.TrimSuffix(tt.transcript, ".jsonl")
+		if err := startTranscriptReplay(socketKey, filepath.Join("testdata", "transcripts", tt.transcript), false); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := doDeviceSpecificGet(socketKey, tt.endpoint, tt.get[0], tt.get[1]); err == nil || !strings.Contains(got, "device returned error: E13") {
+			t.Errorf("GET %s: got %s, %v, want the device's E13", tt.endpoint, got, err)
+		}
+		if got, err := doDeviceSpecificSet(socketKey, tt.endpoint, tt.set[0], tt.set[1], tt.set[2]); err == nil || !strings.Contains(got, "device returned error: E13") {
+			t.Errorf("SET %s: got %s, %v, want the device's E13", tt.endpoint, got, err)
+		}
+		stopTranscriptReplay(socketKey)
+	}
+}
+
+// The IN1804 takes whole dB from -100 to 0, anything else is refused before it is sent
+func TestVolumeDbIN1804Range(t *testing.T) {
+	socketKey := "telnet|admin:extron@in1804_volumedb_range"
+	if err := startTranscriptReplay(socketKey, filepath.Join("testdata", "transcripts", "telnet_in1804_volumedb_23.jsonl"), false); err != nil {
+		t.Fatal(err)
+	}
+	defer stopTranscriptReplay(socketKey)
+
+	for level, want := range map[string]string{"-3.5": "only takes whole dB", "1": "out of range", "-101": "out of range", "-3.25": "at most one decimal"} {
+		if got, err := setVolumeDbDo(socketKey, "volumedb", "programvolume", level, ""); err == nil || !strings.Contains(got, want) {
+			t.Errorf("%s dB: got %s, %v, want %s", level, got, err, want)
+		}
+	}
+	replay, _ := transports.Load(socketKey)
+	if missed := replay.(*replayTransport).missed; len(missed) > 0 {
+		t.Errorf("sent %q", missed)
+	}
+}
+
+// Audio tied to a different input than video comes back as both, and both land in the shadow
+func TestAudioAndVideoRouteBreakaway(t *testing.T) {
+	replayCalls(t, "telnet_cp84_breakaway_23.jsonl", []replayCall{
+		{"GET", "audioandvideoroute", "2", "", `{"audio":"5","breakaway":true,"video":"3"}`}, // "2%" is 3, "2$" is 5
+		{"GET", "audioandvideoroute", "1", "", `"4"`},
+	})
+
+	deviceShadowsMutex.Lock()
+	defer deviceShadowsMutex.Unlock()
+	shadow := deviceShadows["telnet|admin:extron@telnet_cp84_breakaway_23"]
+	if shadow == nil {
+		t.Fatal("nothing was recorded")
+	}
+	if route := shadow.VideoRoutes["2"]; route == nil || route.Value != "3" {
+		t.Errorf("video route of output 2: got %+v, want input 3", route)
+	}
+	if route := shadow.AudioRoutes["2"]; route == nil || route.Value != "5" {
+		t.Errorf("audio route of output 2: got %+v, want input 5", route)
+	}
+}
+
+func TestCheckIOName(t *testing.T) {
+	tests := []struct {
+		deviceType string
+		name       string
+		ok         bool
+	}{
+		{"Matrix Switcher", "Doc Cam", true},
+		{"Matrix Switcher", "Lectern PC 1", true},   // 12, the limit
+		{"Matrix Switcher", "Lectern PC 12", false}, // 13
+		{"Scaler", "Laptop Left Side", true},        // 16, the limit
+		{"Scaler", "Laptop Right Side", false},      // 17
+		{"Audio Processor", "Wireless Mic", true},   // 12
+		{"Audio Processor", "Wireless Mic1", false}, // 13
+		{"Switcher", "A name longer than any limit", true},
+		{"Matrix Switcher", "", false},
+		{"Matrix Switcher", "Doc,Cam", false}, // SIS delimiters
+		{"Matrix Switcher", "Room[1]", false},
+		{"Matrix Switcher", "PC~1", false},
+		{"Matrix Switcher", `Say "hi"`, false},
+		{"Matrix Switcher", "Doc\tCam", false}, // not printable
+		{"Matrix Switcher", "Café", false},     // not ASCII
+	}
+	for _, tt := range tests {
+		if err := checkIOName(tt.deviceType, tt.name); (err == nil) != tt.ok {
+			t.Errorf("%s %q: %v, want ok %v", tt.deviceType, tt.name, err, tt.ok)
+		}
+	}
+}
+
+// The echo has to be for the number and name that were sent, padded numbers included
+func TestSetIOName(t *testing.T) {
+	tests := []struct {
+		transcript string
+		endpoint   string
+		number     string
+		name       string
+		want       string // part of the answer
+		ok         bool
+	}{
+		{"telnet_cp84_names_23.jsonl", "inputname", "2", "Doc Cam", "ok", true},   // "Nmi02,Doc Cam"
+		{"telnet_cp84_names_23.jsonl", "outputname", "3A", "Lectern", "ok", true}, // "3A" is output 3, "Nmo03,Lectern"
+		{"telnet_cp84_names_23.jsonl", "inputname", "4", "Laptop", "invalid response", false},
+		{"telnet_cp84_names_23.jsonl", "inputname", "5", "Camera", "device returned error: E13", false},
+		{"telnet_cp84_names_23.jsonl", "inputname", "9", "Camera", "input 9", false},                // not sent, the CP84 has 8 inputs
+		{"telnet_cp84_names_23.jsonl", "inputname", "1", "Lectern PC 12", "the limit is 12", false}, // not sent
+		{"telnet_in1606_names_23.jsonl", "inputname", "1", "Laptop Left Side", "ok", true},          // 16 is fine on a scaler
+		{"telnet_in1606_names_23.jsonl", "outputname", "1", "Projector", "not supported", false},    // scalers don't name outputs
+	}
+	for _, tt := range tests {
+		socketKey := "telnet|admin:extron@" + strings.TrimSuffix(tt.transcript, ".jsonl")
+		if _, loaded := transports.Load(socketKey); !loaded {
+			if err := startTranscriptReplay(socketKey, filepath.Join("testdata", "transcripts", tt.transcript), false); err != nil {
+				t.Fatal(err)
+			}
+			defer stopTranscriptReplay(socketKey)
+		}
+		got, err := doDeviceSpecificSet(socketKey, tt.endpoint, tt.number, tt.name, "")
+		if (err == nil) != tt.ok || !strings.Contains(got, tt.want) {
+			t.Errorf("%s %s %q: got %s, %v, want %s", tt.endpoint, tt.number, tt.name, got, err, tt.want)
+		}
+	}
+	for _, socketKey := range []string{"telnet|admin:extron@telnet_cp84_names_23", "telnet|admin:extron@telnet_in1606_names_23"} {
+		replay, _ := transports.Load(socketKey)
+		for i, used := range replay.(*replayTransport).used {
+			if !used {
+				t.Errorf("command %q in the capture was never sent", replay.(*replayTransport).entries[i].Command)
+			}
+		}
+		if missed := replay.(*replayTransport).missed; len(missed) > 0 {
+			t.Errorf("sent %q", missed)
+		}
+	}
+}
+
+// Relay numbers are checked against the model, and each change is read back
+func TestRelayErrors(t *testing.T) {
+	socketKey := "telnet|admin:extron@telnet_ipcp350_relay_error_23"
+	if err := startTranscriptReplay(socketKey, filepath.Join("testdata", "transcripts", "telnet_ipcp350_relay_error_23.jsonl"), false); err != nil {
+		t.Fatal(err)
+	}
+	defer stopTranscriptReplay(socketKey)
+
+	tests := []struct {
+		endpoint string
+		relay    string
+		arg      string
+		want     string // part of the error
+	}{
+		{"setstate", "3", "true", "reads back false after setting it to true"},
+		{"setstate", "2", "true", "device returned error: E13"},
+		{"setstate", "4", "true", "invalid response"}, // "Cpn04 Rly0"
+		{"timedtriggerstate", "2", "0.05", "reads back false after the pulse, it was true"},
+		{"triggerstate", "1", "", "device returned error: E14"},
+		{"setstate", "5", "true", "relay must be 1-4 on model IPCP Pro 350"}, // not sent
+		{"setstate", "0", "true", "relay must be 1-4"},
+		{"triggerstate", "5", "", "relay must be 1-4"},
+		{"timedtriggerstate", "1", "0.01", "duration must be 0.02 to 30 seconds"}, // less than a tick
+		{"timedtriggerstate", "1", "31", "duration must be 0.02 to 30 seconds"},
+	}
+	for _, tt := range tests {
+		if got, err := doDeviceSpecificSet(socketKey, tt.endpoint, tt.relay, tt.arg, ""); err == nil || !strings.Contains(got, tt.want) {
+			t.Errorf("%s %s %s: got %s, %v, want %s", tt.endpoint, tt.relay, tt.arg, got, err, tt.want)
+		}
+	}
+
+	replay, _ := transports.Load(socketKey)
+	for i, used := range replay.(*replayTransport).used {
+		if !used {
+			t.Errorf("command %q in the capture was never sent", replay.(*replayTransport).entries[i].Command)
+		}
+	}
+	if missed := replay.(*replayTransport).missed; len(missed) > 0 {
+		t.Errorf("sent %q", missed)
+	}
+}
+
+// Only controllers have relays, and only as many as the model
+func TestFindRelay(t *testing.T) {
+	tests := []struct {
+		model string
+		relay string
+		want  string
+	}{
+		{"IPL T CR48", "8", "8"},
+		{"IPL T CR48", "9", ""},
+		{"IPCP Pro 550", "08", "8"},
+		{"IPCP Pro 250", "2", "2"},
+		{"IPCP Pro 250", "3", ""},
+		{"IPCP Pro 350", `"4"`, "4"},
+		{"IPCP Pro 350", "A", ""},
+	}
+	for _, tt := range tests {
+		socketKey := "telnet|admin:extron@findrelay_" + tt.model
+		setDeviceModel(socketKey, tt.model)
+		setDeviceType(socketKey, "Controller")
+		got, err := findRelay(socketKey, tt.relay)
+		if got != tt.want || (err == nil) != (tt.want != "") {
+			t.Errorf("%s relay %s: got %q, %v, want %q", tt.model, tt.relay, got, err, tt.want)
+		}
+	}
+
+	socketKey := "telnet|admin:extron@findrelay_in1606"
+	setDeviceModel(socketKey, "IN1606")
+	setDeviceType(socketKey, "Scaler")
+	if _, err := findRelay(socketKey, "1"); err == nil {
+		t.Error("a scaler has no relays")
+	}
+}
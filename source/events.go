@@ -0,0 +1,479 @@
+package main
+
+// Unsolicited responses (verbose mode) and the event stream built from them.
+// With verbose mode on, devices push a line whenever a tie, mute or input signal changes,
+// even when we didn't ask. Those lines can arrive in the middle of a command/reply exchange,
+// so the send path uses isUnsolicited to skip over them and publishes them here instead.
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mefranklin6/microservice-framework/framework"
+)
+
+type deviceEventKind string
+
+const (
+	routeChanged        deviceEventKind = "routechanged"        // Output, Input, Media (video|audio|all)
+	muteChanged         deviceEventKind = "mutechanged"         // Output, Media (video|audio), State (true|false)
+	inputSignalChanged  deviceEventKind = "inputsignalchanged"  // Input, State (true|false)
+	groupChanged        deviceEventKind = "groupchanged"        // Output (group number), State (raw value)
+	levelChanged        deviceEventKind = "levelchanged"        // Output (DMP object ID), State (tenths of dB)
+	digitalInputChanged deviceEventKind = "digitalinputchanged" // Input (port), State (raw 1|0). Feeds occupancystatus
+	deviceReconfigured  deviceEventKind = "reconfigured"
+	valueChanged        deviceEventKind = "valuechanged" // Endpoint, Output/Input (endpoint args), State (new value)
+)
+
+type deviceEvent struct {
+	SocketKey string          `json:"-"`      // includes credentials, never sent to clients
+	Device    string          `json:"device"` // address of the device without credentials, ex: "192.168.50.82:23"
+	Kind      deviceEventKind `json:"kind"`
+	Source    string          `json:"source"` // notification, set, get or poll
+	Endpoint  string          `json:"endpoint,omitempty"`
+	Time      time.Time       `json:"time"`
+	Output    string          `json:"output,omitempty"`
+	Input     string          `json:"input,omitempty"`
+	Media     string          `json:"media,omitempty"`
+	State     string          `json:"state,omitempty"`
+	Label     string          `json:"label,omitempty"` // what a DMP object ID in Output is, ex: "MicToOut3/4" or "dmpoutputvolume/3"
+	Raw       string          `json:"raw,omitempty"`
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// Parsing //
+///////////////////////////////////////////////////////////////////////////////
+
+var tieMedia = map[string]string{"Vid": "video", "RGB": "video", "Aud": "audio", "All": "all"}
+
+// Verbose-mode lines we know how to turn into events
+var notificationPatterns = []struct {
+	pattern *regexp.Regexp
+	parse   func(m []string) []deviceEvent
+}{
+	{regexp.MustCompile(`^Out(\w+) In(\d+) (Vid|RGB|Aud|All)$`), func(m []string) []deviceEvent { // matrix tie
+		return []deviceEvent{{Kind: routeChanged, Output: m[1], Input: trimZeros(m[2]), Media: tieMedia[m[3]]}}
+	}},
+	{regexp.MustCompile(`^In(\d+) (Vid|RGB|Aud|All)$`), func(m []string) []deviceEvent { // single output tie
+		return []deviceEvent{{Kind: routeChanged, Output: "1", Input: trimZeros(m[1]), Media: tieMedia[m[2]]}}
+	}},
+	{regexp.MustCompile(`^Vmt(\w+)\*([0-2])$`), func(m []string) []deviceEvent {
+		return []deviceEvent{{Kind: muteChanged, Output: m[1], Media: "video", State: boolString(m[2] != "0")}}
+	}},
+	{regexp.MustCompile(`^Vmt([0-2])$`), func(m []string) []deviceEvent {
+		return []deviceEvent{{Kind: muteChanged, Output: "1", Media: "video", State: boolString(m[1] != "0")}}
+	}},
+	{regexp.MustCompile(`^Amt(\w+)\*([01])$`), func(m []string) []deviceEvent {
+		return []deviceEvent{{Kind: muteChanged, Output: m[1], Media: "audio", State: boolString(m[2] == "1")}}
+	}},
+	{regexp.MustCompile(`^Amt([01])$`), func(m []string) []deviceEvent {
+		return []deviceEvent{{Kind: muteChanged, Output: "1", Media: "audio", State: boolString(m[1] == "1")}}
+	}},
+	{regexp.MustCompile(`^Sig(\d+)\*([01])$`), func(m []string) []deviceEvent {
+		return []deviceEvent{{Kind: inputSignalChanged, Input: trimZeros(m[1]), State: boolString(m[2] == "1")}}
+	}},
+	{regexp.MustCompile(`^Frq\d*\s+([01* ]+)$`), func(m []string) []deviceEvent { // all inputs at once
+		bits := strings.NewReplacer("*", "", " ", "").Replace(m[1])
+		events := make([]deviceEvent, 0, len(bits))
+		for i, bit := range bits {
+			events = append(events, deviceEvent{Kind: inputSignalChanged, Input: fmt.Sprint(i + 1), State: boolString(bit == '1')})
+		}
+		return events
+	}},
+	{regexp.MustCompile(`^GrpmD(\d+)\*([+-]?\d+)$`), func(m []string) []deviceEvent {
+		return []deviceEvent{{Kind: groupChanged, Output: m[1], State: trimSignedZeros(m[2])}}
+	}},
+	{regexp.MustCompile(`^DsG(\d+)\*([+-]?\d+)$`), func(m []string) []deviceEvent {
+		return []deviceEvent{{Kind: levelChanged, Output: m[1], State: trimSignedZeros(m[2])}}
+	}},
+	{regexp.MustCompile(`^DsM(\d+)\*([01])$`), func(m []string) []deviceEvent {
+		return []deviceEvent{{Kind: muteChanged, Output: m[1], Media: "audio", State: boolString(m[2] == "1")}}
+	}},
+	{regexp.MustCompile(`^Cpn(\d+) Sio([01])$`), func(m []string) []deviceEvent { // controller digital input / contact closure
+		return []deviceEvent{{Kind: digitalInputChanged, Input: trimZeros(m[1]), State: m[2]}}
+	}},
+	{regexp.MustCompile(`^Cpn(\d+) Rly([01])$`), func(m []string) []deviceEvent { // controller relay
+		return []deviceEvent{{Kind: valueChanged, Endpoint: "setstate", Output: trimZeros(m[1]), State: boolString(m[2] == "1")}}
+	}},
+	{regexp.MustCompile(`^Rpr(\d+)$`), func(m []string) []deviceEvent { // preset recalled, ex: from the front panel
+		return []deviceEvent{{Kind: valueChanged, Endpoint: "preset", State: trimZeros(m[1])}}
+	}},
+	{regexp.MustCompile(`^Nm([io])(\d+),(.*)$`), func(m []string) []deviceEvent { // I/O renamed
+		endpoint := map[string]string{"i": "inputname", "o": "outputname"}[m[1]]
+		return []deviceEvent{{Kind: valueChanged, Endpoint: endpoint, Output: trimZeros(m[2]), State: m[3]}}
+	}},
+	{regexp.MustCompile(`^Reconfig$`), func(m []string) []deviceEvent {
+		return []deviceEvent{{Kind: deviceReconfigured}}
+	}},
+}
+
+// Commands that cause the device to answer with a notification-shaped line.
+// Used to tell our own reply apart from a notification caused by someone else.
+var commandEchoPatterns = []struct {
+	pattern *regexp.Regexp
+	echo    func(m []string) deviceEvent
+}{
+	{regexp.MustCompile(`^(\d+)\*(\w+)[%$!&]$`), func(m []string) deviceEvent {
+		return deviceEvent{Kind: routeChanged, Output: m[2], Input: trimZeros(m[1])}
+	}},
+	{regexp.MustCompile(`^(\d+)[$!&]$`), func(m []string) deviceEvent {
+		return deviceEvent{Kind: routeChanged, Output: "1", Input: trimZeros(m[1])}
+	}},
+	{regexp.MustCompile(`^(\w+)\*[0-2]B$`), func(m []string) deviceEvent {
+		return deviceEvent{Kind: muteChanged, Output: m[1], Media: "video"}
+	}},
+	{regexp.MustCompile(`^[0-2]B$`), func(m []string) deviceEvent {
+		return deviceEvent{Kind: muteChanged, Output: "1", Media: "video"}
+	}},
+	{regexp.MustCompile(`^(\d+)\*[01]Z$`), func(m []string) deviceEvent {
+		return deviceEvent{Kind: muteChanged, Output: m[1], Media: "audio"}
+	}},
+	{regexp.MustCompile(`^[01]Z$`), func(m []string) deviceEvent { // IN1804 program mute
+		return deviceEvent{Kind: muteChanged, Output: "1", Media: "audio"}
+	}},
+	{regexp.MustCompile(`^\x1B(\w+)\*[01]AFMT$`), func(m []string) deviceEvent {
+		return deviceEvent{Kind: muteChanged, Output: m[1], Media: "audio"}
+	}},
+	{regexp.MustCompile(`^\x1B[01]AFMT$`), func(m []string) deviceEvent {
+		return deviceEvent{Kind: muteChanged, Output: "1", Media: "audio"}
+	}},
+	{regexp.MustCompile(`^\x1BD(\d+)\*[+-]?\d+GRPM$`), func(m []string) deviceEvent {
+		return deviceEvent{Kind: groupChanged, Output: m[1]}
+	}},
+	{regexp.MustCompile(`^\x1BG(\d+)\*[+-]?\d+AU$`), func(m []string) deviceEvent {
+		return deviceEvent{Kind: levelChanged, Output: m[1]}
+	}},
+	{regexp.MustCompile(`^\x1BM(\d+)\*[01]AU$`), func(m []string) deviceEvent {
+		return deviceEvent{Kind: muteChanged, Output: m[1], Media: "audio"}
+	}},
+	{regexp.MustCompile(`^(\d+)\*[0-3](\*\d+)?O$`), func(m []string) deviceEvent {
+		return deviceEvent{Kind: valueChanged, Output: trimZeros(m[1])}
+	}},
+	{regexp.MustCompile(`^\d+\.$`), func(m []string) deviceEvent {
+		return deviceEvent{Kind: valueChanged} // Rpr, presets have no output
+	}},
+	{regexp.MustCompile(`^\x1B(\d+),.*N[IO]$`), func(m []string) deviceEvent {
+		return deviceEvent{Kind: valueChanged, Output: trimZeros(m[1])} // Nmi, Nmo
+	}},
+	{regexp.MustCompile(`^(\d+)\]$`), func(m []string) deviceEvent {
+		return deviceEvent{Kind: digitalInputChanged, Input: trimZeros(m[1])}
+	}},
+}
+
+// Returns the events in a verbose-mode line, or nil if the line is not a notification we recognize
+func parseNotification(socketKey string, line string) []deviceEvent {
+	line = strings.TrimSpace(strings.Trim(line, `"`))
+	for _, p := range notificationPatterns {
+		if m := p.pattern.FindStringSubmatch(line); m != nil {
+			events := p.parse(m)
+			now := time.Now()
+			for i := range events {
+				events[i].SocketKey = socketKey
+				events[i].Source = "notification"
+				events[i].Time = now
+				events[i].Raw = line
+				if isMixPointOID(events[i].Output) {
+					events[i].Label = dmpEventLabel(socketKey, events[i].Output)
+				}
+			}
+			return events
+		}
+	}
+	return nil
+}
+
+// The mix point or gain block a DMP notification is about, "" if it is not one.  Only decoded once the model is known, never asks the device
+func dmpEventLabel(socketKey string, oid string) string {
+	if block, channel, ok := decodeDmpGainBlock(deviceModel(socketKey), oid); ok {
+		return block.Endpoint + "/" + channel
+	}
+	mixPoint, err := decodeDmpMixPoint(deviceModel(socketKey), oid)
+	if err != nil {
+		return ""
+	}
+	return mixPoint.Input + "/" + mixPoint.Output
+}
+
+// True if line is a notification that is not the reply to cmdString.
+// Anything we don't recognize is treated as the reply, same as before verbose mode existed.
+func isUnsolicited(socketKey string, cmdString string, line string) bool {
+	events := parseNotification(socketKey, line)
+	if len(events) == 0 {
+		return false
+	}
+
+	cmd := strings.TrimRight(cmdString, "\r\n")
+	for _, p := range commandEchoPatterns {
+		m := p.pattern.FindStringSubmatch(cmd)
+		if m == nil {
+			continue
+		}
+		expected := p.echo(m)
+		for _, ev := range events {
+			if ev.Kind != expected.Kind || !strings.EqualFold(trimZeros(ev.Output), trimZeros(expected.Output)) {
+				continue
+			}
+			if expected.Input != "" && ev.Input != expected.Input {
+				continue
+			}
+			if expected.Media != "" && ev.Media != expected.Media {
+				continue
+			}
+			return false // this is our echo
+		}
+	}
+	return true
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// Publishing //
+///////////////////////////////////////////////////////////////////////////////
+
+type eventSubscriber struct {
+	device string // socketKey or device address, "" receives events from every device
+	ch     chan deviceEvent
+}
+
+var eventSubscribers = make(map[int]*eventSubscriber)
+var eventSubscribersMutex sync.Mutex
+var nextEventSubscriberID int
+
+// Subscribes to events for one device, or every device if device is "".
+// device can be a socketKey or just the address (see eventMatchesDevice).
+// Call the returned function to unsubscribe. Slow subscribers miss events rather than block the driver.
+func subscribeDeviceEvents(device string) (<-chan deviceEvent, func()) {
+	eventSubscribersMutex.Lock()
+	defer eventSubscribersMutex.Unlock()
+
+	id := nextEventSubscriberID
+	nextEventSubscriberID++
+	sub := &eventSubscriber{device: device, ch: make(chan deviceEvent, 64)}
+	eventSubscribers[id] = sub
+
+	unsubscribe := func() {
+		eventSubscribersMutex.Lock()
+		defer eventSubscribersMutex.Unlock()
+		if _, exists := eventSubscribers[id]; exists {
+			delete(eventSubscribers, id)
+			close(sub.ch)
+		}
+	}
+	return sub.ch, unsubscribe
+}
+
+func publishDeviceEvent(ev deviceEvent) {
+	function := "publishDeviceEvent"
+
+	if ev.Device == "" {
+		ev.Device = deviceAddress(ev.SocketKey)
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+
+	eventSubscribersMutex.Lock()
+	defer eventSubscribersMutex.Unlock()
+
+	for _, sub := range eventSubscribers {
+		if !eventMatchesDevice(sub.device, ev.SocketKey) {
+			continue
+		}
+		select {
+		case sub.ch <- ev:
+		default:
+			framework.Log(fmt.Sprintf("%s - subscriber for %s is full, dropping %s event", function, transcriptName(ev.SocketKey), ev.Kind))
+		}
+	}
+}
+
+// True if anyone is subscribed to events from socketKey
+func hasEventSubscribers(socketKey string) bool {
+	eventSubscribersMutex.Lock()
+	defer eventSubscribersMutex.Unlock()
+
+	for _, sub := range eventSubscribers {
+		if eventMatchesDevice(sub.device, socketKey) {
+			return true
+		}
+	}
+	return false
+}
+
+// A subscription filter matches every device when empty, otherwise the exact socketKey,
+// the address with port ("192.168.50.82:23") or just the host ("192.168.50.82").
+func eventMatchesDevice(filter string, socketKey string) bool {
+	if filter == "" || filter == socketKey {
+		return true
+	}
+	address := deviceAddress(socketKey)
+	if filter == address {
+		return true
+	}
+	host, _, found := strings.Cut(address, ":")
+	return found && filter == host
+}
+
+// Applies a notification line to the device shadow and publishes what changed. Returns false if the line wasn't a notification.
+func publishNotification(socketKey string, line string) bool {
+	function := "publishNotification"
+
+	events := parseNotification(socketKey, line)
+	if len(events) == 0 {
+		return false
+	}
+	framework.Log(fmt.Sprintf("%s - %s - unsolicited: %s", function, socketKey, line))
+	for _, ev := range events {
+		if ev.Kind == digitalInputChanged { // debounced before it becomes an occupancy change
+			observeOccupancyInput(socketKey, ev.Input, ev.State, ev.Source)
+			continue
+		}
+		if ev.Kind == deviceReconfigured {
+			forgetDeviceTables(socketKey)
+		}
+		if updateShadow(ev) {
+			publishDeviceEvent(ev)
+		}
+	}
+	return true
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// Per-connection reader //
+///////////////////////////////////////////////////////////////////////////////
+
+var eventReaders = make(map[string]chan bool) // socketKey -> stop channel
+var eventReadersMutex sync.Mutex
+
+// Turns on verbose mode right after login, before any command is sent
+func enableVerboseMode(socketKey string) {
+	function := "enableVerboseMode"
+
+	transport := transportFor(socketKey)
+	if !transport.writeLine(socketKey, verboseModeCmd) {
+		framework.AddToErrors(socketKey, function+" - failed to send verbose mode command")
+		return
+	}
+	resp := transport.readLine(socketKey)
+	if !strings.HasPrefix(strings.TrimSpace(resp), "Vrb") {
+		framework.AddToErrors(socketKey, function+" - unexpected response to verbose mode command: "+resp)
+	}
+}
+
+// Starts a reader that picks up notifications while no command is in flight.
+// Commands in flight skip over notifications themselves (see sendBasicCommandDo), so this only has to
+// catch what arrives between commands. Exits on its own once the connection goes away.
+func startEventReader(socketKey string) {
+	function := "startEventReader"
+
+	eventReadersMutex.Lock()
+	defer eventReadersMutex.Unlock()
+
+	if _, exists := eventReaders[socketKey]; exists {
+		return
+	}
+	if framework.SSHMode == "per-command session" && framework.GetDeviceProtocol(socketKey) == "ssh" {
+		return // nothing can arrive between sessions
+	}
+
+	stopCh := make(chan bool)
+	eventReaders[socketKey] = stopCh
+
+	go func() {
+		ticker := time.NewTicker(eventReaderInterval)
+		defer ticker.Stop()
+
+		framework.Log(fmt.Sprintf("%s - started for %s with interval %v", function, socketKey, eventReaderInterval))
+
+		for {
+			select {
+			case <-ticker.C:
+				if !transportFor(socketKey).connected(socketKey) {
+					eventReadersMutex.Lock()
+					delete(eventReaders, socketKey)
+					eventReadersMutex.Unlock()
+					framework.Log(fmt.Sprintf("%s - connection gone, stopped for %s", function, socketKey))
+					return
+				}
+
+				// Only hold the socket for a short look, commands wait for the mutex.
+				// A line that arrives after the look goes to the next reader, command or not.
+				mu := getSocketMutex(socketKey)
+				if !mu.TryLock() {
+					continue // a command is in flight and will handle anything that arrives
+				}
+				line := transportFor(socketKey).readLineWithin(socketKey, eventReaderWait)
+				mu.Unlock()
+
+				if strings.TrimSpace(line) == "" {
+					continue
+				}
+				if !publishNotification(socketKey, line) {
+					framework.AddToErrors(socketKey, fmt.Sprintf("%s - unrecognized unsolicited line: %s", function, line))
+				}
+			case <-stopCh:
+				framework.Log(fmt.Sprintf("%s - stopped for %s", function, socketKey))
+				return
+			}
+		}
+	}()
+}
+
+// Stops every event reader. They come back with the next command to each device.
+func stopAllEventReaders() {
+	eventReadersMutex.Lock()
+	defer eventReadersMutex.Unlock()
+
+	for socketKey, stopCh := range eventReaders {
+		close(stopCh)
+		delete(eventReaders, socketKey)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// Helpers //
+///////////////////////////////////////////////////////////////////////////////
+
+func boolString(b bool) string {
+	if b {
+		return "true"
+	}
+	return "false"
+}
+
+// Address of the device in socketKey without protocol or credentials,
+// ex: "telnet|admin:pw@192.168.50.82:23" -> "192.168.50.82:23"
+func deviceAddress(socketKey string) string {
+	if at := strings.LastIndex(socketKey, "@"); at >= 0 {
+		return socketKey[at+1:]
+	}
+	if pipe := strings.Index(socketKey, "|"); pipe >= 0 {
+		return socketKey[pipe+1:]
+	}
+	return socketKey
+}
+
+// "02" -> "2", "0" stays "0"
+func trimZeros(s string) string {
+	trimmed := strings.TrimLeft(s, "0")
+	if trimmed == "" && s != "" {
+		return "0"
+	}
+	return trimmed
+}
+
+// "-00250" -> "-250", "+00035" -> "35"
+func trimSignedZeros(s string) string {
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign = "-"
+	}
+	s = strings.TrimLeft(s, "+-")
+	trimmed := trimZeros(s)
+	if trimmed == "0" {
+		return "0"
+	}
+	return sign + trimmed
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// In verbose mode the device pushes other changes ahead of a reply.  They are published as events
+// and the reply is still parsed, including a notification-shaped echo of our own command.
+func TestNotificationsBeforeReply(t *testing.T) {
+	socketKey := "telnet|admin:extron@telnet_da4_verbose_23"
+	events, unsubscribe := subscribeDeviceEvents(socketKey)
+	defer unsubscribe()
+
+	replayCalls(t, "telnet_da4_verbose_23.jsonl", []replayCall{
+		{"GET", "videomute", "1", "", `"true"`},
+		{"SET", "videomute", "2", "true", "ok"}, // "Vmt3*2" first, then our "Vmt2*1"
+	})
+
+	want := []deviceEvent{
+		{Kind: muteChanged, Output: "1", Media: "video", State: "true", Source: "notification"},
+		{Kind: inputSignalChanged, Input: "1", State: "false", Source: "notification"},
+		{Kind: muteChanged, Output: "3", Media: "video", State: "true", Source: "notification"},
+	}
+	var got []deviceEvent
+	timeout := time.After(time.Second)
+	for len(got) < len(want) {
+		select {
+		case ev := <-events:
+			if ev.Source == "notification" {
+				got = append(got, ev)
+			}
+		case <-timeout:
+			t.Fatalf("got %d notification events, want %d", len(got), len(want))
+		}
+	}
+	for i, ev := range got {
+		w := want[i]
+		if ev.Kind != w.Kind || ev.Output != w.Output || ev.Input != w.Input || ev.Media != w.Media || ev.State != w.State {
+			t.Errorf("event %d: got %+v, want %+v", i, ev, w)
+		}
+	}
+}
+
+func TestIsUnsolicited(t *testing.T) {
+	tests := []struct {
+		cmd   string
+		line  string
+		wantU bool
+	}{
+		{"2*1B\r", "Vmt2*1", false},
+		{"2*1B\r", "Vmt3*1", true},
+		{"3*2!\r", "Out2 In3 All", false},
+		{"3*2!\r", "Out2 In4 All", true},
+		{"3!\r", "In3 All", false},
+		{"B\r", "Vmt1*1", true},
+		{"B\r", "0 1 0 0 0", false}, // not a notification
+		{"\x1BD2*1GRPM\r", "GrpmD2*+00001", false},
+		{"\x1BD2*1GRPM\r", "GrpmD4*+00001", true},
+	}
+	for _, tt := range tests {
+		if got := isUnsolicited("telnet|admin:extron@unsolicited", tt.cmd, tt.line); got != tt.wantU {
+			t.Errorf("isUnsolicited(%q, %q) = %v, want %v", tt.cmd, tt.line, got, tt.wantU)
+		}
+	}
+}
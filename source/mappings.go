@@ -1,5 +1,7 @@
 package main
 
+import "regexp"
+
 // Mappings //
 var errorResponsesMap = map[string]string{
 	"E01": "Invalid input number",
@@ -66,19 +68,62 @@ var internalGetCmdMap = map[string]map[string]string{
 	"videoroute": {
 		"Matrix Switcher": "%s%%\r", // arg1: output name
 		"Scaler":          "&\r",
+		"IN1808":          "%s&\r", // arg1: tie output (see tieOutputs in the profile)
 		"Switcher":        "!\r",
 	},
 	"audioandvideoroute": {
-		"Scaler": "!\r",
+		"Scaler":   "!\r",
+		"IN1808":   "%s!\r", // arg1: tie output
+		"Switcher": "!\r",
+	},
+	"audioroute": {
+		"Matrix Switcher": "%s$\r", // arg1: output name
+		"Scaler":          "$\r",
+		"IN1808":          "%s$\r", // arg1: tie output
+	},
+	"presets": {
+		"Matrix Switcher": "\x1B%sPNAM\r", // arg1: preset number
+		"Scaler":          "\x1B%sPNAM\r", // arg1: preset number
+	},
+	"inputname": {
+		"Matrix Switcher": "\x1B%sNI\r", // arg1: input number
+		"Scaler":          "\x1B%sNI\r", // arg1: input number
+		"Audio Processor": "\x1B%sNI\r", // arg1: mic/line input number
+	},
+	"hdcpinputstatus": {
+		"Matrix Switcher":        "\x1BI%sHDCP\r", // arg1: input number
+		"Scaler":                 "\x1BI%sHDCP\r", // arg1: input number
+		"Switcher":               "\x1BI%sHDCP\r", // arg1: input number
+		"Distribution Amplifier": "\x1BI%sHDCP\r", // arg1: input number
+	},
+	"hdcpoutputstatus": {
+		"Matrix Switcher":        "\x1BO%sHDCP\r", // arg1: output number
+		"Scaler":                 "\x1BO%sHDCP\r", // arg1: output number
+		"Switcher":               "\x1BO%sHDCP\r", // arg1: output number
+		"Distribution Amplifier": "\x1BO%sHDCP\r", // arg1: output number
+	},
+	"hdcpauthorization": {
+		"Matrix Switcher": "\x1BE%sHDCP\r", // arg1: input number
+		"Scaler":          "\x1BE%sHDCP\r", // arg1: input number
+		"Switcher":        "\x1BE%sHDCP\r", // arg1: input number
+	},
+	"edidpresets": {
+		"Matrix Switcher": "\x1BN%sEDID\r", // arg1: EDID slot
+		"Scaler":          "\x1BN%sEDID\r", // arg1: EDID slot
+	},
+	"edid": {
+		"Matrix Switcher": "\x1BA%sEDID\r", // arg1: input number
+		"Scaler":          "\x1BA%sEDID\r", // arg1: input number
+	},
+	"outputname": { // scalers have one output, it isn't named
+		"Matrix Switcher": "\x1B%sNO\r", // arg1: output number
+		"Audio Processor": "\x1B%sNO\r", // arg1: output number
 	},
-	//"audioroute": {
-	//	"Matrix Switcher": "%s$\r", // arg1: output name
-	//	"Scaler":          "$\r",
-	//},
 	"audiomute": {
 		"Matrix Switcher": "%s*B\r",        // arg1: output name
 		"Scaler":          "\x1BD%sGRPM\r", // arg1: X48 group number
 		"Switcher":        "\x1BAFMT\r",
+		"IN1804":          "Z\r", // one program mute
 	},
 	"videomute": {
 		"Matrix Switcher":        "\x1BVM\r",
@@ -87,20 +132,47 @@ var internalGetCmdMap = map[string]map[string]string{
 		"Distribution Amplifier": "B\r",
 	},
 	"volume": {
-		"Scaler": "\x1BD%sGRPM\r", // arg1: x46 volume group number.  Does not work with 1804
+		"Scaler": "\x1BD%sGRPM\r", // arg1: x46 volume group number
+		"IN1804": "V\r",           // one program volume, whole dB on a dBFS scale (see transformVolumeIN1804)
+	},
+	"groupvolume": { // also group mutes, they are read the same way
+		"Audio Processor": "\x1BD%sGRPM\r", // arg1: group number
+	},
+	"groupsoftlimits": { // internal, for groupvolume
+		"Audio Processor": "\x1BL%sGRPM\r", // arg1: group number
 	},
-	"matrixmute": {
+	"matrixmute": { // also the DMP gain block mutes (see dmpGainBlocks)
 		"Audio Processor": "\x1BM%sAU\r", // arg1: Object ID Number (mixpoint)
 	},
-	"matrixvolume": {
+	"matrixvolume": { // also the DMP gain blocks (see dmpGainBlocks)
 		"Audio Processor": "\x1BG%sAU\r", // arg1: Object ID Number (mixpoint)
 	},
+	"audioandvideomute": { // audio leg only, the video leg goes through "videomute"
+		"Matrix Switcher":        "%sZ\r",        // arg1: audio output number
+		"Distribution Amplifier": "\x1B%sAFMT\r", // arg1: output number
+		"Switcher":               "\x1BAFMT\r",
+	},
+	"occupancystatus": {
+		"Controller": "%s]\r", // arg1: digital input / contact closure port
+	},
+	"setstate": {
+		"Controller": "%sO\r", // arg1: relay number
+	},
+	"power": {
+		"Power Controller": "%sPC\r", // arg1: outlet number. IPL T PCS4 / PC1
+	},
+
+	"powersave": { // the Extron unit itself, not the display
+		"Scaler":          "\x1BPSAV\r",
+		"Matrix Switcher": "\x1BPSAV\r",
+	},
 
 	//"viewvideoinput":          "&\r",       // non-matrix
 	//"viewcurrentinput":        "!\r",       // non-matrix
 
-	//"viewallvideoties":        "\x1B0*1*1VC\r", // matrix
-	//"viewallaudioties":        "\x1B0*1*2VC\r", // matrix
+	"matrixties": {
+		"Matrix Switcher": "\x1B0*%s*%sVC\r", // arg1: first output, arg2: video (1) or audio (2). Up to 16 outputs per answer
+	},
 
 	//"readvideooutputtie":      "%s%%\r",        // arg1: output name, matrix
 
@@ -113,13 +185,51 @@ var internalGetCmdMap = map[string]map[string]string{
 var internalSetCmdMap = map[string]map[string]string{
 	"videoroute": {
 		"Matrix Switcher": "%s*%s%%\r", // arg1: input name | arg2: output name
-		"Scaler":          "%s&\r",     // FIXME: 1808 is different | arg1: input name
+		"Scaler":          "%s&\r",     // arg1: input name
+		"IN1808":          "%s*%s&\r",  // arg1: input name | arg2: tie output (see tieOutputs in the profile)
 	},
 	"audioandvideoroute": {
 		"Matrix Switcher": "%s*%s!\r", // arg1: input name | arg2: output name
-		"Scaler":          "%s!\r",    // FIXME: 1808 is different | arg1: input name
+		"Scaler":          "%s!\r",    // arg1: input name
+		"IN1808":          "%s*%s!\r", // arg1: input name | arg2: tie output
 		"Switcher":        "%s!\r",    // arg1: input name
 	},
+	"audioroute": {
+		"Matrix Switcher": "%s*%s$\r", // arg1: input name | arg2: output name
+		"Scaler":          "%s$\r",    // arg1: input name
+		"IN1808":          "%s*%s$\r", // arg1: input name | arg2: tie output
+	},
+	"preset": {
+		"Matrix Switcher": "%s.\r", // arg1: preset number
+		"Scaler":          "%s.\r", // arg1: preset number
+		"Audio Processor": "%s.\r", // arg1: preset number
+	},
+	"inputname": {
+		"Matrix Switcher": "\x1B%s,%sNI\r", // arg1: input number | arg2: name
+		"Scaler":          "\x1B%s,%sNI\r", // arg1: input number | arg2: name
+		"Audio Processor": "\x1B%s,%sNI\r", // arg1: mic/line input number | arg2: name
+	},
+	"edid": {
+		"Matrix Switcher": "\x1BA%s*%sEDID\r", // arg1: input number | arg2: EDID slot
+		"Scaler":          "\x1BA%s*%sEDID\r", // arg1: input number | arg2: EDID slot
+	},
+	"edidcapture": { // EDID Minder: save the EDID of the display on an output to a user slot
+		"Matrix Switcher": "\x1BS%s*%sEDID\r", // arg1: output number | arg2: EDID user slot
+		"Scaler":          "\x1BS%s*%sEDID\r", // arg1: output number | arg2: EDID user slot
+	},
+	"hdcpauthorization": { // off makes sources send unencrypted video, or nothing if they require HDCP
+		"Matrix Switcher": "\x1BE%s*%sHDCP\r", // arg1: input number | arg2: 1 (on) or 0 (off)
+		"Scaler":          "\x1BE%s*%sHDCP\r", // arg1: input number | arg2: 1 (on) or 0 (off)
+		"Switcher":        "\x1BE%s*%sHDCP\r", // arg1: input number | arg2: 1 (on) or 0 (off)
+	},
+	"outputname": {
+		"Matrix Switcher": "\x1B%s,%sNO\r", // arg1: output number | arg2: name
+		"Audio Processor": "\x1B%s,%sNO\r", // arg1: output number | arg2: name
+	},
+	"savepreset": { // DMP presets are made in DSP Configurator
+		"Matrix Switcher": "%s,\r", // arg1: preset number
+		"Scaler":          "%s,\r", // arg1: preset number
+	},
 	"videomute": {
 		"Matrix Switcher":        "%s*%sB\r", // arg1: output name
 		"Scaler":                 "%s*%sB\r", // arg1: output name
@@ -136,15 +246,42 @@ var internalSetCmdMap = map[string]map[string]string{
 		"Switcher":               "\x1B%sAFMT\r",     // arg1: mute state (1,0)
 		"Scaler":                 "\x1BD%s*%sGRPM\r", // arg1: X48 mute group number, arg2 mute state (1,0)
 		"Distribution Amplifier": "\x1B%s*%sAFMT\r",  // arg1: output name, arg2: mute state (1,0)
+		"IN1804":                 "%[2]sZ\r",         // arg2: mute state (1,0), there are no groups
 	},
 	"volume": {
 		"Scaler": "\x1BD%s*%sGRPM\r", // arg1: x46 volume group number, arg2: x47 value (-1000 to 12)
+		"IN1804": "%[2]sV\r",         // arg2: whole dB, -100 to 0 (dBFS), there are no groups
+	},
+	"groupvolume": {
+		"Audio Processor": "\x1BD%s*%sGRPM\r", // arg1: group number, arg2: level in tenths of dB, inside the soft limits
+	},
+	"groupmute": {
+		"Audio Processor": "\x1BD%s*%sGRPM\r", // arg1: group number, arg2: mute (1) or unmute (0)
 	},
-	"matrixmute": {
+	"matrixmute": { // also the DMP gain block mutes
 		"Audio Processor": "\x1BM%s*%sAU\r", // arg1: Object ID Number, arg2: mute (1) or unmute (0)
 	},
-	"matrixvolume": {
-		"Audio Processor": "\x1BG%s*%sAU\r", // arg1: Object ID Number, arg2: level in tenths of decibels (-1000 to 120)
+	"matrixvolume": { // also the DMP gain blocks, which have their own ranges
+		"Audio Processor": "\x1BG%s*%sAU\r", // arg1: Object ID Number, arg2: level in tenths of decibels (-1000 to 120 on mix points)
+	},
+	"audioandvideomute": { // audio leg only, the video leg goes through "videomute"
+		"Matrix Switcher":        "%s*%sZ\r",        // arg1: audio output number, arg2: mute state (1,0)
+		"Distribution Amplifier": "\x1B%s*%sAFMT\r", // arg1: output number, arg2: mute state (1,0)
+		"Switcher":               "\x1B%sAFMT\r",    // arg1: mute state (1,0)
+	},
+	"setstate": {
+		"Controller": "%s*%sO\r", // arg1: relay number, arg2: closed (1) or open (0)
+	},
+	"triggerstate": {
+		"Controller": "%s*3*%sO\r", // arg1: relay number, arg2: pulse length in 20ms ticks (1-65535)
+	},
+	"power": {
+		"Power Controller": "%s*%sPC\r", // arg1: outlet number, arg2: on (1) or off (0)
+	},
+
+	"powersave": { // the Extron unit itself, not the display
+		"Scaler":          "\x1B%sPSAV\r", // arg1: power save mode, 0 is normal, 1 is power save
+		"Matrix Switcher": "\x1B%sPSAV\r",
 	},
 
 	//"globalvideomute":        "1*B\r",
@@ -153,11 +290,9 @@ var internalSetCmdMap = map[string]map[string]string{
 
 	//"audioandvideoroute": "%s!\r",        // arg1: input name, non-matrix
 	//"videoroute":         "%s&\r",        // arg1: input name, non-matrix
-	//"audioroute":         "%s$\r",        // arg1: input name, non-matrix
 	//"setloopoutinput":    "\x1B%sLOUT\r", // arg1: input name
 
 	//"tieaudioandvideoroute": "%s*%s!\r", // arg1: input name | arg2: output name, matrix
-	//"tieaudioroute":         "%s*%s$\r", // arg1: input name | arg2: output name, matrix
 
 	//"mutevideooutput":   "%s*1B\r", // arg1: output name
 	//"mutevideoandsync":  "%s*2B\r", // arg1: output name
@@ -168,155 +303,119 @@ var internalSetCmdMap = map[string]map[string]string{
 // Make sure all future endpoints are added here.
 // function args are socketKey, endpoint, arg1, arg2, arg3
 var getFunctionsMap = map[string]func(string, string, string, string, string) (string, error){
-	"power":              notImplemented, // TODO
-	"volume":             getVolumeDo,
-	"videoroute":         getVideoRouteDo,
-	"audioandvideoroute": getAudioAndVideoRouteDo,
-	"audiomute":          getAudioMuteDo,
-	"videomute":          getVideoMuteDo,
-	"audioandvideomute":  notImplemented, // TODO
-	"inputstatus":        getInputStatusDo,
-	"occupancystatus":    notImplemented, // TODO
-	"matrixmute":         getMatrixMuteDo,
-	"matrixvolume":       getMatrixVolumeDo,
-	"setstate":           notImplemented, // TODO
+	"power":                getPowerDo,
+	"powersave":            getPowerSaveDo,
+	"volume":               getVolumeDo,
+	"volumedb":             getVolumeDbDo,
+	"videoroute":           getVideoRouteDo,
+	"audioandvideoroute":   getAudioAndVideoRouteDo,
+	"audioroute":           getAudioRouteDo,
+	"presets":              getPresetsDo,
+	"inputname":            getInputNameDo,
+	"outputname":           getOutputNameDo,
+	"names":                getNamesDo,
+	"hdcpinputstatus":      getHDCPInputStatusDo,
+	"hdcpoutputstatus":     getHDCPOutputStatusDo,
+	"hdcpstatus":           getHDCPStatusDo,
+	"hdcpauthorization":    getHDCPAuthorizationDo,
+	"edidpresets":          getEDIDPresetsDo,
+	"edid":                 getEDIDDo,
+	"audiomute":            getAudioMuteDo,
+	"videomute":            getVideoMuteDo,
+	"audioandvideomute":    getAudioAndVideoMuteDo,
+	"inputstatus":          getInputStatusDo,
+	"occupancystatus":      getOccupancyStatusDo,
+	"matrixmute":           getMatrixMuteDo,
+	"matrixvolume":         getMatrixVolumeDo,
+	"matrixvolumedb":       getMatrixVolumeDbDo,
+	"dmpmixpoint":          getDmpMixPointDo,
+	"dmpinputgain":         getDmpGainDo,
+	"dmpinputmute":         getDmpMuteDo,
+	"dmppremixergain":      getDmpGainDo,
+	"dmppremixermute":      getDmpMuteDo,
+	"dmpvirtualreturngain": getDmpGainDo,
+	"dmpvirtualreturnmute": getDmpMuteDo,
+	"dmpoutputvolume":      getDmpGainDo,
+	"dmpoutputmute":        getDmpMuteDo,
+	"groupvolume":          getGroupVolumeDo,
+	"groupmute":            getGroupMuteDo,
+	"matrixties":           getMatrixTiesDo,
+	"setstate":             getStateDo,
 }
 
 // Maps set endpoints to set functions so we can call them dynamically.
 // Make sure all future endpoints are added here.
 // function args are socketKey, endpoint, arg1, arg2, arg3
 var setFunctionsMap = map[string]func(string, string, string, string, string) (string, error){
-	"power":              notImplemented, // TODO
+	"power":              setPowerDo,
+	"powersave":          setPowerSaveDo,
 	"volume":             setVolumeDo,
+	"volumedb":           setVolumeDbDo,
 	"videoroute":         setVideoRouteDo,
 	"audioandvideoroute": setAudioAndVideoRoute,
+	"audioroute":         setAudioRouteDo,
+	"preset":             setPresetDo,
+	"savepreset":         savePresetDo,
+	"inputname":          setInputNameDo,
+	"outputname":         setOutputNameDo,
+	"hdcpauthorization":  setHDCPAuthorizationDo,
+	"edid":               setEDIDDo,
+	"edidcapture":        setEDIDCaptureDo,
 	"audiomute":          setAudioMuteDo,
 	"videomute":          setVideoMuteDo,
 	//"videosyncmute":      setVideoSyncMuteDo,
-	"audioandvideomute": notImplemented, // TODO
-	"matrixmute":        setMatrixMuteDo,
-	"matrixvolume":      setMatrixVolumeDo,
-	"setstate":          notImplemented, // TODO
-	"triggerstate":      notImplemented, // TODO
-	"timedtriggerstate": notImplemented, // TODO
+	"audioandvideomute":    setAudioAndVideoMuteDo,
+	"matrixmute":           setMatrixMuteDo,
+	"matrixvolume":         setMatrixVolumeDo,
+	"matrixvolumedb":       setMatrixVolumeDbDo,
+	"dmpinputgain":         setDmpGainDo,
+	"dmpinputmute":         setDmpMuteDo,
+	"dmppremixergain":      setDmpGainDo,
+	"dmppremixermute":      setDmpMuteDo,
+	"dmpvirtualreturngain": setDmpGainDo,
+	"dmpvirtualreturnmute": setDmpMuteDo,
+	"dmpoutputvolume":      setDmpGainDo,
+	"dmpoutputmute":        setDmpMuteDo,
+	"groupvolume":          setGroupVolumeDo,
+	"groupmute":            setGroupMuteDo,
+	"setstate":             setStateDo,
+	"triggerstate":         triggerStateDo,
+	"timedtriggerstate":    timedTriggerStateDo,
 }
 
-// Input or Output Names : Index of where to find them in string returns (ex: video mute)
-type videoIO_Map struct {
-	inputs  map[string]int
-	outputs map[string]int
+// Relay ports on controllers, by part of the model name
+var controllerRelayCounts = map[string]int{
+	"CR48":         8, // IPL T CR48
+	"IPCP Pro 550": 8,
+	"IPCP Pro 350": 4,
+	"IPCP Pro 250": 2,
 }
 
-var crossPoint84Map = videoIO_Map{
-	inputs: map[string]int{
-		"1": 0,
-		"2": 1,
-		"3": 2,
-		"4": 3,
-		"5": 4,
-		"6": 5,
-		"7": 6,
-		"8": 7,
-	},
-	outputs: map[string]int{
-		"1":  0,
-		"2":  1,
-		"3A": 2,
-		"3B": 3,
-		"4A": 4,
-		"4B": 5,
-	},
+// I/O name limits, by device type.  Names are checked before they are sent
+var maxNameLengths = map[string]int{
+	"Matrix Switcher": 12,
+	"Scaler":          16,
+	"Audio Processor": 12,
 }
 
-var crossPoint86Map = videoIO_Map{
-	inputs: map[string]int{
-		"1": 0,
-		"2": 1,
-		"3": 2,
-		"4": 3,
-		"5": 4,
-		"6": 5,
-		"7": 6,
-		"8": 7,
-	},
-	outputs: map[string]int{
-		"1":  0,
-		"2":  1,
-		"3A": 2,
-		"3B": 3,
-		"4A": 4,
-		"4B": 5,
-		"5":  6,
-		"6":  7,
-	},
-}
+const invalidNameCharacters = "+~,@=`[]{}<>'\";:|\\?" // SIS uses these as delimiters
 
-var crossPoint108Map = videoIO_Map{
-	inputs: map[string]int{
-		"1":  0,
-		"2":  1,
-		"3":  2,
-		"4":  3,
-		"5":  4,
-		"6":  5,
-		"7":  6,
-		"8":  7,
-		"9":  8,
-		"10": 9,
-	},
-	outputs: map[string]int{
-		"1":  0,
-		"2":  1,
-		"3":  2,
-		"4":  3,
-		"5A": 4,
-		"5B": 5,
-		"6A": 6,
-		"6B": 7,
-		"7":  8,
-		"8":  9,
-	},
+// Digits in the input and output HDCP status responses.
+// For inputs "present" is a source, for outputs it's a sink.  "3" is HDCP 2.x on 4K models
+type hdcpCode struct {
+	Present bool
+	HDCP    string
 }
 
-// LoopOut is handled as a special case
-var in180xMap = videoIO_Map{
-	inputs: map[string]int{
-		"1": 0,
-		"2": 1,
-		"3": 2,
-		"4": 3,
-		"5": 4,
-		"6": 5,
-		"7": 6,
-		"8": 7,
-	},
-	outputs: map[string]int{
-		"1A": 0,
-		"1B": 1,
-		// LoopOut is not in the map, it is handled separately
-	},
+var hdcpStatusCodes = map[string]hdcpCode{
+	"0": {false, "none"},
+	"1": {true, "1.4"},
+	"2": {true, "none"},
+	"3": {true, "2.x"},
 }
 
-// Audio group map for IN 160x scalers
-// These are 'X46' variables in the manual.
-var in160xGroupAudioVolumeMap = map[string]string{
-	"programvolume":  "1",
-	"micvolume":      "3",
-	"variablevolume": "8",
-}
-
-// Audio group mute map for IN 160x scalers
-// These are 'X48' variables in the manual.
-var in160xGroupAudioMuteMap = map[string]string{
-	"programmute": "2",
-	"micmute":     "4",
-	"outputmute":  "7",
-}
-
-// Video mute map for IN 160x scalers
-// These are 'X2" variables in the manual
-var in160xVideoMuteMap = map[string]string{
-	"1A": "1", // HDMI
-	"1B": "2", // HDMI
-	"1C": "3", // DTP / HDbT output
-}
+// EDID descriptions, ex: "1920x1080 @60Hz 2ch", "3840x2160 @30Hz Multi-ch", "Output 2" (follows the display on output 2)
+var edidResolutionPattern = regexp.MustCompile(`(\d{3,4})\s*x\s*(\d{3,4})`)
+var edidRefreshPattern = regexp.MustCompile(`@\s*(\d+(?:\.\d+)?)\s*Hz`)
+var edidAudioPattern = regexp.MustCompile(`(?i)\b(2\s*ch|multi-?\s*ch|[5-8]\.1\s*ch|lpcm)\b`)
+var edidFollowPattern = regexp.MustCompile(`^Output\s*(\d+)$`)
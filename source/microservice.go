@@ -2,6 +2,7 @@ package main
 
 import (
 	"errors"
+	"os"
 	"time"
 
 	"github.com/mefranklin6/microservice-framework/framework" //TODO: Change after PR5
@@ -36,6 +37,23 @@ var maintenancePeriod = struct {                // lets the connection drop duri
 	Start: time.Date(0, 1, 1, 2, 0, 0, 0, time.UTC), // 2:00 AM
 	End:   time.Date(0, 1, 1, 3, 0, 0, 0, time.UTC), // 3:00 AM
 }
+var profileDir = os.Getenv("SIS_PROFILE_DIR")       // device profiles here are loaded on top of the built-in ones (see profiles.go)
+var transcriptDir = os.Getenv("SIS_TRANSCRIPT_DIR") // when set, record every command/response per device here
+var curveFile = os.Getenv("SIS_VOLUME_CURVES")      // extra volume curves and the curve each endpoint uses (see volume_curves.go)
+var verboseMode = true                              // have devices push tie, mute and signal changes (see events.go)
+var verboseModeCmd = "\x1B1CV\r"                    // verbose only. Mode 3 would also tag query replies, which the parsers don't expect
+var eventReaderInterval = 1 * time.Second           // how often to look for notifications between commands
+var eventReaderWait = 50 * time.Millisecond         // how long each look holds the socket
+var maxUnsolicitedLines = 10                        // notifications to skip while waiting for a reply before giving up
+var eventStreamAddr = ":8080"                       // Server-Sent Events stream of device state changes (see stream.go), "" to disable
+var eventStreamHeartbeat = 15 * time.Second         // keeps idle streams from being closed by proxies
+var stateStaleAfter = 2 * keepAlivePollingInterval  // device state values older than this are flagged stale
+var refreshFor = 10 * time.Minute                   // the keepalive refresh stops re-reading a value nobody has read or set for this long
+var relayPulseDuration = 500 * time.Millisecond     // triggerstate pulse length
+var maxRelayPulse = 30 * time.Second                // longest timedtriggerstate. Calls wait for the pulse to end to confirm it
+var occupancyDebounce = 5 * time.Second             // an occupancy input must hold a new state this long before it is reported
+var defaultPresetCount = 32                         // global presets to list for models whose profile doesn't say
+var maxEDIDSlots = 128                              // the EDID table is read until the device runs out, or this many slots
 
 // Every microservice using this golang microservice framework needs to provide this function to invoke functions to do sets.
 // socketKey is the network connection for the framework to use to communicate with the device.
@@ -62,6 +80,8 @@ func doDeviceSpecificSet(socketKey string, setting string, arg1 string, arg2 str
 		return specialEndpointSet(socketKey, "videoroute", arg1, arg2, "") // arg1: output, arg2: input
 	case "audioandvideoroute":
 		return specialEndpointSet(socketKey, "audioandvideoroute", arg1, arg2, "") // arg1: output, arg2: input
+	case "audioroute":
+		return specialEndpointSet(socketKey, "audioroute", arg1, arg2, "") // arg1: output, arg2: input
 	case "videomute":
 		return specialEndpointSet(socketKey, "videomute", arg1, arg2, "") // arg1: output, arg2: bool
 	// case "videosyncmute":
@@ -70,14 +90,56 @@ func doDeviceSpecificSet(socketKey string, setting string, arg1 string, arg2 str
 		return specialEndpointSet(socketKey, "audiomute", arg1, arg2, "") // arg1: output, arg2: bool
 	case "volume":
 		return specialEndpointSet(socketKey, "volume", arg1, arg2, "") // arg1: channel or group name, arg2: volume percentage
+	case "volumedb":
+		return specialEndpointSet(socketKey, "volumedb", arg1, arg2, "") // arg1: channel or group name, arg2: volume in dB, ex: -3.5
 	case "matrixmute":
 		return specialEndpointSet(socketKey, "matrixmute", arg1, arg2, arg3) // arg1: input, arg2: output, arg3: state (true|false))
 	case "matrixvolume":
 		return specialEndpointSet(socketKey, "matrixvolume", arg1, arg2, arg3) // arg1: input, arg2: output, arg3: volume (0-100)
+	case "matrixvolumedb":
+		return specialEndpointSet(socketKey, "matrixvolumedb", arg1, arg2, arg3) // arg1: input, arg2: output, arg3: volume in dB, ex: -3.5
+	case "dmpinputgain", "dmppremixergain", "dmpvirtualreturngain", "dmpoutputvolume":
+		return specialEndpointSet(socketKey, setting, arg1, arg2, "") // arg1: channel, arg2: volume percentage over the block's range
+	case "dmpinputmute", "dmppremixermute", "dmpvirtualreturnmute", "dmpoutputmute":
+		return specialEndpointSet(socketKey, setting, arg1, arg2, "") // arg1: channel, arg2: bool
+	case "groupvolume":
+		return specialEndpointSet(socketKey, "groupvolume", arg1, arg2, "") // arg1: DMP group number or name, arg2: volume percentage inside the soft limits
+	case "groupmute":
+		return specialEndpointSet(socketKey, "groupmute", arg1, arg2, "") // arg1: DMP group number or name, arg2: bool
+	case "audioandvideomute":
+		return specialEndpointSet(socketKey, "audioandvideomute", arg1, arg2, "") // arg1: output, arg2: bool
+	case "setstate":
+		return specialEndpointSet(socketKey, "setstate", arg1, arg2, "") // arg1: relay, arg2: bool (true is closed)
+	case "triggerstate":
+		return specialEndpointSet(socketKey, "triggerstate", arg1, "", "") // arg1: relay
+	case "timedtriggerstate":
+		return specialEndpointSet(socketKey, "timedtriggerstate", arg1, arg2, "") // arg1: relay, arg2: seconds, ex: 2.5
+	case "power":
+		return specialEndpointSet(socketKey, "power", arg1, arg2, "") // arg1: outlet or "all", arg2: bool.  IPL T PDUs only
+	case "powersave":
+		return specialEndpointSet(socketKey, "powersave", arg1, "", "") // arg1: bool, true puts the Extron unit itself (not the display) in power save
+	case "preset":
+		return specialEndpointSet(socketKey, "preset", arg1, "", "") // arg1: preset number or name, ex: room modes like "lecture"
+	case "savepreset":
+		return specialEndpointSet(socketKey, "savepreset", arg1, "", "") // arg1: preset number
+	case "inputname":
+		return specialEndpointSet(socketKey, "inputname", arg1, arg2, "") // arg1: input, arg2: name
+	case "outputname":
+		return specialEndpointSet(socketKey, "outputname", arg1, arg2, "") // arg1: output, arg2: name
+	case "hdcpauthorization":
+		return specialEndpointSet(socketKey, "hdcpauthorization", arg1, arg2, "") // arg1: input, arg2: bool
+	case "edid":
+		return specialEndpointSet(socketKey, "edid", arg1, arg2, arg3) // arg1: input, arg2: EDID slot or "outputfollow", arg3: output to follow
+	case "edidcapture":
+		return specialEndpointSet(socketKey, "edidcapture", arg1, arg2, "") // arg1: output, arg2: EDID user slot
 	case "stopallkeepalivepolling":
 		return stopAllKeepAlivePolling()
 	case "restartkeepalivepolling":
 		return restartKeepAlivePolling()
+	case "starttranscriptrecording":
+		return startTranscriptRecording(socketKey)
+	case "stoptranscriptrecording":
+		return stopTranscriptRecording(socketKey)
 		//case "special1":
 		//	return setSpecial1(socketKey, arg1, arg2)
 		//case "special2":
@@ -116,6 +178,8 @@ func doDeviceSpecificGet(socketKey string, setting string, arg1 string, arg2 str
 		return specialEndpointGet(socketKey, "videoroute", arg1, "", "") // arg1: output (if not matrix, use '1' for arg1)
 	case "audioandvideoroute":
 		return specialEndpointGet(socketKey, "audioandvideoroute", arg1, "", "") // arg1: output
+	case "audioroute":
+		return specialEndpointGet(socketKey, "audioroute", arg1, "", "") // arg1: output (if not matrix, use '1' for arg1)
 	case "inputstatus":
 		return specialEndpointGet(socketKey, "inputstatus", arg1, "", "") // arg1: input
 	case "videomute":
@@ -124,10 +188,58 @@ func doDeviceSpecificGet(socketKey string, setting string, arg1 string, arg2 str
 		return specialEndpointGet(socketKey, "audiomute", arg1, "", "") // arg1: mute group name
 	case "volume":
 		return specialEndpointGet(socketKey, "volume", arg1, "", "") // arg1: channel or group name
+	case "volumedb":
+		return specialEndpointGet(socketKey, "volumedb", arg1, "", "") // arg1: channel or group name
 	case "matrixmute":
 		return specialEndpointGet(socketKey, "matrixmute", arg1, arg2, "") // arg1: input, arg2: output
 	case "matrixvolume":
 		return specialEndpointGet(socketKey, "matrixvolume", arg1, arg2, "") // arg1: input, arg2: output
+	case "matrixvolumedb":
+		return specialEndpointGet(socketKey, "matrixvolumedb", arg1, arg2, "") // arg1: input, arg2: output
+	case "dmpmixpoint":
+		return specialEndpointGet(socketKey, "dmpmixpoint", arg1, "", "") // arg1: DMP object ID, ex: 21205
+	case "dmpinputgain", "dmppremixergain", "dmpvirtualreturngain", "dmpoutputvolume":
+		return specialEndpointGet(socketKey, setting, arg1, "", "") // arg1: channel, ex: 3, or A for virtual returns
+	case "dmpinputmute", "dmppremixermute", "dmpvirtualreturnmute", "dmpoutputmute":
+		return specialEndpointGet(socketKey, setting, arg1, "", "") // arg1: channel
+	case "groupvolume":
+		return specialEndpointGet(socketKey, "groupvolume", arg1, "", "") // arg1: DMP group number or name
+	case "groupmute":
+		return specialEndpointGet(socketKey, "groupmute", arg1, "", "") // arg1: DMP group number or name
+	case "audioandvideomute":
+		return specialEndpointGet(socketKey, "audioandvideomute", arg1, "", "") // arg1: output (if not matrix, use '1' for arg1)
+	case "occupancystatus":
+		return specialEndpointGet(socketKey, "occupancystatus", arg1, arg2, "") // arg1: input port, arg2 (optional): polarity "normal" or "inverted", kept for later reads
+	case "setstate":
+		return specialEndpointGet(socketKey, "setstate", arg1, "", "") // arg1: relay
+	case "power":
+		return specialEndpointGet(socketKey, "power", arg1, "", "") // arg1: outlet or "all".  IPL T PDUs only
+	case "powersave":
+		return specialEndpointGet(socketKey, "powersave", "", "", "") // power save mode of the Extron unit itself
+	case "matrixties":
+		return specialEndpointGet(socketKey, "matrixties", "", "", "") // every video and audio tie as JSON
+	case "presets":
+		return specialEndpointGet(socketKey, "presets", "", "", "") // preset names as JSON
+	case "inputname":
+		return specialEndpointGet(socketKey, "inputname", arg1, "", "") // arg1: input
+	case "outputname":
+		return specialEndpointGet(socketKey, "outputname", arg1, "", "") // arg1: output
+	case "names":
+		return specialEndpointGet(socketKey, "names", "", "", "") // every input and output name as JSON
+	case "hdcpinputstatus":
+		return specialEndpointGet(socketKey, "hdcpinputstatus", arg1, "", "") // arg1: input
+	case "hdcpoutputstatus":
+		return specialEndpointGet(socketKey, "hdcpoutputstatus", arg1, "", "") // arg1: output
+	case "hdcpstatus":
+		return specialEndpointGet(socketKey, "hdcpstatus", "", "", "") // every input and output as JSON
+	case "hdcpauthorization":
+		return specialEndpointGet(socketKey, "hdcpauthorization", arg1, "", "") // arg1: input
+	case "edidpresets":
+		return specialEndpointGet(socketKey, "edidpresets", "", "", "") // the EDID table as JSON
+	case "edid":
+		return specialEndpointGet(socketKey, "edid", arg1, "", "") // arg1: input
+	case "devicestate":
+		return getDeviceStateDo(socketKey) // everything known about the device, without asking it
 	}
 
 	// If we get here, we didn't recognize the setting.  Send an error back to the config writer who had a bad URL.
@@ -139,5 +251,8 @@ func doDeviceSpecificGet(socketKey string, setting string, arg1 string, arg2 str
 
 func main() {
 	setFrameworkGlobals()
+	loadVolumeCurves() // before the profiles, which name curves
+	loadDeviceProfiles()
+	startEventStreamServer()
 	framework.Startup()
 }
@@ -0,0 +1,115 @@
+package main
+
+// Occupancy sensors wired into controller digital inputs and contact closures (IPL T CR48, IPCP).
+// Raw input readings, from gets and from verbose-mode notifications, go through a debounce
+// so a sensor chattering at the edge of its range doesn't flip the room between occupied and vacant.
+// Each port starts with the polarity from the profile's invertedInputs, so notifications that arrive before the first get
+// are read the right way around.  A get can change it, and the port keeps it until the next change.
+
+import (
+	"sync"
+	"time"
+)
+
+type occupancyInput struct {
+	inverted     bool // the input is closed (1) when the room is vacant
+	known        bool // we have seen at least one reading
+	occupied     bool // debounced state, what the endpoint reports
+	pending      *time.Timer
+	pendingState bool
+}
+
+var occupancyInputs = make(map[string]*occupancyInput) // socketKey/port -> input
+var occupancyInputsMutex sync.Mutex
+
+// Sets the polarity of a port.  The debounced state was read the other way around, so it flips with it
+// and is published again.  A change that was waiting out the debounce starts over with the next reading.
+func setOccupancyPolarity(socketKey string, port string, inverted bool, source string) {
+	occupancyInputsMutex.Lock()
+	input := occupancyInputFor(socketKey, port)
+	if input.inverted == inverted {
+		occupancyInputsMutex.Unlock()
+		return
+	}
+	input.inverted = inverted
+	if input.pending != nil {
+		input.pending.Stop()
+		input.pending = nil
+	}
+	if !input.known {
+		occupancyInputsMutex.Unlock()
+		return
+	}
+	input.occupied = !input.occupied
+	occupied := input.occupied
+	occupancyInputsMutex.Unlock()
+	publishOccupancy(socketKey, port, source, occupied)
+}
+
+// Feeds a raw reading ("1" closed, "0" open) into the debounce and returns the debounced occupancy.
+// A new state has to hold for occupancyDebounce before it is reported.
+func observeOccupancyInput(socketKey string, port string, raw string, source string) bool {
+	occupancyInputsMutex.Lock()
+	input := occupancyInputFor(socketKey, port)
+	occupied := (raw == "1") != input.inverted
+
+	if !input.known || (occupied != input.occupied && occupancyDebounce <= 0) {
+		input.known = true
+		input.occupied = occupied
+		occupancyInputsMutex.Unlock()
+		publishOccupancy(socketKey, port, source, occupied)
+		return occupied
+	}
+	defer occupancyInputsMutex.Unlock()
+
+	if occupied == input.occupied { // back where it was before the window ran out
+		if input.pending != nil {
+			input.pending.Stop()
+			input.pending = nil
+		}
+		return input.occupied
+	}
+	if input.pending != nil && input.pendingState == occupied {
+		return input.occupied // already waiting on this change
+	}
+
+	if input.pending != nil {
+		input.pending.Stop()
+	}
+	input.pendingState = occupied
+	var timer *time.Timer
+	timer = time.AfterFunc(occupancyDebounce, func() {
+		occupancyInputsMutex.Lock()
+		if input.pending != timer { // cancelled or replaced
+			occupancyInputsMutex.Unlock()
+			return
+		}
+		input.pending = nil
+		input.occupied = occupied
+		occupancyInputsMutex.Unlock()
+		publishOccupancy(socketKey, port, source, occupied)
+	})
+	input.pending = timer
+	return input.occupied
+}
+
+// Caller holds occupancyInputsMutex.  A port seen for the first time takes its polarity from the profile.
+func occupancyInputFor(socketKey string, port string) *occupancyInput {
+	key := socketKey + "/" + port
+	input, exists := occupancyInputs[key]
+	if !exists {
+		input = &occupancyInput{}
+		if profile := profileForModel(socketKey, deviceModel(socketKey)); profile != nil {
+			input.inverted = containsString(profile.InvertedInputs, port)
+		}
+		occupancyInputs[key] = input
+	}
+	return input
+}
+
+func publishOccupancy(socketKey string, port string, source string, occupied bool) {
+	ev := endpointEvent(socketKey, "occupancystatus", source, []string{port}, boolString(occupied))
+	if updateShadow(ev) {
+		publishDeviceEvent(ev)
+	}
+}
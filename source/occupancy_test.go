@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+func TestOccupancyPolarity(t *testing.T) {
+	withProfile(t, "room101ipcp.json", `{"name": "Room 101 IPCP", "match": "IPCP", "invertedInputs": ["2"]}`)
+
+	// A notification before any get is already read with the profile's polarity
+	socketKey := "telnet|admin:extron@telnet_ipcp350_23"
+	setDeviceModel(socketKey, "IPCP Pro 350")
+	if observeOccupancyInput(socketKey, "2", "1", "notification") {
+		t.Error("input 2 is inverted in the profile, closed should be vacant")
+	}
+
+	replayCalls(t, "telnet_ipcp350_23.jsonl", []replayCall{
+		{"GET", "occupancystatus", "2", "", `"false"`},      // no polarity keeps the profile's
+		{"GET", "occupancystatus", "2", "normal", `"true"`}, // the same reading, the other way around
+		{"GET", "occupancystatus", "2", "", `"true"`},       // opened, but still inside the debounce
+
+		// the relays, later in the same session
+		{"SET", "setstate", "1", "true", "ok"}, // "Cpn01 Rly1", then read back
+		{"SET", "setstate", "1", "false", "ok"},
+		{"SET", "timedtriggerstate", "2", "0.05", "ok"}, // 2.5 ticks is 2
+		{"SET", "triggerstate", "4", "", "ok"},          // 500 ms is 25 ticks
+	})
+}
@@ -0,0 +1,404 @@
+package main
+
+// Device profiles describe model quirks as data instead of code: which models a profile is for,
+// I/O names and where each one shows up in a response, group numbers, loop-out/loop-through positions
+// and the endpoints the model supports.
+//
+// The JSON files in ./profiles are built in.  Files in profileDir (SIS_PROFILE_DIR) are loaded on top of them at startup.
+// A file with the same name as a built-in profile replaces it, any other file adds a model,
+// so supporting a new model means mounting a file instead of rebuilding the container.
+// Patterns must not overlap: a profile that matches a model another profile matches is logged and skipped.
+// A profile with devices is only for those devices and comes before the profile for their model, so two rooms
+// with the same model and different designs (ex: DMP mute groups) each get their own.  Those only overlap each other.
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mefranklin6/microservice-framework/framework"
+)
+
+//go:embed profiles/*.json
+var builtInProfiles embed.FS
+
+type deviceProfile struct {
+	Name            string            `json:"name"`
+	Match           string            `json:"match"`                     // regular expression tried against the model name
+	Devices         []string          `json:"devices,omitempty"`         // device addresses the profile is for, "host" or "host:port" as in the socketKey.  Empty for every device of the model
+	DeviceType      string            `json:"deviceType,omitempty"`      // replaces the type worked out from the model description
+	Inputs          map[string]int    `json:"inputs,omitempty"`          // input name -> index in the input status response
+	Outputs         map[string]int    `json:"outputs,omitempty"`         // output name -> index in the video mute response
+	LoopOut         *int              `json:"loopOut,omitempty"`         // index of "LoopOut" in the video mute response
+	LoopThrough     *int              `json:"loopThrough,omitempty"`     // index of "LoopThrough" in the video mute response (DA's)
+	VideoMutes      map[string]string `json:"videoMutes,omitempty"`      // output name -> number the video mute commands take, ex: "1A" is "1"
+	VolumeGroups    map[string]string `json:"volumeGroups,omitempty"`    // volume name -> group number ('X46' in the manual)
+	MuteGroups      map[string]string `json:"muteGroups,omitempty"`      // mute name -> group number ('X48' in the manual)
+	OutputMuteGroup string            `json:"outputMuteGroup,omitempty"` // mute group that silences every output, used by audioandvideomute
+	TieOutputs      map[string]string `json:"tieOutputs,omitempty"`      // output name -> number the tie commands take, ex: IN 180x "LoopOut" is "2"
+	Presets         int               `json:"presets,omitempty"`         // number of global presets, defaultPresetCount when not set
+	VolumeCurve     string            `json:"volumeCurve,omitempty"`     // volume curve for every volume endpoint of the model (see volume_curves.go)
+	VolumeCurves    map[string]string `json:"volumeCurves,omitempty"`    // endpoint -> volume curve, ahead of volumeCurve
+	CommandVariant  string            `json:"commandVariant,omitempty"`  // model specific commands in the internal command maps, tried before the device type's
+	InvertedInputs  []string          `json:"invertedInputs,omitempty"`  // occupancy input ports that are closed when the room is vacant (see occupancy.go)
+	Endpoints       []string          `json:"endpoints,omitempty"`       // endpoints the model supports, empty for no restriction
+
+	file    string
+	pattern *regexp.Regexp
+}
+
+var deviceProfiles []*deviceProfile // in the order they are tried
+
+// Device types a profile can declare.  Same names categorizeDeviceType uses.
+var profileDeviceTypes = []string{
+	"Audio Processor", "Collaboration Systems", "Matrix Switcher", "Scaler", "Streaming Media",
+	"Collaboration Switcher", "Switcher", "Distribution Amplifier", "Power Controller", "Controller",
+}
+
+// Loads the built-in profiles, then the ones in profileDir.
+// Profiles that fail validation are logged and skipped, the rest still load.
+func loadDeviceProfiles() {
+	function := "loadDeviceProfiles"
+
+	byFile := make(map[string]*deviceProfile)
+	readProfiles(builtInProfiles, "profiles", "built-in", byFile)
+	if profileDir != "" {
+		if _, err := os.Stat(profileDir); err != nil {
+			framework.Log(fmt.Sprintf("%s - can't read profile directory %s: %v", function, profileDir, err))
+		} else {
+			readProfiles(os.DirFS(profileDir), ".", profileDir, byFile)
+		}
+	}
+
+	files := make([]string, 0, len(byFile))
+	for file := range byFile {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	deviceProfiles = deviceProfiles[:0]
+	for _, file := range files {
+		profile := byFile[file]
+		if other := overlappingProfile(profile, deviceProfiles); other != nil {
+			framework.Log(fmt.Sprintf("%s - skipping profile %s: its match %q overlaps %q in %s, make the patterns exclusive or use the same file name to replace it",
+				function, file, profile.Match, other.Match, other.file))
+			continue
+		}
+		deviceProfiles = append(deviceProfiles, profile)
+	}
+	framework.Log(fmt.Sprintf("%s - loaded %d device profiles", function, len(deviceProfiles)))
+}
+
+func readProfiles(fsys fs.FS, dir string, from string, byFile map[string]*deviceProfile) {
+	function := "readProfiles"
+
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		framework.Log(fmt.Sprintf("%s - can't list %s profiles: %v", function, from, err))
+		return
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		data, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			framework.Log(fmt.Sprintf("%s - can't read %s profile %s: %v", function, from, entry.Name(), err))
+			continue
+		}
+		profile, err := parseDeviceProfile(entry.Name(), data)
+		if err != nil {
+			framework.Log(fmt.Sprintf("%s - skipping %s profile %s: %v", function, from, entry.Name(), err))
+			continue
+		}
+		if _, replaces := byFile[entry.Name()]; replaces {
+			framework.Log(fmt.Sprintf("%s - %s profile %s replaces the built-in one", function, from, entry.Name()))
+		}
+		byFile[entry.Name()] = profile
+	}
+}
+
+func parseDeviceProfile(file string, data []byte) (*deviceProfile, error) {
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.DisallowUnknownFields() // catch typos in field names
+	profile := &deviceProfile{}
+	if err := decoder.Decode(profile); err != nil {
+		return nil, err
+	}
+	profile.file = file
+	if err := profile.validate(); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+func (p *deviceProfile) validate() error {
+	if p.Name == "" {
+		return errors.New("name is required")
+	}
+	if p.Match == "" {
+		return errors.New("match is required")
+	}
+	pattern, err := regexp.Compile(p.Match)
+	if err != nil {
+		return fmt.Errorf("match is not a valid regular expression: %v", err)
+	}
+	p.pattern = pattern
+
+	for _, device := range p.Devices {
+		if device == "" || strings.ContainsAny(device, "|@/") {
+			return fmt.Errorf("devices %q must be an address, ex: \"192.168.50.82\"", device)
+		}
+	}
+
+	if p.DeviceType != "" && !containsString(profileDeviceTypes, p.DeviceType) {
+		return fmt.Errorf("unknown deviceType %q, must be one of: %s", p.DeviceType, strings.Join(profileDeviceTypes, ", "))
+	}
+
+	if err := checkIndexes("inputs", p.Inputs); err != nil {
+		return err
+	}
+	outputIndexes := make(map[int]string, len(p.Outputs)+2)
+	for name, index := range p.Outputs {
+		outputIndexes[index] = name
+	}
+	if err := checkIndexes("outputs", p.Outputs); err != nil {
+		return err
+	}
+	for name, index := range map[string]*int{"loopOut": p.LoopOut, "loopThrough": p.LoopThrough} {
+		if index == nil {
+			continue
+		}
+		if *index < 0 {
+			return fmt.Errorf("%s index must not be negative", name)
+		}
+		if other, taken := outputIndexes[*index]; taken {
+			return fmt.Errorf("%s index %d is already used by output %q", name, *index, other)
+		}
+		outputIndexes[*index] = name
+	}
+
+	for table, groups := range map[string]map[string]string{"videoMutes": p.VideoMutes, "volumeGroups": p.VolumeGroups, "muteGroups": p.MuteGroups, "tieOutputs": p.TieOutputs} {
+		for name, number := range groups {
+			if _, err := strconv.Atoi(number); err != nil {
+				return fmt.Errorf("%s %q must be a number, got %q", table, name, number)
+			}
+		}
+	}
+	if _, ok := p.MuteGroups[p.OutputMuteGroup]; p.OutputMuteGroup != "" && !ok {
+		return fmt.Errorf("outputMuteGroup %q is not one of the muteGroups", p.OutputMuteGroup)
+	}
+
+	if p.Presets < 0 {
+		return errors.New("presets must not be negative")
+	}
+
+	if p.VolumeCurve != "" {
+		for _, endpoint := range volumeCurveEndpoints {
+			if _, own := p.VolumeCurves[endpoint]; own || !p.supports(endpoint) {
+				continue
+			}
+			if err := checkVolumeCurveAssignment(endpoint, p.VolumeCurve); err != nil {
+				return fmt.Errorf("volumeCurve for %s: %v", endpoint, err)
+			}
+		}
+	}
+	for endpoint, curve := range p.VolumeCurves {
+		if err := checkVolumeCurveAssignment(endpoint, curve); err != nil {
+			return fmt.Errorf("volumeCurves: %v", err)
+		}
+	}
+
+	for _, port := range p.InvertedInputs {
+		if number, err := strconv.Atoi(port); err != nil || number < 1 || strconv.Itoa(number) != port {
+			return fmt.Errorf("invertedInputs %q must be an input port number", port)
+		}
+	}
+
+	if p.CommandVariant != "" && !isCommandVariant(p.CommandVariant) {
+		return fmt.Errorf("unknown commandVariant %q", p.CommandVariant)
+	}
+
+	for _, endpoint := range p.Endpoints {
+		_, isGet := getFunctionsMap[endpoint]
+		_, isSet := setFunctionsMap[endpoint]
+		if !isGet && !isSet {
+			return fmt.Errorf("unknown endpoint %q", endpoint)
+		}
+	}
+	return nil
+}
+
+// True if any internal command is defined for variant
+func isCommandVariant(variant string) bool {
+	for _, cmdMap := range []map[string]map[string]string{internalGetCmdMap, internalSetCmdMap} {
+		for _, commands := range cmdMap {
+			if _, exists := commands[variant]; exists {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Names must map to distinct, non-negative response indexes
+func checkIndexes(table string, names map[string]int) error {
+	seen := make(map[int]string, len(names))
+	for name, index := range names {
+		if index < 0 {
+			return fmt.Errorf("%s %q has a negative index", table, name)
+		}
+		if other, taken := seen[index]; taken {
+			return fmt.Errorf("%s %q and %q both use index %d", table, name, other, index)
+		}
+		seen[index] = name
+	}
+	return nil
+}
+
+// The first of profiles for the same devices as p whose pattern matches a model name p's pattern matches, or the other way round.  nil if none.
+// Regular expressions can't be compared directly, so each pattern is tried against model names built from the other.
+func overlappingProfile(p *deviceProfile, profiles []*deviceProfile) *deviceProfile {
+	samples := patternSamples(p.Match)
+	for _, other := range profiles {
+		if !p.sharesDevices(other) {
+			continue
+		}
+		for _, model := range samples {
+			if other.pattern.MatchString(model) {
+				return other
+			}
+		}
+		for _, model := range patternSamples(other.Match) {
+			if p.pattern.MatchString(model) {
+				return other
+			}
+		}
+	}
+	return nil
+}
+
+const maxPatternSamples = 1000
+
+// Model names a pattern matches, ex: "IN ?180[68]" gives "IN1806", "IN1808", "IN 1806" and "IN 1808".
+// Every one for patterns like the built-in ones; repeats are taken zero and one times and wide classes by their ends,
+// so for looser patterns it's a sample.
+func patternSamples(pattern string) []string {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return nil
+	}
+	return expandPattern(re.Simplify())
+}
+
+func expandPattern(re *syntax.Regexp) []string {
+	switch re.Op {
+	case syntax.OpLiteral:
+		return []string{string(re.Rune)}
+	case syntax.OpCharClass:
+		var samples []string
+		for i := 0; i+1 < len(re.Rune); i += 2 {
+			lo, hi := re.Rune[i], re.Rune[i+1]
+			if hi-lo > 16 {
+				samples = append(samples, string(lo), string(hi))
+				continue
+			}
+			for r := lo; r <= hi; r++ {
+				samples = append(samples, string(r))
+			}
+		}
+		return samples
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		return []string{"0"}
+	case syntax.OpCapture, syntax.OpPlus:
+		return expandPattern(re.Sub[0])
+	case syntax.OpStar, syntax.OpQuest:
+		return append([]string{""}, expandPattern(re.Sub[0])...)
+	case syntax.OpAlternate:
+		var samples []string
+		for _, sub := range re.Sub {
+			samples = append(samples, expandPattern(sub)...)
+		}
+		return samples
+	case syntax.OpConcat:
+		samples := []string{""}
+		for _, sub := range re.Sub {
+			suffixes := expandPattern(sub)
+			var next []string
+			for _, prefix := range samples {
+				for _, suffix := range suffixes {
+					if len(next) == maxPatternSamples {
+						break
+					}
+					next = append(next, prefix+suffix)
+				}
+			}
+			samples = next
+		}
+		return samples
+	default: // empty match, anchors and word boundaries
+		return []string{""}
+	}
+}
+
+// True if both profiles are for every device of their model, or both list a device in common
+func (p *deviceProfile) sharesDevices(other *deviceProfile) bool {
+	if len(p.Devices) == 0 || len(other.Devices) == 0 {
+		return len(p.Devices) == len(other.Devices)
+	}
+	for _, device := range p.Devices {
+		if containsString(other.Devices, device) {
+			return true
+		}
+	}
+	return false
+}
+
+// The profile for the device at socketKey with a model name, or nil if none match.
+// A profile listing the device in devices comes before the one for every device of the model.
+func profileForModel(socketKey string, model string) *deviceProfile {
+	if model == "" {
+		return nil
+	}
+	address := deviceAddress(socketKey)
+	host, _, _ := strings.Cut(address, ":")
+	var forModel *deviceProfile
+	for _, profile := range deviceProfiles {
+		if !profile.pattern.MatchString(model) {
+			continue
+		}
+		if len(profile.Devices) == 0 {
+			if forModel == nil {
+				forModel = profile
+			}
+			continue
+		}
+		if containsString(profile.Devices, address) || containsString(profile.Devices, host) {
+			return profile
+		}
+	}
+	return forModel
+}
+
+// True if the profile lists endpoint, or does not restrict endpoints at all
+func (p *deviceProfile) supports(endpoint string) bool {
+	return len(p.Endpoints) == 0 || containsString(p.Endpoints, endpoint)
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package main
+
+import "testing"
+
+func TestProfileForModel(t *testing.T) {
+	tests := []struct {
+		model string
+		want  string
+	}{
+		{"IN1804", "IN 1804"},
+		{"IN1806", "IN 180x"},
+		{"IN1808", "IN 180x"},
+		{"IN1606", "IN 160x"},
+		{"DTPCP84 4K", "DTP CrossPoint 84"},
+		{"DTPCP108 4K", "DTP CrossPoint 108"},
+		{"SW4 HD 4K", ""},
+	}
+	for _, tt := range tests {
+		got := ""
+		if profile := profileForModel("", tt.model); profile != nil {
+			got = profile.Name
+		}
+		if got != tt.want {
+			t.Errorf("profileForModel(%q) = %q, want %q", tt.model, got, tt.want)
+		}
+	}
+}
+
+func TestBuiltInProfilesDontOverlap(t *testing.T) {
+	for i, profile := range deviceProfiles {
+		if other := overlappingProfile(profile, deviceProfiles[i+1:]); other != nil {
+			t.Errorf("%s (%q) overlaps %s (%q)", profile.file, profile.Match, other.file, other.Match)
+		}
+	}
+}
+
+func TestOverlappingProfile(t *testing.T) {
+	parse := func(match string) *deviceProfile {
+		profile, err := parseDeviceProfile("test.json", []byte(`{"name": "test", "match": "`+match+`"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return profile
+	}
+	in1804 := parse("IN ?1804")
+
+	tests := []struct {
+		match    string
+		overlaps bool
+	}{
+		{"IN ?18[0-9][0-9]", true},
+		{"IN ?180[68]", false},
+		{"IN ?18(04|06)", true},
+		{"IN1804 12G", true}, // contains IN1804
+		{"IN.*", true},
+		{"IN ?160[0-9]", false},
+		{"^IN180", true},
+	}
+	for _, tt := range tests {
+		if got := overlappingProfile(parse(tt.match), []*deviceProfile{in1804}) != nil; got != tt.overlaps {
+			t.Errorf("%q against %q: overlaps %v, want %v", tt.match, in1804.Match, got, tt.overlaps)
+		}
+	}
+}
+
+// Two rooms with the same DMP model and different designs each get the profile for their own device
+func TestProfileForDevice(t *testing.T) {
+	parse := func(data string) *deviceProfile {
+		profile, err := parseDeviceProfile("test.json", []byte(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return profile
+	}
+	room101 := parse(`{"name": "Room 101 DMP", "match": "DMP 128 Plus", "devices": ["10.1.1.5"], "muteGroups": {"programmute": "2"}}`)
+	room102 := parse(`{"name": "Room 102 DMP", "match": "DMP 128 Plus", "devices": ["10.1.2.5:23"], "muteGroups": {"programmute": "7"}}`)
+	anyDmp := parse(`{"name": "DMP 128 Plus", "match": "DMP 128 Plus"}`)
+
+	saved := deviceProfiles
+	deviceProfiles = []*deviceProfile{anyDmp, room101, room102}
+	defer func() { deviceProfiles = saved }()
+
+	tests := []struct {
+		socketKey string
+		want      string
+	}{
+		{"telnet|admin:extron@10.1.1.5", "Room 101 DMP"},
+		{"telnet|admin:extron@10.1.1.5:23", "Room 101 DMP"}, // a host covers every port
+		{"telnet|admin:extron@10.1.2.5:23", "Room 102 DMP"},
+		{"telnet|admin:extron@10.1.2.5:2023", "DMP 128 Plus"},
+		{"telnet|admin:extron@10.1.3.5", "DMP 128 Plus"},
+	}
+	for _, tt := range tests {
+		if got := profileForModel(tt.socketKey, "DMP 128 Plus C V"); got == nil || got.Name != tt.want {
+			t.Errorf("%s: got %v, want %s", tt.socketKey, got, tt.want)
+		}
+	}
+
+	if other := overlappingProfile(room102, []*deviceProfile{anyDmp, room101}); other != nil {
+		t.Errorf("room 102 overlaps %s, profiles for other devices or for the model should not", other.Name)
+	}
+	if other := overlappingProfile(parse(`{"name": "Room 101 again", "match": "DMP", "devices": ["10.1.1.5"]}`), []*deviceProfile{anyDmp, room101}); other != room101 {
+		t.Errorf("a second profile for 10.1.1.5 overlaps %v, want Room 101 DMP", other)
+	}
+	if _, err := parseDeviceProfile("test.json", []byte(`{"name": "bad", "match": "DMP", "devices": ["telnet|admin:extron@10.1.1.5"]}`)); err == nil {
+		t.Error("devices takes addresses, not socketKeys")
+	}
+}
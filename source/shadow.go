@@ -0,0 +1,290 @@
+package main
+
+// In-memory shadow of each device's state.
+// Every get, set and verbose-mode notification lands here (as a deviceEvent) with a timestamp,
+// so dashboards can read a room's state with the "devicestate" endpoint without touching the device.
+// The shadow is also what decides whether something changed, and therefore whether an event is published.
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/mefranklin6/microservice-framework/framework"
+)
+
+type shadowValue struct {
+	Value   string    `json:"value"`
+	Updated time.Time `json:"updated"`
+	Source  string    `json:"source"` // notification, set, get or poll
+	Stale   bool      `json:"stale"`  // older than stateStaleAfter, or the connection dropped since
+}
+
+type deviceShadow struct {
+	Device         string                  `json:"device"`
+	Model          string                  `json:"model,omitempty"`
+	DeviceType     string                  `json:"deviceType,omitempty"`
+	IOSize         *ioSize                 `json:"ioSize,omitempty"` // from the information response (see discovery.go)
+	VideoRoutes    map[string]*shadowValue `json:"videoRoutes"`      // output -> input
+	AudioRoutes    map[string]*shadowValue `json:"audioRoutes"`      // output -> input
+	VideoMutes     map[string]*shadowValue `json:"videoMutes"`       // output -> true|false
+	AudioMutes     map[string]*shadowValue `json:"audioMutes"`       // output -> true|false
+	InputSignals   map[string]*shadowValue `json:"inputSignals"`     // input -> true|false
+	GroupVolumes   map[string]*shadowValue `json:"groupVolumes"`     // group name -> percent
+	GroupMutes     map[string]*shadowValue `json:"groupMutes"`       // group name -> true|false
+	MixPointLevels map[string]*shadowValue `json:"mixPointLevels"`   // DMP object ID -> percent
+	MixPointMutes  map[string]*shadowValue `json:"mixPointMutes"`    // DMP object ID -> true|false
+	Occupancy      map[string]*shadowValue `json:"occupancy"`        // controller input port -> true|false (debounced)
+	Other          map[string]*shadowValue `json:"other"`            // endpoint/args -> value
+}
+
+func newDeviceShadow(socketKey string) *deviceShadow {
+	return &deviceShadow{
+		Device:         deviceAddress(socketKey),
+		VideoRoutes:    make(map[string]*shadowValue),
+		AudioRoutes:    make(map[string]*shadowValue),
+		VideoMutes:     make(map[string]*shadowValue),
+		AudioMutes:     make(map[string]*shadowValue),
+		InputSignals:   make(map[string]*shadowValue),
+		GroupVolumes:   make(map[string]*shadowValue),
+		GroupMutes:     make(map[string]*shadowValue),
+		MixPointLevels: make(map[string]*shadowValue),
+		MixPointMutes:  make(map[string]*shadowValue),
+		Occupancy:      make(map[string]*shadowValue),
+		Other:          make(map[string]*shadowValue),
+	}
+}
+
+// every table, for walking them all
+func (d *deviceShadow) tables() []map[string]*shadowValue {
+	return []map[string]*shadowValue{
+		d.VideoRoutes, d.AudioRoutes, d.VideoMutes, d.AudioMutes, d.InputSignals,
+		d.GroupVolumes, d.GroupMutes, d.MixPointLevels, d.MixPointMutes, d.Occupancy, d.Other,
+	}
+}
+
+var deviceShadows = make(map[string]*deviceShadow) // socketKey -> shadow
+var deviceShadowsMutex sync.Mutex
+
+// Applies an event to the shadow. Returns true if it changed a value (or is a change by nature, like a reconfigure).
+func updateShadow(ev deviceEvent) bool {
+	deviceShadowsMutex.Lock()
+	defer deviceShadowsMutex.Unlock()
+
+	shadow, exists := deviceShadows[ev.SocketKey]
+	if !exists {
+		shadow = newDeviceShadow(ev.SocketKey)
+		deviceShadows[ev.SocketKey] = shadow
+	}
+
+	now := ev.Time
+	if now.IsZero() {
+		now = time.Now()
+	}
+	set := func(table map[string]*shadowValue, key string, value string) bool {
+		previous, seen := table[key]
+		table[key] = &shadowValue{Value: value, Updated: now, Source: ev.Source}
+		return !seen || previous.Value != value
+	}
+
+	switch ev.Kind {
+	case routeChanged:
+		changed := false
+		if ev.Media == "video" || ev.Media == "all" {
+			changed = set(shadow.VideoRoutes, ev.Output, ev.Input) || changed
+		}
+		if ev.Media == "audio" || ev.Media == "all" {
+			changed = set(shadow.AudioRoutes, ev.Output, ev.Input) || changed
+		}
+		return changed
+
+	case muteChanged:
+		switch {
+		case ev.Endpoint == "audiomute":
+			return set(shadow.GroupMutes, ev.Output, ev.State)
+		case ev.Media == "video":
+			return set(shadow.VideoMutes, shadowVideoMuteName(ev.SocketKey, ev.Output), ev.State)
+		case ev.Source == "notification" && isMixPointOID(ev.Output): // DsM
+			if block, channel, ok := decodeDmpGainBlock(deviceModel(ev.SocketKey), ev.Output); ok {
+				return set(shadow.Other, block.MuteEndpoint+"/"+channel, ev.State)
+			}
+			return set(shadow.MixPointMutes, ev.Output, ev.State)
+		default:
+			return set(shadow.AudioMutes, ev.Output, ev.State)
+		}
+
+	case inputSignalChanged:
+		return set(shadow.InputSignals, ev.Input, ev.State)
+
+	case levelChanged: // DsG, tenths of dB
+		if block, channel, ok := decodeDmpGainBlock(deviceModel(ev.SocketKey), ev.Output); ok {
+			percent, err := unTransformVolume(volumeCurveFor(ev.SocketKey, block.Endpoint), ev.State, block.MinTenths, block.MaxTenths)
+			if err == nil {
+				return set(shadow.Other, block.Endpoint+"/"+channel, percent) // same key as a dmp gain get
+			}
+		}
+		percent, err := newUnTransformVolume(volumeCurveFor(ev.SocketKey, "matrixvolume"), ev.State)
+		if err != nil {
+			return set(shadow.Other, "level/"+ev.Output, ev.State)
+		}
+		return set(shadow.MixPointLevels, ev.Output, percent)
+
+	case groupChanged: // GrpmD, raw group value
+		if group, known := knownDmpGroup(ev.SocketKey, ev.Output); known { // DMP group masters (see groupvolume)
+			if group.Mute {
+				return set(shadow.GroupMutes, ev.Output, boolString(ev.State == "1"))
+			}
+			percent, err := unTransformVolume(volumeCurveFor(ev.SocketKey, "groupvolume"), ev.State, group.Lower, group.Upper)
+			if err == nil {
+				return set(shadow.GroupVolumes, ev.Output, percent)
+			}
+		}
+		profile := profileForModel(ev.SocketKey, deviceModel(ev.SocketKey))
+		if profile == nil {
+			return set(shadow.Other, "group/"+ev.Output, ev.State)
+		}
+		if name, isVolume := groupName(profile.VolumeGroups, ev.Output); isVolume {
+			percent, err := newUnTransformVolume(volumeCurveFor(ev.SocketKey, "volume"), ev.State)
+			if err == nil {
+				return set(shadow.GroupVolumes, name, percent)
+			}
+		}
+		if name, isMute := groupName(profile.MuteGroups, ev.Output); isMute {
+			return set(shadow.GroupMutes, name, boolString(ev.State == "1"))
+		}
+		return set(shadow.Other, "group/"+ev.Output, ev.State)
+
+	case deviceReconfigured:
+		for _, table := range shadow.tables() {
+			for _, value := range table {
+				value.Stale = true
+			}
+		}
+		return true
+
+	case valueChanged:
+		switch ev.Endpoint {
+		case "volume", "groupvolume":
+			return set(shadow.GroupVolumes, ev.Output, ev.State)
+		case "groupmute":
+			return set(shadow.GroupMutes, ev.Output, ev.State)
+		case "occupancystatus":
+			return set(shadow.Occupancy, ev.Output, ev.State)
+		case "edidpresets":
+			return false // the device's EDID table, not state
+		case "dmpmixpoint":
+			return false // a lookup, not state
+		case "preset":
+			// a recall changes ties, mutes and levels without saying which, so nothing known can be trusted
+			for _, table := range shadow.tables() {
+				for _, value := range table {
+					value.Stale = true
+				}
+			}
+			set(shadow.Other, "preset", ev.State)
+			return true // publish every recall, even of the same preset
+		case "matrixties", "audioandvideoroute", "names", "hdcpstatus":
+			return false // JSON of several values, already recorded one at a time (see recordRoute, getNamesDo and getHDCPStatusDo)
+		case "matrixvolume", "matrixmute":
+			table := shadow.MixPointLevels
+			if ev.Endpoint == "matrixmute" {
+				table = shadow.MixPointMutes
+			}
+			if oid, err := calculateDmpMixPointNumber(deviceModel(ev.SocketKey), ev.Input, ev.Output); err == nil {
+				return set(table, oid, ev.State)
+			}
+		}
+		key := ev.Endpoint
+		for _, arg := range []string{ev.Output, ev.Input} {
+			if arg != "" {
+				key += "/" + arg
+			}
+		}
+		return set(shadow.Other, key, ev.State)
+	}
+	return false
+}
+
+// Marks everything known about a device as stale, ex: after the connection was dropped
+func markShadowStale(socketKey string) {
+	deviceShadowsMutex.Lock()
+	defer deviceShadowsMutex.Unlock()
+
+	if shadow, exists := deviceShadows[socketKey]; exists {
+		for _, table := range shadow.tables() {
+			for _, value := range table {
+				value.Stale = true
+			}
+		}
+	}
+}
+
+// GET devicestate: the whole shadow as JSON. Never talks to the device.
+func getDeviceStateDo(socketKey string) (string, error) {
+	function := "getDeviceStateDo"
+
+	deviceShadowsMutex.Lock()
+	defer deviceShadowsMutex.Unlock()
+
+	shadow, exists := deviceShadows[socketKey]
+	if !exists {
+		shadow = newDeviceShadow(socketKey)
+	}
+	shadow.Model = deviceModel(socketKey)
+	shadow.DeviceType = knownDeviceType(socketKey)
+	ioSizesMutex.Lock()
+	shadow.IOSize = ioSizes[socketKey]
+	ioSizesMutex.Unlock()
+
+	for _, table := range shadow.tables() {
+		for _, value := range table {
+			if time.Since(value.Updated) > stateStaleAfter {
+				value.Stale = true
+			}
+		}
+	}
+
+	data, err := json.Marshal(shadow)
+	if err != nil {
+		errMsg := function + " - error encoding device state: " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+	return string(data), nil
+}
+
+// Notifications report some video mutes by number (ex: IN 160x), the endpoints use the output name
+func shadowVideoMuteName(socketKey string, output string) string {
+	if profile := profileForModel(socketKey, deviceModel(socketKey)); profile != nil {
+		for name, number := range profile.VideoMutes {
+			if number == output {
+				return name
+			}
+		}
+	}
+	return output
+}
+
+// Reverse lookup of a group number in one of a profile's group maps
+func groupName(groupMap map[string]string, group string) (string, bool) {
+	for name, number := range groupMap {
+		if number == group {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// DMP mix point object IDs are five digits
+func isMixPointOID(s string) bool {
+	if len(s) != 5 {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
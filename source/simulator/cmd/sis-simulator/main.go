@@ -0,0 +1,51 @@
+// Runs a simulated Extron SIS device on a local TCP port.
+//
+//	go run ./src/simulator/cmd/sis-simulator -profile crosspoint86 -listen 127.0.0.1:2323 -password extron
+//
+// Then point the microservice at it: telnet|admin:extron@127.0.0.1:2323
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/mefranklin6/microservice-extron-sis/src/simulator"
+)
+
+func main() {
+	profileName := flag.String("profile", "crosspoint86", "device profile to simulate ("+strings.Join(simulator.ProfileNames(), ", ")+")")
+	listen := flag.String("listen", "127.0.0.1:2323", "address to listen on")
+	password := flag.String("password", "extron", "admin password, empty for no password")
+	list := flag.Bool("list", false, "list available profiles and exit")
+	flag.Parse()
+
+	if *list {
+		for _, name := range simulator.ProfileNames() {
+			profile, _ := simulator.LookupProfile(name)
+			fmt.Printf("%-14s %-22s %s\n", name, profile.Model, profile.Family)
+		}
+		return
+	}
+
+	profile, ok := simulator.LookupProfile(*profileName)
+	if !ok {
+		log.Fatalf("unknown profile %q, use -list to see available profiles", *profileName)
+	}
+
+	sim := simulator.New(profile, *password)
+	if err := sim.Start(*listen); err != nil {
+		log.Fatalf("failed to start simulator: %v", err)
+	}
+	log.Printf("simulating %s (%s) on %s", profile.Model, profile.Family, sim.Addr())
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	<-stop
+
+	sim.Close()
+}
@@ -0,0 +1,183 @@
+package simulator
+
+import (
+	"sort"
+	"strings"
+)
+
+// Family decides which SIS dialect a simulated device speaks.
+// These line up with the device types the driver derives from the '2I' response.
+type Family string
+
+const (
+	MatrixSwitcher        Family = "Matrix Switcher"
+	Scaler                Family = "Scaler"
+	Switcher              Family = "Switcher"
+	DistributionAmplifier Family = "Distribution Amplifier"
+	AudioProcessor        Family = "Audio Processor"
+)
+
+// Profile describes one simulated device model.
+type Profile struct {
+	Name        string // key used on the command line, ex: "crosspoint86"
+	Model       string // model name as it appears in the telnet banner
+	Description string // '2I' response, used by the driver to categorize the device
+	Info        string // 'I' response
+	PartNumber  string // 'N' response
+	Firmware    string // 'Q' response
+	Family      Family
+
+	Inputs  int // number of tie-able inputs
+	Outputs int // number of tie-able outputs (matrix switchers, and scalers that tie per output like the IN1808)
+
+	// Video mute names in the order the device reports them
+	// ex: CrossPoint 84 reports "0 0 0 0 0 0" for 1,2,3A,3B,4A,4B
+	MuteOutputs []string
+
+	// Scaler group volumes and mutes ('X46'/'X48' group numbers) with their power-on values.
+	// Also DMP group masters
+	VolumeGroups map[string]int
+	MuteGroups   map[string]int
+
+	// DMP soft limits (upper, lower) per volume group, in tenths of dB
+	GroupLimits map[string][2]int
+
+	// Signal presence per input at power-on
+	Signals []int
+}
+
+var profiles = map[string]Profile{
+	"crosspoint84": {
+		Name:        "crosspoint84",
+		Model:       "DTPCP84 4K",
+		Description: "DTP CrossPoint 84 4K Scaling Presentation Matrix Switcher",
+		Info:        "V8X4 A8X4",
+		PartNumber:  "60-1381-01",
+		Firmware:    "1.10",
+		Family:      MatrixSwitcher,
+		Inputs:      8,
+		Outputs:     4,
+		MuteOutputs: []string{"1", "2", "3A", "3B", "4A", "4B"},
+		Signals:     []int{1, 0, 1, 0, 0, 0, 0, 0},
+	},
+	"crosspoint86": {
+		Name:        "crosspoint86",
+		Model:       "DTPCP86 4K",
+		Description: "DTP CrossPoint 86 4K Scaling Presentation Matrix Switcher",
+		Info:        "V8X6 A8X6",
+		PartNumber:  "60-1382-01",
+		Firmware:    "1.10",
+		Family:      MatrixSwitcher,
+		Inputs:      8,
+		Outputs:     6,
+		MuteOutputs: []string{"1", "2", "3A", "3B", "4A", "4B", "5", "6"},
+		Signals:     []int{1, 0, 1, 0, 0, 0, 0, 0},
+	},
+	"crosspoint108": {
+		Name:        "crosspoint108",
+		Model:       "DTPCP108 4K",
+		Description: "DTP CrossPoint 108 4K Scaling Presentation Matrix Switcher",
+		Info:        "V10X8 A10X8",
+		PartNumber:  "60-1383-01",
+		Firmware:    "1.10",
+		Family:      MatrixSwitcher,
+		Inputs:      10,
+		Outputs:     8,
+		MuteOutputs: []string{"1", "2", "3", "4", "5A", "5B", "6A", "6B", "7", "8"},
+		Signals:     []int{1, 1, 0, 0, 0, 0, 0, 0, 0, 0},
+	},
+	"in1606": {
+		Name:         "in1606",
+		Model:        "IN1606",
+		Description:  "HDMI Scaling Presentation Switcher",
+		Info:         "V6X1 A6X1",
+		PartNumber:   "60-1081-01",
+		Firmware:     "1.20",
+		Family:       Scaler,
+		Inputs:       6,
+		MuteOutputs:  []string{"1", "2", "3"},
+		VolumeGroups: map[string]int{"1": -200, "3": -200, "8": -200},
+		MuteGroups:   map[string]int{"2": 0, "4": 0, "7": 0},
+		Signals:      []int{1, 0, 0, 1, 0, 0},
+	},
+	"in1808": {
+		Name:        "in1808",
+		Model:       "IN1808",
+		Description: "Eight Input Seamless Scaling Switcher",
+		Info:        "V8X2 A8X2",
+		PartNumber:  "60-1665-01",
+		Firmware:    "1.02",
+		Family:      Scaler,
+		Inputs:      8,
+		Outputs:     2, // 1 is 1A and 1B, 2 is LoopOut
+		MuteOutputs: []string{"1A", "1B", "LoopOut"},
+		Signals:     []int{1, 0, 0, 0, 1, 0, 0, 0},
+	},
+	"in1804": {
+		Name:        "in1804",
+		Model:       "IN1804",
+		Description: "Four Input Scaling Presentation Switcher",
+		Info:        "V4X2 A4X2",
+		PartNumber:  "60-1666-01",
+		Firmware:    "1.02",
+		Family:      Scaler,
+		Inputs:      4,
+		MuteOutputs: []string{"1A", "1B"},
+		Signals:     []int{1, 0, 0, 0},
+	},
+	"da4": {
+		Name:        "da4",
+		Model:       "DA4 HD 4K PLUS",
+		Description: "HDMI Distribution Amplifier",
+		Info:        "V1X4 A1X4",
+		PartNumber:  "60-1592-01",
+		Firmware:    "1.01",
+		Family:      DistributionAmplifier,
+		Inputs:      1,
+		Outputs:     4,
+		MuteOutputs: []string{"0", "1", "2", "3", "4"}, // 0 is the local loop through
+		Signals:     []int{1},
+	},
+	"dmp128": {
+		Name:         "dmp128",
+		Model:        "DMP 128 Plus C V AT",
+		Description:  "DMP 128 Plus Digital Audio Processor",
+		Info:         "DMP 128 Plus C V AT",
+		PartNumber:   "60-1552-13",
+		Firmware:     "1.11",
+		Family:       AudioProcessor,
+		Inputs:       12, // mic/line, for naming
+		Outputs:      8,
+		VolumeGroups: map[string]int{"1": -300, "2": 0},    // room volume, mic volume
+		MuteGroups:   map[string]int{"3": 0},               // program mute
+		GroupLimits:  map[string][2]int{"1": {-100, -800}}, // room volume stays between -80 and -10 dB
+	},
+	"sw4": {
+		Name:        "sw4",
+		Model:       "SW4 HD 4K",
+		Description: "Four Input HDMI Switcher",
+		Info:        "V4X1 A4X1",
+		PartNumber:  "60-1498-01",
+		Firmware:    "1.00",
+		Family:      Switcher,
+		Inputs:      4,
+		MuteOutputs: []string{"1"},
+		Signals:     []int{1, 0, 1, 0},
+	},
+}
+
+// LookupProfile returns the built-in profile registered under name (case-insensitive).
+func LookupProfile(name string) (Profile, bool) {
+	profile, ok := profiles[strings.ToLower(name)]
+	return profile, ok
+}
+
+// ProfileNames returns the names of all built-in profiles, sorted.
+func ProfileNames() []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
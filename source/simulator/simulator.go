@@ -0,0 +1,254 @@
+package simulator
+
+// A stand-in for an Extron SIS device that speaks just enough telnet-flavored SIS
+// to exercise the driver without real hardware.
+// State is shared between all connections to the same simulator, like a real device.
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Simulator struct {
+	Profile  Profile
+	Password string // empty means the device has no password set
+
+	mu    sync.Mutex
+	state *deviceState
+
+	listener net.Listener
+	clients  map[net.Conn]*client
+	connsMu  sync.Mutex
+	wg       sync.WaitGroup
+}
+
+// One telnet session
+type client struct {
+	mu      sync.Mutex // guards writer
+	writer  *bufio.Writer
+	verbose int // 'CV' mode: 1 and 3 receive change notifications
+}
+
+func (c *client) writeLine(line string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writer.WriteString(line + "\r\n")
+	return c.writer.Flush()
+}
+
+// New returns a simulator for the given profile with power-on state.
+func New(profile Profile, password string) *Simulator {
+	return &Simulator{
+		Profile:  profile,
+		Password: password,
+		state:    newDeviceState(profile),
+		clients:  make(map[net.Conn]*client),
+	}
+}
+
+// Start listens on addr (ex: "127.0.0.1:0") and serves connections in the background.
+func (s *Simulator) Start(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	s.listener = listener
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return // listener closed
+			}
+			c := &client{writer: bufio.NewWriter(conn)}
+			s.connsMu.Lock()
+			s.clients[conn] = c
+			s.connsMu.Unlock()
+
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				s.serve(conn, c)
+				s.connsMu.Lock()
+				delete(s.clients, conn)
+				s.connsMu.Unlock()
+			}()
+		}
+	}()
+	return nil
+}
+
+// Addr returns the address the simulator is listening on.
+func (s *Simulator) Addr() string {
+	if s.listener == nil {
+		return ""
+	}
+	return s.listener.Addr().String()
+}
+
+// Close stops the listener, drops all open connections and waits for them to finish.
+func (s *Simulator) Close() error {
+	if s.listener == nil {
+		return errors.New("simulator not started")
+	}
+	err := s.listener.Close()
+
+	s.connsMu.Lock()
+	for conn := range s.clients {
+		conn.Close()
+	}
+	s.connsMu.Unlock()
+
+	s.wg.Wait()
+	return err
+}
+
+// Handle runs a single SIS command against the simulated device and returns the reply without line endings.
+// cmd may include the trailing carriage return.
+func (s *Simulator) Handle(cmd string) string {
+	cmd = strings.TrimRight(cmd, "\r\n")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state.handle(s.Profile, cmd)
+}
+
+// SetSignal changes signal presence on an input (1 based), as if a source was plugged in or unplugged.
+// Verbose sessions are told about it.
+func (s *Simulator) SetSignal(input int, present bool) error {
+	s.mu.Lock()
+	if input < 1 || input > len(s.state.signals) {
+		s.mu.Unlock()
+		return fmt.Errorf("input %d out of range", input)
+	}
+	s.state.signals[input-1] = 0
+	if present {
+		s.state.signals[input-1] = 1
+	}
+	notification := "Frq00 " + joinInts(s.state.signals, "")
+	s.mu.Unlock()
+
+	s.notify(nil, notification)
+	return nil
+}
+
+// Sends a change notification to every verbose session except the one that caused it
+func (s *Simulator) notify(from *client, line string) {
+	s.connsMu.Lock()
+	defer s.connsMu.Unlock()
+
+	for _, c := range s.clients {
+		if c == from || (c.verbose != 1 && c.verbose != 3) {
+			continue
+		}
+		c.writeLine(line)
+	}
+}
+
+// Replies that mean the device state changed, and go out to verbose sessions
+var changePrefixes = []string{"Out", "In", "Vmt", "Amt", "GrpmD", "DsG", "DsM"}
+
+func isChange(reply string) bool {
+	for _, prefix := range changePrefixes {
+		if strings.HasPrefix(reply, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Plays the login banner and then answers commands until the client goes away
+func (s *Simulator) serve(conn net.Conn, c *client) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+
+	// Banner: copyright, company, model name, firmware, part number
+	// The driver pulls the model name out of this line by counting commas.
+	c.writeLine(fmt.Sprintf("(c) Copyright 2026, Extron Electronics, %s, V%s, %s",
+		s.Profile.Model, s.Profile.Firmware, s.Profile.PartNumber))
+	c.writeLine(time.Now().UTC().Format("Mon, 02 Jan 2006 15:04:05"))
+
+	if s.Password == "" {
+		if c.writeLine("") != nil {
+			return
+		}
+	} else {
+		// Real devices do not terminate the prompt, but a line-based client would stall on it
+		attempts := 0
+		for {
+			if c.writeLine("Password:") != nil {
+				return
+			}
+			line, err := readCommand(reader)
+			if err != nil {
+				return
+			}
+			if line == s.Password {
+				if c.writeLine("Login Administrator") != nil {
+					return
+				}
+				break
+			}
+			attempts++
+			if attempts >= 3 {
+				return
+			}
+		}
+	}
+
+	for {
+		cmd, err := readCommand(reader)
+		if err != nil {
+			return
+		}
+		if cmd == "" {
+			continue
+		}
+
+		// Verbose mode belongs to the session, not the device
+		var reply string
+		if m := verboseModeCmd.FindStringSubmatch(cmd); m != nil {
+			if m[1] != "" {
+				s.connsMu.Lock()
+				c.verbose = int(m[1][0] - '0')
+				s.connsMu.Unlock()
+			}
+			reply = fmt.Sprintf("Vrb%d", c.verbose)
+		} else {
+			reply = s.Handle(cmd)
+		}
+
+		if c.writeLine(reply) != nil {
+			return
+		}
+		if isChange(reply) {
+			s.notify(c, reply)
+		}
+	}
+}
+
+var verboseModeCmd = regexp.MustCompile(`^\x1B([0-3]?)CV$`)
+
+// Reads up to the next carriage return or line feed
+func readCommand(reader *bufio.Reader) (string, error) {
+	var sb strings.Builder
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		if b == '\r' || b == '\n' {
+			return sb.String(), nil
+		}
+		sb.WriteByte(b)
+	}
+}
@@ -0,0 +1,144 @@
+package simulator
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// A telnet session against a running simulator
+type session struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func dial(t *testing.T, sim *Simulator) *session {
+	t.Helper()
+	conn, err := net.Dial("tcp", sim.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &session{t: t, conn: conn, reader: bufio.NewReader(conn)}
+}
+
+func (s *session) read() string {
+	s.t.Helper()
+	s.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	line, err := s.reader.ReadString('\n')
+	if err != nil {
+		s.t.Fatalf("reading: %v", err)
+	}
+	return strings.TrimRight(line, "\r\n")
+}
+
+func (s *session) send(cmd string) string {
+	s.t.Helper()
+	if _, err := s.conn.Write([]byte(cmd)); err != nil {
+		s.t.Fatal(err)
+	}
+	return s.read()
+}
+
+// Reads the banner and logs in, returns the banner's first line
+func (s *session) login(password string) string {
+	s.t.Helper()
+	banner := s.read()
+	s.read() // date
+	if prompt := s.read(); prompt != "Password:" {
+		s.t.Fatalf("got %q, want the password prompt", prompt)
+	}
+	if reply := s.send(password + "\r"); reply != "Login Administrator" {
+		s.t.Fatalf("got %q, want Login Administrator", reply)
+	}
+	return banner
+}
+
+func startSimulator(t *testing.T, name string) *Simulator {
+	t.Helper()
+	profile, ok := LookupProfile(name)
+	if !ok {
+		t.Fatalf("no profile %s", name)
+	}
+	sim := New(profile, "extron")
+	if err := sim.Start("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sim.Close() })
+	return sim
+}
+
+func TestLoginBanner(t *testing.T) {
+	sim := startSimulator(t, "crosspoint84")
+	banner := dial(t, sim).login("extron")
+
+	// The driver takes the model name from the third of five comma separated fields
+	fields := strings.Split(banner, ",")
+	if len(fields) != 5 || strings.TrimSpace(fields[2]) != "DTPCP84 4K" {
+		t.Errorf("banner %q does not carry the model as the third of five fields", banner)
+	}
+}
+
+func TestWrongPassword(t *testing.T) {
+	sim := startSimulator(t, "sw4")
+	s := dial(t, sim)
+	s.read()
+	s.read()
+	for attempt := 0; attempt < 3; attempt++ {
+		if prompt := s.read(); prompt != "Password:" {
+			t.Fatalf("attempt %d: got %q, want the password prompt", attempt, prompt)
+		}
+		s.conn.Write([]byte("wrong\r"))
+	}
+	s.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := s.reader.ReadString('\n'); err == nil {
+		t.Error("the session should be dropped after three wrong passwords")
+	}
+}
+
+func TestInfoMatchesOutputs(t *testing.T) {
+	tests := []struct {
+		profile string
+		want    string
+	}{
+		{"crosspoint84", "V8X4 A8X4"},
+		{"crosspoint86", "V8X6 A8X6"},
+		{"crosspoint108", "V10X8 A10X8"},
+	}
+	for _, tt := range tests {
+		sim := startSimulator(t, tt.profile)
+		s := dial(t, sim)
+		s.login("extron")
+		if got := s.send("I\r"); got != tt.want {
+			t.Errorf("%s: I = %q, want %q", tt.profile, got, tt.want)
+		}
+	}
+}
+
+// State is shared between sessions, and verbose sessions hear about changes made by the others
+func TestTieAcrossSessions(t *testing.T) {
+	sim := startSimulator(t, "crosspoint86")
+	control := dial(t, sim)
+	control.login("extron")
+	watcher := dial(t, sim)
+	watcher.login("extron")
+
+	if got := watcher.send("\x1B1CV\r"); got != "Vrb1" {
+		t.Fatalf("verbose mode: got %q", got)
+	}
+	if got := control.send("2*3!\r"); got != "Out3 In2 All" {
+		t.Errorf("tie: got %q", got)
+	}
+	if got := watcher.read(); got != "Out3 In2 All" {
+		t.Errorf("notification: got %q", got)
+	}
+	if got := watcher.send("3%\r"); got != "2" {
+		t.Errorf("video tie query from the other session: got %q", got)
+	}
+	if got := control.send("9*3!\r"); got != "E01" {
+		t.Errorf("tie to an input the device doesn't have: got %q, want E01", got)
+	}
+}
@@ -0,0 +1,662 @@
+package simulator
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Error codes the simulator can answer with. Same meaning as errorResponsesMap in the driver.
+const (
+	errInvalidInput   = "E01"
+	errInvalidCommand = "E10"
+	errInvalidPreset  = "E11"
+	errInvalidOutput  = "E12"
+	errInvalidValue   = "E13"
+)
+
+// Everything the simulated device remembers
+type deviceState struct {
+	videoTies  map[int]int // output -> input, 0 means untied
+	audioTies  map[int]int
+	videoMutes map[string]int // mute output name -> 0 (off), 1 (video), 2 (video and sync)
+	signals    []int
+	groups     map[string]int // scaler volume and mute groups
+	audioMute  int            // switcher audio mute
+	volume     int            // IN1804 program volume, whole dB
+	daMutes    map[string]int // DA and matrix per-output audio mutes
+	mixLevels  map[string]int // DMP object ID -> tenths of dB
+	mixMutes   map[string]int // DMP object ID -> 0|1
+	presets    map[int]*savedPreset
+	names      map[string]string // "i1", "o3" -> name
+	hdcpOff    map[int]bool      // inputs with HDCP authorization turned off
+	edids      []string          // EDID table, slot 1 first
+	edidInputs map[int]int       // input -> assigned EDID slot
+}
+
+// A global preset: the ties at the time it was saved
+type savedPreset struct {
+	name      string
+	videoTies map[int]int
+	audioTies map[int]int
+}
+
+const presetSlots = 32
+
+// Built-in EDIDs, after one "Output n" (follow the display on output n) slot per output and before the user slots
+var edidPresets = []string{
+	"1024x768 @60Hz 2ch", "1280x720 @60Hz 2ch", "1280x800 @60Hz", "1366x768 @60Hz 2ch", "1920x1080 @60Hz 2ch",
+	"1920x1080 @60Hz Multi-ch", "1920x1200 @60Hz", "3840x2160 @30Hz 2ch", "3840x2160 @60Hz 2ch",
+}
+
+const edidUserSlots = 4
+
+// What EDID Minder reads from every display
+const simulatedDisplayEDID = "1920x1200 @59.95Hz 2ch"
+
+func newDeviceState(profile Profile) *deviceState {
+	state := &deviceState{
+		videoTies:  make(map[int]int),
+		audioTies:  make(map[int]int),
+		videoMutes: make(map[string]int),
+		signals:    append([]int(nil), profile.Signals...),
+		groups:     make(map[string]int),
+		volume:     -30,
+		daMutes:    make(map[string]int),
+		mixLevels:  make(map[string]int),
+		mixMutes:   make(map[string]int),
+		presets:    make(map[int]*savedPreset),
+		names:      make(map[string]string),
+		hdcpOff:    make(map[int]bool),
+		edidInputs: make(map[int]int),
+	}
+	outputs := profile.Outputs
+	if profile.Family == Scaler || profile.Family == Switcher {
+		outputs = 1
+	}
+	for output := 1; output <= outputs; output++ {
+		state.videoTies[output] = 1
+		state.audioTies[output] = 1
+	}
+	for output := 1; output <= outputs; output++ {
+		state.edids = append(state.edids, fmt.Sprintf("Output %d", output))
+	}
+	state.edids = append(state.edids, edidPresets...)
+	for slot := 1; slot <= edidUserSlots; slot++ {
+		state.edids = append(state.edids, fmt.Sprintf("User %d", slot))
+	}
+	for input := 1; input <= profile.Inputs; input++ {
+		state.edidInputs[input] = outputs + 5 // 1920x1080 @60Hz 2ch
+	}
+	for _, name := range profile.MuteOutputs {
+		state.videoMutes[name] = 0
+		state.daMutes[name] = 0
+	}
+	for group, value := range profile.VolumeGroups {
+		state.groups[group] = value
+	}
+	for group, value := range profile.MuteGroups {
+		state.groups[group] = value
+	}
+	return state
+}
+
+// A command pattern and what to do when it matches.
+// Handlers receive the submatches (without the full match).
+type sisCommand struct {
+	pattern *regexp.Regexp
+	handler func(state *deviceState, profile Profile, args []string) string
+}
+
+func cmd(pattern string, handler func(*deviceState, Profile, []string) string) sisCommand {
+	return sisCommand{pattern: regexp.MustCompile("^" + pattern + "$"), handler: handler}
+}
+
+// Commands every Extron device answers
+var commonCommands = []sisCommand{
+	cmd(`Q`, func(_ *deviceState, p Profile, _ []string) string { return p.Firmware }),
+	cmd(`N`, func(_ *deviceState, p Profile, _ []string) string { return p.PartNumber }),
+	cmd(`I`, func(_ *deviceState, p Profile, _ []string) string { return p.Info }),
+	cmd(`2I`, func(_ *deviceState, p Profile, _ []string) string { return p.Description }),
+	cmd(`W20STAT`, func(_ *deviceState, _ Profile, _ []string) string { return "+00035" }),
+	cmd(`99I`, func(_ *deviceState, _ Profile, _ []string) string { return "A1B2C3D" }),
+	cmd(`\x1B(\d+)N([IO])`, func(s *deviceState, p Profile, a []string) string { return s.ioName(p, a[1], a[0]) }),
+	cmd(`\x1B(\d+),([^,]+)N([IO])`, func(s *deviceState, p Profile, a []string) string { return s.setIOName(p, a[2], a[0], a[1]) }),
+	cmd(`98I`, func(_ *deviceState, _ Profile, _ []string) string { return "00-05-A6-00-00-01" }),
+}
+
+var familyCommands = map[Family][]sisCommand{
+	MatrixSwitcher: {
+		cmd(`(\d+)%`, func(s *deviceState, p Profile, a []string) string { return s.queryTie(p, s.videoTies, a[0]) }),
+		cmd(`(\d+)\$`, func(s *deviceState, p Profile, a []string) string { return s.queryTie(p, s.audioTies, a[0]) }),
+		cmd(`(\d+)\*(\d+)([%$!&])`, func(s *deviceState, p Profile, a []string) string { return s.matrixTie(p, a[0], a[1], a[2]) }),
+		cmd(`(\w+)\*([0-2])B`, func(s *deviceState, _ Profile, a []string) string { return s.setVideoMute(a[0], a[1]) }),
+		cmd(`(\w+)\*B`, func(s *deviceState, _ Profile, a []string) string { return s.queryVideoMute(a[0]) }),
+		cmd(`\x1BVM`, func(s *deviceState, p Profile, _ []string) string { return s.allVideoMutes(p, " ") }),
+		cmd(`0LS`, func(s *deviceState, _ Profile, _ []string) string { return joinInts(s.signals, "") }),
+		cmd(`\x1B0\*(\d+)\*([12])VC`, func(s *deviceState, p Profile, a []string) string { return s.tieTable(p, a[0], a[1]) }),
+		cmd(`(\d+)\*([01])Z`, func(s *deviceState, p Profile, a []string) string {
+			if output, _ := strconv.Atoi(a[0]); output < 1 || output > p.Outputs {
+				return errInvalidOutput
+			}
+			s.daMutes[a[0]], _ = strconv.Atoi(a[1])
+			return "Amt" + a[0] + "*" + a[1]
+		}),
+		cmd(`(\d+)Z`, func(s *deviceState, p Profile, a []string) string {
+			if output, _ := strconv.Atoi(a[0]); output < 1 || output > p.Outputs {
+				return errInvalidOutput
+			}
+			return strconv.Itoa(s.daMutes[a[0]])
+		}),
+	},
+	Scaler: {
+		cmd(`&`, func(s *deviceState, _ Profile, _ []string) string { return fmt.Sprintf("%02d", s.videoTies[1]) }),
+		cmd(`!`, func(s *deviceState, _ Profile, _ []string) string { return fmt.Sprintf("%02d", s.videoTies[1]) }),
+		cmd(`\$`, func(s *deviceState, _ Profile, _ []string) string { return fmt.Sprintf("%02d", s.audioTies[1]) }),
+		cmd(`(\d+)([&!$])`, func(s *deviceState, p Profile, a []string) string { return s.scalerTie(p, a[0], a[1]) }),
+		cmd(`(\d+)\*(\d+)([&!$])`, func(s *deviceState, p Profile, a []string) string {
+			if p.Outputs < 2 {
+				return errInvalidCommand // only scalers with more than one output tie per output
+			}
+			return s.matrixTie(p, a[0], a[1], a[2])
+		}),
+		cmd(`(\w+)\*([0-2])B`, func(s *deviceState, _ Profile, a []string) string { return s.setVideoMute(a[0], a[1]) }),
+		cmd(`(\w+)\*B`, func(s *deviceState, p Profile, a []string) string {
+			if strings.HasPrefix(p.Model, "IN18") {
+				return s.allVideoMutes(p, " ") // IN 180x reports every output at once
+			}
+			return s.queryVideoMute(a[0])
+		}),
+		cmd(`\x1B0LS`, func(s *deviceState, _ Profile, _ []string) string { return joinInts(s.signals, "*") }),
+		cmd(`\x1BD(\d+)GRPM`, func(s *deviceState, _ Profile, a []string) string { return s.queryGroup(a[0]) }),
+		cmd(`\x1BD(\d+)\*(-?\d+)GRPM`, func(s *deviceState, p Profile, a []string) string { return s.setGroup(p, a[0], a[1]) }),
+		// IN1804 has one program volume (whole dB, -100 to 0 dBFS) and mute instead of groups
+		cmd(`V`, func(s *deviceState, p Profile, _ []string) string {
+			if p.Model != "IN1804" {
+				return errInvalidCommand
+			}
+			return fmt.Sprintf("Vol%d", s.volume)
+		}),
+		cmd(`(-?\d+)V`, func(s *deviceState, p Profile, a []string) string {
+			volume, _ := strconv.Atoi(a[0])
+			if p.Model != "IN1804" {
+				return errInvalidCommand
+			}
+			if volume < -100 || volume > 0 {
+				return errInvalidValue
+			}
+			s.volume = volume
+			return fmt.Sprintf("Vol%d", volume)
+		}),
+		cmd(`Z`, func(s *deviceState, p Profile, _ []string) string {
+			if p.Model != "IN1804" {
+				return errInvalidCommand
+			}
+			return strconv.Itoa(s.audioMute)
+		}),
+		cmd(`([01])Z`, func(s *deviceState, p Profile, a []string) string {
+			if p.Model != "IN1804" {
+				return errInvalidCommand
+			}
+			s.audioMute, _ = strconv.Atoi(a[0])
+			return "Amt" + a[0]
+		}),
+	},
+	Switcher: {
+		cmd(`!`, func(s *deviceState, _ Profile, _ []string) string { return strconv.Itoa(s.videoTies[1]) }),
+		cmd(`(\d+)!`, func(s *deviceState, p Profile, a []string) string { return s.scalerTie(p, a[0], "!") }),
+		cmd(`B`, func(s *deviceState, _ Profile, _ []string) string { return strconv.Itoa(s.videoMutes["1"]) }),
+		cmd(`([0-2])B`, func(s *deviceState, _ Profile, a []string) string {
+			s.videoMutes["1"], _ = strconv.Atoi(a[0])
+			return "Vmt" + a[0]
+		}),
+		cmd(`\x1BLS`, func(s *deviceState, _ Profile, _ []string) string { return joinInts(s.signals, " ") + "*1" }),
+		cmd(`\x1BAFMT`, func(s *deviceState, _ Profile, _ []string) string { return strconv.Itoa(s.audioMute) }),
+		cmd(`\x1B([01])AFMT`, func(s *deviceState, _ Profile, a []string) string {
+			s.audioMute, _ = strconv.Atoi(a[0])
+			return "Amt" + a[0]
+		}),
+	},
+	DistributionAmplifier: {
+		cmd(`B`, func(s *deviceState, p Profile, _ []string) string { return s.allVideoMutes(p, " ") }),
+		cmd(`(\d+)\*([0-2])B`, func(s *deviceState, _ Profile, a []string) string { return s.setVideoMute(a[0], a[1]) }),
+		cmd(`\x1BLS`, func(s *deviceState, p Profile, _ []string) string {
+			// input*loopout output1 output2 ..., every connected sink reports 1
+			return fmt.Sprintf("%d*%s", s.signals[0], strings.TrimSpace(strings.Repeat("1 ", p.Outputs+1)))
+		}),
+		cmd(`\x1B(\d+)AFMT`, func(s *deviceState, _ Profile, a []string) string {
+			mute, ok := s.daMutes[a[0]]
+			if !ok {
+				return errInvalidOutput
+			}
+			return strconv.Itoa(mute)
+		}),
+		cmd(`\x1B(\d+)\*([01])AFMT`, func(s *deviceState, _ Profile, a []string) string {
+			if _, ok := s.daMutes[a[0]]; !ok {
+				return errInvalidOutput
+			}
+			s.daMutes[a[0]], _ = strconv.Atoi(a[1])
+			return "Amt" + a[0] + "*" + a[1]
+		}),
+	},
+	AudioProcessor: {
+		cmd(`\x1BG(\d{5})AU`, func(s *deviceState, _ Profile, a []string) string { return strconv.Itoa(s.mixLevels[a[0]]) }),
+		cmd(`\x1BG(\d{5})\*(-?\d+)AU`, func(s *deviceState, _ Profile, a []string) string {
+			level, _ := strconv.Atoi(a[1])
+			min, max := dmpLevelRange(a[0])
+			if level < min || level > max {
+				return errInvalidValue
+			}
+			s.mixLevels[a[0]] = level
+			return "DsG" + a[0] + "*" + strconv.Itoa(level)
+		}),
+		cmd(`\x1BD(\d+)GRPM`, func(s *deviceState, _ Profile, a []string) string { return s.queryGroup(a[0]) }),
+		cmd(`\x1BD(\d+)\*(-?\d+)GRPM`, func(s *deviceState, p Profile, a []string) string { return s.setGroup(p, a[0], a[1]) }),
+		cmd(`\x1BL(\d+)GRPM`, func(s *deviceState, p Profile, a []string) string {
+			if _, ok := s.groups[a[0]]; !ok {
+				return errInvalidValue
+			}
+			limits, limited := p.GroupLimits[a[0]]
+			if !limited {
+				limits = [2]int{120, -1000}
+			}
+			return fmt.Sprintf("%+06d*%+06d", limits[0], limits[1])
+		}),
+		cmd(`\x1BM(\d{5})AU`, func(s *deviceState, _ Profile, a []string) string { return strconv.Itoa(s.mixMutes[a[0]]) }),
+		cmd(`\x1BM(\d{5})\*(\d+)AU`, func(s *deviceState, _ Profile, a []string) string {
+			if a[1] != "0" && a[1] != "1" {
+				return errInvalidValue
+			}
+			s.mixMutes[a[0]], _ = strconv.Atoi(a[1])
+			return "DsM" + a[0] + "*" + a[1]
+		}),
+	},
+}
+
+// Level limits in tenths of dB by object ID: input gain goes to +80 dB, outputs only attenuate, everything else is -100 to +12 dB
+func dmpLevelRange(oid string) (int, int) {
+	switch {
+	case strings.HasPrefix(oid, "400"):
+		return -180, 800
+	case strings.HasPrefix(oid, "600"):
+		return -1000, 0
+	}
+	return -1000, 120
+}
+
+// Global presets. Matrix switchers and scalers recall, save and name them, DMP presets are made in DSP Configurator and only recalled
+var presetCommands = map[Family][]sisCommand{
+	MatrixSwitcher: {
+		cmd(`(\d+)\.`, func(s *deviceState, _ Profile, a []string) string { return s.recallPreset(a[0]) }),
+		cmd(`(\d+),`, func(s *deviceState, _ Profile, a []string) string { return s.savePreset(a[0]) }),
+		cmd(`\x1B(\d+)PNAM`, func(s *deviceState, _ Profile, a []string) string { return s.presetName(a[0]) }),
+		cmd(`\x1B(\d+),(.+)PNAM`, func(s *deviceState, _ Profile, a []string) string { return s.namePreset(a[0], a[1]) }),
+	},
+	Scaler: {
+		cmd(`(\d+)\.`, func(s *deviceState, _ Profile, a []string) string { return s.recallPreset(a[0]) }),
+		cmd(`(\d+),`, func(s *deviceState, _ Profile, a []string) string { return s.savePreset(a[0]) }),
+		cmd(`\x1B(\d+)PNAM`, func(s *deviceState, _ Profile, a []string) string { return s.presetName(a[0]) }),
+		cmd(`\x1B(\d+),(.+)PNAM`, func(s *deviceState, _ Profile, a []string) string { return s.namePreset(a[0], a[1]) }),
+	},
+	AudioProcessor: {
+		cmd(`(\d+)\.`, func(s *deviceState, _ Profile, a []string) string { return s.recallPreset(a[0]) }),
+	},
+}
+
+// HDCP status and input authorization, everything with video
+var hdcpCommands = []sisCommand{
+	cmd(`\x1BI(\d+)HDCP`, func(s *deviceState, p Profile, a []string) string { return s.inputHDCP(p, a[0]) }),
+	cmd(`\x1BO(\d+)HDCP`, func(s *deviceState, p Profile, a []string) string { return s.outputHDCP(p, a[0]) }),
+	cmd(`\x1BE(\d+)HDCP`, func(s *deviceState, p Profile, a []string) string {
+		input, err := strconv.Atoi(a[0])
+		if err != nil || input < 1 || input > p.Inputs {
+			return errInvalidInput
+		}
+		return map[bool]string{false: "1", true: "0"}[s.hdcpOff[input]]
+	}),
+	cmd(`\x1BE(\d+)\*([01])HDCP`, func(s *deviceState, p Profile, a []string) string {
+		input, err := strconv.Atoi(a[0])
+		if err != nil || input < 1 || input > p.Inputs {
+			return errInvalidInput
+		}
+		s.hdcpOff[input] = a[1] == "0"
+		return "HdcpE" + a[0] + "*" + a[1]
+	}),
+}
+
+// EDID Minder, matrix switchers and scalers
+var edidCommands = []sisCommand{
+	cmd(`\x1BN(\d+)EDID`, func(s *deviceState, _ Profile, a []string) string {
+		slot, ok := s.edidSlot(a[0])
+		if !ok {
+			return errInvalidValue
+		}
+		return s.edids[slot-1]
+	}),
+	cmd(`\x1BA(\d+)EDID`, func(s *deviceState, p Profile, a []string) string {
+		input, err := strconv.Atoi(a[0])
+		if err != nil || input < 1 || input > p.Inputs {
+			return errInvalidInput
+		}
+		return fmt.Sprintf("%03d", s.edidInputs[input])
+	}),
+	cmd(`\x1BA(\d+)\*(\d+)EDID`, func(s *deviceState, p Profile, a []string) string {
+		input, err := strconv.Atoi(a[0])
+		if err != nil || input < 1 || input > p.Inputs {
+			return errInvalidInput
+		}
+		slot, ok := s.edidSlot(a[1])
+		if !ok {
+			return errInvalidValue
+		}
+		s.edidInputs[input] = slot
+		return fmt.Sprintf("EdidA%02d*%03d", input, slot)
+	}),
+	cmd(`\x1BS(\d+)\*(\d+)EDID`, func(s *deviceState, p Profile, a []string) string {
+		output, err := strconv.Atoi(a[0])
+		outputs := p.Outputs
+		if p.Family == Scaler {
+			outputs = 1
+		}
+		if err != nil || output < 1 || output > outputs {
+			return errInvalidOutput
+		}
+		slot, ok := s.edidSlot(a[1])
+		if !ok || slot <= len(s.edids)-edidUserSlots {
+			return errInvalidValue // only user slots can be written
+		}
+		s.edids[slot-1] = simulatedDisplayEDID
+		return fmt.Sprintf("EdidS%02d*%03d", output, slot)
+	}),
+}
+
+// Dispatches a single command
+func (s *deviceState) handle(profile Profile, command string) string {
+	tables := [][]sisCommand{commonCommands, familyCommands[profile.Family], presetCommands[profile.Family]}
+	if profile.Family != AudioProcessor {
+		tables = append(tables, hdcpCommands)
+	}
+	if profile.Family == MatrixSwitcher || profile.Family == Scaler {
+		tables = append(tables, edidCommands)
+	}
+	for _, table := range tables {
+		for _, c := range table {
+			if args := c.pattern.FindStringSubmatch(command); args != nil {
+				return c.handler(s, profile, args[1:])
+			}
+		}
+	}
+	return errInvalidCommand
+}
+
+func (s *deviceState) queryTie(profile Profile, ties map[int]int, output string) string {
+	outputNum, err := strconv.Atoi(output)
+	if err != nil || outputNum < 1 || outputNum > profile.Outputs {
+		return errInvalidOutput
+	}
+	return strconv.Itoa(ties[outputNum])
+}
+
+// All ties from an output on, 16 at most, ex: "02 01 04 04 Vid"
+func (s *deviceState) tieTable(profile Profile, first string, media string) string {
+	firstNum, err := strconv.Atoi(first)
+	if err != nil || firstNum < 1 || firstNum > profile.Outputs {
+		return errInvalidOutput
+	}
+	ties, suffix := s.videoTies, "Vid"
+	if media == "2" {
+		ties, suffix = s.audioTies, "Aud"
+	}
+	var fields []string
+	for output := firstNum; output <= profile.Outputs && len(fields) < 16; output++ {
+		fields = append(fields, fmt.Sprintf("%02d", ties[output]))
+	}
+	return strings.Join(fields, " ") + " " + suffix
+}
+
+// Matrix tie, ex: "2*3%" ties input 2 to output 3 (video only)
+func (s *deviceState) matrixTie(profile Profile, input string, output string, kind string) string {
+	inputNum, err := strconv.Atoi(input)
+	if err != nil || inputNum < 0 || inputNum > profile.Inputs {
+		return errInvalidInput
+	}
+	outputNum, err := strconv.Atoi(output)
+	if err != nil || outputNum < 1 || outputNum > profile.Outputs {
+		return errInvalidOutput
+	}
+
+	suffix := ""
+	switch kind {
+	case "%", "&":
+		s.videoTies[outputNum] = inputNum
+		suffix = "Vid"
+	case "$":
+		s.audioTies[outputNum] = inputNum
+		suffix = "Aud"
+	case "!":
+		s.videoTies[outputNum] = inputNum
+		s.audioTies[outputNum] = inputNum
+		suffix = "All"
+	}
+	return fmt.Sprintf("Out%d In%d %s", outputNum, inputNum, suffix)
+}
+
+// Non-matrix input selection, ex: "2!" selects input 2 for audio and video
+func (s *deviceState) scalerTie(profile Profile, input string, kind string) string {
+	inputNum, err := strconv.Atoi(input)
+	if err != nil || inputNum < 1 || inputNum > profile.Inputs {
+		return errInvalidInput
+	}
+
+	switch kind {
+	case "&":
+		s.videoTies[1] = inputNum
+		return fmt.Sprintf("In%d RGB", inputNum)
+	case "$":
+		s.audioTies[1] = inputNum
+		return fmt.Sprintf("In%d Aud", inputNum)
+	default:
+		s.videoTies[1] = inputNum
+		s.audioTies[1] = inputNum
+		return fmt.Sprintf("In%02d All", inputNum)
+	}
+}
+
+func (s *deviceState) setVideoMute(output string, state string) string {
+	if _, ok := s.videoMutes[output]; !ok {
+		return errInvalidOutput
+	}
+	s.videoMutes[output], _ = strconv.Atoi(state)
+	return "Vmt" + output + "*" + state
+}
+
+func (s *deviceState) queryVideoMute(output string) string {
+	state, ok := s.videoMutes[output]
+	if !ok {
+		return errInvalidOutput
+	}
+	return strconv.Itoa(state)
+}
+
+func (s *deviceState) allVideoMutes(profile Profile, sep string) string {
+	states := make([]int, 0, len(profile.MuteOutputs))
+	for _, name := range profile.MuteOutputs {
+		states = append(states, s.videoMutes[name])
+	}
+	return joinInts(states, sep)
+}
+
+func (s *deviceState) queryGroup(group string) string {
+	value, ok := s.groups[group]
+	if !ok {
+		return errInvalidValue
+	}
+	return strconv.Itoa(value)
+}
+
+func (s *deviceState) setGroup(profile Profile, group string, value string) string {
+	if _, ok := s.groups[group]; !ok {
+		return errInvalidValue
+	}
+	valueInt, err := strconv.Atoi(value)
+	if err != nil {
+		return errInvalidValue
+	}
+	if _, isMute := profile.MuteGroups[group]; isMute {
+		if valueInt != 0 && valueInt != 1 {
+			return errInvalidValue
+		}
+	} else if valueInt < -1000 || valueInt > 120 {
+		return errInvalidValue
+	} else if limits, limited := profile.GroupLimits[group]; limited && (valueInt > limits[0] || valueInt < limits[1]) {
+		return errInvalidValue
+	}
+	s.groups[group] = valueInt
+	return "GrpmD" + group + "*" + strconv.Itoa(valueInt)
+}
+
+func joinInts(values []int, sep string) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = strconv.Itoa(v)
+	}
+	return strings.Join(parts, sep)
+}
+
+func presetSlot(preset string) (int, bool) {
+	slot, err := strconv.Atoi(preset)
+	return slot, err == nil && slot >= 1 && slot <= presetSlots
+}
+
+func (s *deviceState) recallPreset(preset string) string {
+	slot, ok := presetSlot(preset)
+	if !ok {
+		return errInvalidPreset
+	}
+	if saved, exists := s.presets[slot]; exists {
+		for output, input := range saved.videoTies {
+			s.videoTies[output] = input
+		}
+		for output, input := range saved.audioTies {
+			s.audioTies[output] = input
+		}
+	}
+	return fmt.Sprintf("Rpr%02d", slot)
+}
+
+func (s *deviceState) savePreset(preset string) string {
+	slot, ok := presetSlot(preset)
+	if !ok {
+		return errInvalidPreset
+	}
+	saved := &savedPreset{name: fmt.Sprintf("Preset %d", slot), videoTies: make(map[int]int), audioTies: make(map[int]int)}
+	if previous, exists := s.presets[slot]; exists {
+		saved.name = previous.name
+	}
+	for output, input := range s.videoTies {
+		saved.videoTies[output] = input
+	}
+	for output, input := range s.audioTies {
+		saved.audioTies[output] = input
+	}
+	s.presets[slot] = saved
+	return fmt.Sprintf("Spr%02d", slot)
+}
+
+func (s *deviceState) presetName(preset string) string {
+	slot, ok := presetSlot(preset)
+	if !ok {
+		return errInvalidPreset
+	}
+	if saved, exists := s.presets[slot]; exists {
+		return saved.name
+	}
+	return "[unassigned]"
+}
+
+func (s *deviceState) namePreset(preset string, name string) string {
+	slot, ok := presetSlot(preset)
+	if !ok {
+		return errInvalidPreset
+	}
+	saved, exists := s.presets[slot]
+	if !exists {
+		return errInvalidPreset // only saved presets can be named
+	}
+	saved.name = name
+	return fmt.Sprintf("Pnam%02d,%s", slot, name)
+}
+
+// kind is "I" or "O"
+func ioNameKey(profile Profile, kind string, number string) (string, bool) {
+	num, err := strconv.Atoi(number)
+	count := profile.Inputs
+	if kind == "O" {
+		count = profile.Outputs
+	}
+	return strings.ToLower(kind) + strconv.Itoa(num), err == nil && num >= 1 && num <= count
+}
+
+func (s *deviceState) ioName(profile Profile, kind string, number string) string {
+	key, ok := ioNameKey(profile, kind, number)
+	if !ok {
+		if kind == "I" {
+			return errInvalidInput
+		}
+		return errInvalidOutput
+	}
+	if name, exists := s.names[key]; exists {
+		return name
+	}
+	return map[string]string{"I": "Input ", "O": "Output "}[kind] + key[1:]
+}
+
+func (s *deviceState) setIOName(profile Profile, kind string, number string, name string) string {
+	key, ok := ioNameKey(profile, kind, number)
+	if !ok {
+		if kind == "I" {
+			return errInvalidInput
+		}
+		return errInvalidOutput
+	}
+	s.names[key] = name
+	return "Nm" + key + "," + name
+}
+
+// 0: no source, 1: source with HDCP, 2: source without HDCP.  Sources only encrypt for inputs that authorize HDCP.
+func (s *deviceState) inputHDCP(profile Profile, input string) string {
+	inputNum, err := strconv.Atoi(input)
+	if err != nil || inputNum < 1 || inputNum > profile.Inputs {
+		return errInvalidInput
+	}
+	switch {
+	case inputNum > len(s.signals) || s.signals[inputNum-1] == 0:
+		return "0"
+	case s.hdcpOff[inputNum]:
+		return "2"
+	default:
+		return "1"
+	}
+}
+
+// 0: no sink, 1: sink with HDCP, 2: sink without HDCP.  Every output has a sink, encrypted when its input is.
+func (s *deviceState) outputHDCP(profile Profile, output string) string {
+	outputNum, err := strconv.Atoi(output)
+	outputs := profile.Outputs
+	if profile.Family == Scaler || profile.Family == Switcher {
+		outputs = 1
+	}
+	if err != nil || outputNum < 1 || outputNum > outputs {
+		return errInvalidOutput
+	}
+	if s.inputHDCP(profile, strconv.Itoa(s.videoTies[outputNum])) == "1" {
+		return "1"
+	}
+	return "2"
+}
+
+func (s *deviceState) edidSlot(slot string) (int, bool) {
+	slotNum, err := strconv.Atoi(slot)
+	return slotNum, err == nil && slotNum >= 1 && slotNum <= len(s.edids)
+}
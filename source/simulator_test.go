@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mefranklin6/microservice-extron-sis/src/simulator"
+)
+
+// Talks to a simulator over TCP in place of the framework socket, so the driver logs in,
+// discovers the size and sends its commands exactly as it would to a device.
+type simulatorTransport struct {
+	addr   string
+	mu     sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func (s *simulatorTransport) ensureActiveConnection(socketKey string) error {
+	if s.connected(socketKey) {
+		return nil
+	}
+	conn, err := net.Dial("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.conn, s.reader = conn, bufio.NewReader(conn)
+	s.mu.Unlock()
+
+	if !telnetLoginNegotiation(socketKey) {
+		s.close(socketKey)
+		return errors.New("error logging in to the simulator")
+	}
+	if verboseMode {
+		enableVerboseMode(socketKey)
+	}
+	discoverIOSize(socketKey)
+	return nil
+}
+
+func (s *simulatorTransport) connected(socketKey string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conn != nil
+}
+
+func (s *simulatorTransport) writeLine(socketKey string, line string) bool {
+	s.mu.Lock()
+	conn := s.conn
+	s.mu.Unlock()
+	if conn == nil {
+		return false
+	}
+	_, err := conn.Write([]byte(line))
+	return err == nil
+}
+
+func (s *simulatorTransport) readLine(socketKey string) string {
+	return s.readLineWithin(socketKey, 2*time.Second)
+}
+
+func (s *simulatorTransport) readLineWithin(socketKey string, timeout time.Duration) string {
+	s.mu.Lock()
+	conn, reader := s.conn, s.reader
+	s.mu.Unlock()
+	if conn == nil {
+		return ""
+	}
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	line, _ := reader.ReadString('\n')
+	return strings.TrimRight(line, "\r\n")
+}
+
+func (s *simulatorTransport) close(socketKey string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+}
+
+// Starts a simulated device and points socketKey at it.  The returned socketKey is unique to the test.
+func startSimulator(t *testing.T, profileName string) string {
+	t.Helper()
+	profile, ok := simulator.LookupProfile(profileName)
+	if !ok {
+		t.Fatalf("no simulator profile %s", profileName)
+	}
+	sim := simulator.New(profile, "extron")
+	if err := sim.Start("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	socketKey := "telnet|admin:extron@" + sim.Addr()
+	transport := &simulatorTransport{addr: sim.Addr()}
+	transports.Store(socketKey, transport)
+	t.Cleanup(func() {
+		transports.Delete(socketKey)
+		transport.close(socketKey)
+		sim.Close()
+		// the next simulator can get the same port, and so the same socketKey
+		setDeviceModel(socketKey, "")
+		setDeviceType(socketKey, "")
+		forgetDeviceTables(socketKey)
+	})
+	return socketKey
+}
+
+// Each profile starts at its power-on state, the calls run in order against one connection
+var simulatorTests = []struct {
+	profile string
+	calls   []replayCall
+}{
+	{"crosspoint84", []replayCall{
+		{"SET", "videoroute", "2", "3", "ok"},
+		{"GET", "videoroute", "2", "", `"3"`},
+		{"GET", "matrixties", "", "", `{"audio":{"1":"1","2":"1","3A":"1","3B":"1","4A":"1","4B":"1"},"video":{"1":"1","2":"3","3A":"1","3B":"1","4A":"1","4B":"1"}}`},
+		{"SET", "videomute", "3A", "true", "ok"},
+		{"GET", "videomute", "3A", "", `"true"`},
+		{"GET", "videomute", "3B", "", `"false"`},
+		{"GET", "inputstatus", "1", "", "true"},
+		{"GET", "inputstatus", "2", "", "false"},
+	}},
+	{"crosspoint86", []replayCall{
+		{"SET", "videoroute", "6", "8", "ok"}, // output 6 only exists on the 86
+		{"GET", "videoroute", "6", "", `"8"`},
+	}},
+	{"in1606", []replayCall{
+		{"SET", "videoroute", "1", "4", "ok"},
+		{"GET", "videoroute", "1", "", `"4"`},
+		{"SET", "videomute", "1A", "true", "ok"},
+		{"GET", "videomute", "1A", "", `"true"`},
+		{"GET", "inputstatus", "4", "", "true"},
+	}},
+	{"da4", []replayCall{
+		{"SET", "videomute", "2", "true", "ok"},
+		{"GET", "videomute", "2", "", `"true"`},
+		{"GET", "videomute", "3", "", `"false"`},
+		{"GET", "inputstatus", "1", "", `"true"`},
+	}},
+	{"sw4", []replayCall{
+		{"GET", "inputstatus", "3", "", "true"},
+		{"GET", "inputstatus", "2", "", "false"},
+		{"GET", "videomute", "1", "", `"false"`},
+	}},
+}
+
+func TestSimulator(t *testing.T) {
+	for _, tt := range simulatorTests {
+		t.Run(tt.profile, func(t *testing.T) {
+			socketKey := startSimulator(t, tt.profile)
+			for _, call := range tt.calls {
+				var got string
+				var err error
+				if call.method == "GET" {
+					got, err = doDeviceSpecificGet(socketKey, call.endpoint, call.arg1, call.arg2)
+				} else {
+					got, err = doDeviceSpecificSet(socketKey, call.endpoint, call.arg1, call.arg2, "")
+				}
+				if err != nil {
+					t.Fatalf("%s %s %s %s: %v", call.method, call.endpoint, call.arg1, call.arg2, err)
+				}
+				if got != call.want {
+					t.Errorf("%s %s %s %s = %s, want %s", call.method, call.endpoint, call.arg1, call.arg2, got, call.want)
+				}
+			}
+		})
+	}
+}
+
+// The size comes from the 'I' response at login, so the driver refuses outputs the device doesn't have
+func TestSimulatorDiscoveredSize(t *testing.T) {
+	socketKey := startSimulator(t, "crosspoint84")
+	if _, err := doDeviceSpecificGet(socketKey, "videoroute", "1", ""); err != nil {
+		t.Fatal(err)
+	}
+	size, err := findIOSize(socketKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size.VideoInputs != 8 || size.VideoOutputs != 4 {
+		t.Errorf("discovered %dx%d, want 8x4", size.VideoInputs, size.VideoOutputs)
+	}
+}
+
+// matrixties lands in the shadow under the output names of its answer
+func TestSimulatorMatrixTiesShadow(t *testing.T) {
+	socketKey := startSimulator(t, "crosspoint84")
+	if _, err := doDeviceSpecificSet(socketKey, "videoroute", "3", "5", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := doDeviceSpecificGet(socketKey, "matrixties", "", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	deviceShadowsMutex.Lock()
+	defer deviceShadowsMutex.Unlock()
+	shadow := deviceShadows[socketKey]
+	for _, name := range []string{"3A", "3B"} {
+		if route, exists := shadow.VideoRoutes[name]; !exists || route.Value != "5" {
+			t.Errorf("video route of %s: got %+v, want input 5", name, route)
+		}
+		if route, exists := shadow.AudioRoutes[name]; !exists || route.Value != "1" { // videoroute leaves audio where it was
+			t.Errorf("audio route of %s: got %+v, want input 1", name, route)
+		}
+	}
+	if _, exists := shadow.VideoRoutes["4"]; exists {
+		t.Error("matrixties recorded output 4 by number, the answer names it 4A and 4B")
+	}
+}
@@ -0,0 +1,293 @@
+package main
+
+// Live device state changes for control UIs.
+// Every value the driver reads or sets goes into the device shadow (see shadow.go), and a change is published as an event.
+// Events (including verbose-mode notifications) are streamed to clients as Server-Sent Events:
+//
+//	curl -N http://<containerIP>:8080/events/192.168.50.82
+//
+// The framework owns the main HTTP server and only returns one string per request,
+// so the stream is served on its own port (eventStreamAddr).
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mefranklin6/microservice-framework/framework"
+)
+
+// Which argument of a set endpoint carries the new value (1 based), 0 if there is no value to keep.
+// The arguments before it say what is being changed (output, input, group...).
+var setEndpointValueArg = map[string]int{
+	"videoroute":           2, // arg1: output, arg2: input
+	"audioandvideoroute":   2, // arg1: output, arg2: input
+	"audioroute":           2, // arg1: output, arg2: input
+	"videomute":            2, // arg1: output, arg2: state
+	"audioandvideomute":    2, // arg1: output, arg2: state
+	"audiomute":            2, // arg1: group name, arg2: state
+	"volume":               2, // arg1: group name, arg2: level
+	"volumedb":             2, // arg1: group name, arg2: level in dB
+	"matrixmute":           3, // arg1: input, arg2: output, arg3: state
+	"matrixvolume":         3, // arg1: input, arg2: output, arg3: level
+	"matrixvolumedb":       3, // arg1: input, arg2: output, arg3: level in dB
+	"dmpinputgain":         2, // arg1: channel, arg2: level. Same for the other DMP gain blocks and their mutes
+	"dmpinputmute":         2,
+	"dmppremixergain":      2,
+	"dmppremixermute":      2,
+	"dmpvirtualreturngain": 2,
+	"dmpvirtualreturnmute": 2,
+	"dmpoutputvolume":      2,
+	"dmpoutputmute":        2,
+	"groupvolume":          2, // arg1: group, arg2: level
+	"groupmute":            2, // arg1: group, arg2: state
+	"power":                2, // arg1: outlet, arg2: state
+	"powersave":            1, // arg1: state
+	"setstate":             2, // arg1: relay, arg2: state
+	"triggerstate":         0, // pulses leave nothing behind to record
+	"timedtriggerstate":    0,
+	"preset":               0, // setPresetDo records the preset number, arg1 may be a name
+	"inputname":            2, // arg1: input, arg2: name
+	"outputname":           2, // arg1: output, arg2: name
+	"hdcpauthorization":    2, // arg1: input, arg2: state
+	"edid":                 0, // arg2 may be "outputfollow", the next edid GET records what was assigned
+	"edidcapture":          0, // only changes the EDID table
+	"savepreset":           0, // saving changes nothing we track
+}
+
+// An endpoint and arguments that have been read or set, so they can be refreshed
+type observation struct {
+	endpoint string
+	args     []string
+	asked    time.Time // last get or set from a client, refreshes don't count
+}
+
+// Endpoints the keepalive refresh re-reads: one command each, so the refresh doesn't crowd the SIS session.
+// Tables like matrixties, names and hdcpstatus take a command per row and are only read when asked for.
+var refreshableEndpoints = map[string]bool{
+	"videoroute":  true,
+	"videomute":   true,
+	"inputstatus": true,
+	"audiomute":   true,
+	"volume":      true,
+}
+
+var observedValues = make(map[string]map[string]*observation) // socketKey -> endpoint/args -> observation
+var observedValuesMutex sync.Mutex
+
+// Records the result of a successful get and publishes an event if it changed
+func observeGet(socketKey string, endpoint string, source string, value string, args ...string) {
+	observeValue(socketKey, endpoint, source, trimArgs(args), value)
+}
+
+// Records the new value from a successful set and publishes an event if it changed
+func observeSet(socketKey string, endpoint string, args ...string) {
+	args = trimArgs(args)
+	if len(args) == 0 {
+		return // nothing was set, ex: stopallkeepalivepolling
+	}
+	valueArg, exists := setEndpointValueArg[endpoint]
+	if exists && valueArg == 0 {
+		return
+	}
+	if !exists || valueArg > len(args) {
+		valueArg = len(args) // assume the value comes last
+	}
+	observeValue(socketKey, endpoint, "set", args[:valueArg-1], args[valueArg-1])
+}
+
+func observeValue(socketKey string, endpoint string, source string, args []string, value string) {
+	value = strings.TrimSpace(strings.Trim(value, `"`))
+	key := endpoint + "/" + strings.Join(args, "/")
+
+	observedValuesMutex.Lock()
+	device, exists := observedValues[socketKey]
+	if !exists {
+		device = make(map[string]*observation)
+		observedValues[socketKey] = device
+	}
+	if _, seen := device[key]; !seen || source != "poll" {
+		device[key] = &observation{endpoint: endpoint, args: args, asked: time.Now()}
+	}
+	observedValuesMutex.Unlock()
+
+	ev := endpointEvent(socketKey, endpoint, source, args, value)
+	if updateShadow(ev) {
+		publishDeviceEvent(ev)
+	}
+}
+
+// Turns an endpoint value into a normalized event
+func endpointEvent(socketKey string, endpoint string, source string, args []string, value string) deviceEvent {
+	ev := deviceEvent{SocketKey: socketKey, Source: source, Endpoint: endpoint, State: value, Time: time.Now()}
+	arg := func(i int) string {
+		if i < len(args) {
+			return args[i]
+		}
+		return ""
+	}
+
+	switch endpoint {
+	case "videoroute", "audioroute", "audioandvideoroute":
+		if strings.HasPrefix(value, "{") { // audio broken away from video, the routes were recorded separately
+			ev.Kind = valueChanged
+			ev.Output = arg(0)
+			break
+		}
+		ev.Kind = routeChanged
+		ev.Output = arg(0)
+		if ev.Output == "" {
+			ev.Output = "1" // non-matrix
+		}
+		ev.Input = trimZeros(value)
+		ev.State = ""
+		switch endpoint {
+		case "videoroute":
+			ev.Media = "video"
+		case "audioroute":
+			ev.Media = "audio"
+		default:
+			ev.Media = "all"
+		}
+	case "videomute":
+		ev.Kind = muteChanged
+		ev.Output = arg(0)
+		ev.Media = "video"
+	case "audiomute":
+		ev.Kind = muteChanged
+		ev.Output = arg(0)
+		ev.Media = "audio"
+	case "inputstatus":
+		ev.Kind = inputSignalChanged
+		ev.Input = arg(0)
+	case "matrixmute", "matrixvolume":
+		ev.Kind = valueChanged
+		ev.Input = arg(0)
+		ev.Output = arg(1)
+	case "occupancystatus":
+		ev.Kind = valueChanged
+		ev.Output = arg(0) // port. arg2 is the polarity, not part of the state
+	default:
+		ev.Kind = valueChanged
+		ev.Output = arg(0)
+		ev.Input = arg(1)
+	}
+	return ev
+}
+
+// Re-reads the values served for socketKey in the last refreshFor, so changes made at the device show up in the stream.
+// Only refreshableEndpoints are re-read, older values are forgotten.
+// Called from the keepalive poll, and only while someone is listening.
+func refreshObservedValues(socketKey string) {
+	function := "refreshObservedValues"
+
+	observedValuesMutex.Lock()
+	var pending []observation
+	for key, obs := range observedValues[socketKey] {
+		if time.Since(obs.asked) > refreshFor {
+			delete(observedValues[socketKey], key)
+			continue
+		}
+		if refreshableEndpoints[obs.endpoint] {
+			pending = append(pending, *obs)
+		}
+	}
+	observedValuesMutex.Unlock()
+
+	for _, obs := range pending {
+		args := append(append([]string(nil), obs.args...), "", "", "")
+		value, err := getFunctionsMap[obs.endpoint](socketKey, obs.endpoint, args[0], args[1], args[2])
+		if err != nil {
+			framework.Log(fmt.Sprintf("%s - %s - error refreshing %s: %v", function, socketKey, obs.endpoint, err))
+			continue
+		}
+		observeGet(socketKey, obs.endpoint, "poll", value, obs.args...)
+	}
+}
+
+// Drops trailing empty arguments so "videoroute/3" is the same key however it was called
+func trimArgs(args []string) []string {
+	end := len(args)
+	for end > 0 && args[end-1] == "" {
+		end--
+	}
+	return append([]string(nil), args[:end]...)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// Server-Sent Events //
+///////////////////////////////////////////////////////////////////////////////
+
+func startEventStreamServer() {
+	function := "startEventStreamServer"
+
+	if eventStreamAddr == "" {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/events", serveEventStream)  // every device
+	mux.HandleFunc("/events/", serveEventStream) // one device: /events/<address>
+
+	go func() {
+		framework.Log(function + " - serving device events on " + eventStreamAddr)
+		err := http.ListenAndServe(eventStreamAddr, mux)
+		framework.Log(fmt.Sprintf("%s - event stream server stopped: %v", function, err))
+	}()
+}
+
+// Streams events as they happen, one SSE message per event.
+// The device is the last part of the path: an address with or without port, or a full socketKey.
+func serveEventStream(w http.ResponseWriter, r *http.Request) {
+	function := "serveEventStream"
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	device := strings.Trim(strings.TrimPrefix(r.URL.Path, "/events"), "/")
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	events, unsubscribe := subscribeDeviceEvents(device)
+	defer unsubscribe()
+
+	framework.Log(fmt.Sprintf("%s - client %s subscribed to '%s'", function, r.RemoteAddr, device))
+
+	heartbeat := time.NewTicker(eventStreamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case ev, open := <-events:
+			if !open {
+				return
+			}
+			data, err := json.Marshal(ev)
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Kind, data); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			framework.Log(fmt.Sprintf("%s - client %s unsubscribed from '%s'", function, r.RemoteAddr, device))
+			return
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// The keepalive refresh only re-reads single command endpoints asked for in the last refreshFor
+func TestRefreshObservedValues(t *testing.T) {
+	socketKey := "telnet|admin:extron@sw4_refresh"
+	if err := startTranscriptReplay(socketKey, filepath.Join("testdata", "transcripts", "telnet_sw4_23.jsonl"), false); err != nil {
+		t.Fatal(err)
+	}
+	defer stopTranscriptReplay(socketKey)
+	defer func() {
+		observedValuesMutex.Lock()
+		delete(observedValues, socketKey)
+		observedValuesMutex.Unlock()
+	}()
+
+	if _, err := doDeviceSpecificGet(socketKey, "inputstatus", "3", ""); err != nil {
+		t.Fatal(err)
+	}
+	observedValuesMutex.Lock()
+	observedValues[socketKey]["hdcpinputstatus/1"] = &observation{endpoint: "hdcpinputstatus", args: []string{"1"}, asked: time.Now()}
+	observedValues[socketKey]["videomute/1"] = &observation{endpoint: "videomute", args: []string{"1"}, asked: time.Now().Add(-2 * refreshFor)}
+	observedValuesMutex.Unlock()
+
+	refreshObservedValues(socketKey)
+
+	replay, _ := transports.Load(socketKey)
+	r := replay.(*replayTransport)
+	if len(r.missed) > 0 {
+		t.Errorf("refresh sent commands it shouldn't have: %q", r.missed)
+	}
+	for i, used := range r.used {
+		if command := r.entries[i].Command; used != (command != "B\r") {
+			t.Errorf("command %q sent: %v", command, used)
+		}
+	}
+
+	observedValuesMutex.Lock()
+	defer observedValuesMutex.Unlock()
+	if _, kept := observedValues[socketKey]["videomute/1"]; kept {
+		t.Error("videomute/1 was last asked for longer than refreshFor ago and should be forgotten")
+	}
+	if _, kept := observedValues[socketKey]["hdcpinputstatus/1"]; !kept {
+		t.Error("hdcpinputstatus/1 is not refreshed, but was asked for recently and should be kept")
+	}
+}
@@ -0,0 +1,371 @@
+package main
+
+// Record and replay of SIS command/response transcripts.
+// Recording writes every command sent on the send path (and the raw line the device answered with)
+// to one JSON-lines file per device.
+// Replaying feeds a transcript back in place of the socket, so field captures can drive the parsers without hardware.
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mefranklin6/microservice-framework/framework"
+)
+
+// One line of a transcript file.
+// The first line of every file is a header carrying the model name from the login banner,
+// because the banner is read before any command is sent.
+type transcriptEntry struct {
+	Time        time.Time `json:"time"`
+	Model       string    `json:"model,omitempty"`       // header only
+	Command     string    `json:"command,omitempty"`     // exactly as written to the socket
+	Response    string    `json:"response,omitempty"`    // exactly as read from the socket
+	Unsolicited []string  `json:"unsolicited,omitempty"` // notifications read before the response, in verbose mode
+	Elapsed     int64     `json:"elapsedMs"`             // time from write to read
+}
+
+// All socket I/O goes through here: the send path, login, discovery and the event reader.
+// The framework transport is the default; a replay transport stands in for it per socketKey.
+type sisTransport interface {
+	ensureActiveConnection(socketKey string) error
+	connected(socketKey string) bool
+	writeLine(socketKey string, line string) bool
+	readLine(socketKey string) string
+	readLineWithin(socketKey string, timeout time.Duration) string // "" when nothing arrived in time
+	close(socketKey string)
+}
+
+type frameworkTransport struct{}
+
+func (frameworkTransport) ensureActiveConnection(socketKey string) error {
+	return ensureActiveConnection(socketKey)
+}
+
+func (frameworkTransport) connected(socketKey string) bool {
+	return framework.CheckConnectionsMapExists(socketKey)
+}
+
+func (frameworkTransport) writeLine(socketKey string, line string) bool {
+	return framework.WriteLineToSocket(socketKey, line)
+}
+
+// The framework read blocks until a line arrives or its own timeout runs out, and can't be given a shorter one.
+// Reads run in the background instead, one at a time per socket.  A read its caller stopped waiting for
+// is picked up by the next reader, so a line that arrives late still goes to whoever holds the socket mutex next.
+func (frameworkTransport) readLine(socketKey string) string {
+	lines, joined := socketRead(socketKey)
+	line := <-lines
+	endSocketRead(socketKey, lines)
+	if line == "" && joined {
+		// the read was started before this caller's command was written and may have timed out just before the answer came
+		lines, _ = socketRead(socketKey)
+		line = <-lines
+		endSocketRead(socketKey, lines)
+	}
+	return line
+}
+
+func (frameworkTransport) readLineWithin(socketKey string, timeout time.Duration) string {
+	lines, _ := socketRead(socketKey)
+	select {
+	case line := <-lines:
+		endSocketRead(socketKey, lines)
+		return line
+	case <-time.After(timeout):
+		return "" // left running for the next reader
+	}
+}
+
+func (frameworkTransport) close(socketKey string) {
+	endSocketRead(socketKey, nil)
+	framework.CloseSocketConnection(socketKey)
+}
+
+var socketReads = make(map[string]chan string) // socketKey -> the line from the read in flight
+var socketReadsMutex sync.Mutex
+
+// Returns the read in flight for socketKey, starting one if there is none.  joined is true if it was already running.
+func socketRead(socketKey string) (lines chan string, joined bool) {
+	socketReadsMutex.Lock()
+	defer socketReadsMutex.Unlock()
+
+	if lines, exists := socketReads[socketKey]; exists {
+		return lines, true
+	}
+	lines = make(chan string, 1)
+	socketReads[socketKey] = lines
+	go func() {
+		lines <- framework.ReadLineFromSocket(socketKey)
+	}()
+	return lines, false
+}
+
+// Forgets the read once its line has been taken.  nil forgets whatever read is in flight (the socket is closing).
+func endSocketRead(socketKey string, lines chan string) {
+	socketReadsMutex.Lock()
+	defer socketReadsMutex.Unlock()
+
+	if lines == nil || socketReads[socketKey] == lines {
+		delete(socketReads, socketKey)
+	}
+}
+
+var transports sync.Map // socketKey -> sisTransport
+
+// Returns the transport to use for socketKey
+func transportFor(socketKey string) sisTransport {
+	if t, ok := transports.Load(socketKey); ok {
+		return t.(sisTransport)
+	}
+	return frameworkTransport{}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// Recording //
+///////////////////////////////////////////////////////////////////////////////
+
+var transcriptRecorders = make(map[string]*os.File) // socketKey -> open transcript
+var transcriptRecordersMutex sync.Mutex
+
+// Starts recording for one device.  Writes to transcriptDir, or "transcripts" if that is not set.
+func startTranscriptRecording(socketKey string) (string, error) {
+	function := "startTranscriptRecording"
+
+	// Connect first so the header carries the model name from the banner
+	findModelName(socketKey)
+
+	transcriptRecordersMutex.Lock()
+	defer transcriptRecordersMutex.Unlock()
+
+	if _, exists := transcriptRecorders[socketKey]; exists {
+		return "ok", nil
+	}
+
+	dir := transcriptDir
+	if dir == "" {
+		dir = "transcripts"
+	}
+	file, err := openTranscriptFile(dir, socketKey)
+	if err != nil {
+		errMsg := function + " - error opening transcript: " + err.Error()
+		framework.AddToErrors(socketKey, errMsg)
+		return errMsg, errors.New(errMsg)
+	}
+	transcriptRecorders[socketKey] = file
+	framework.Log(function + " - recording " + transcriptName(socketKey) + " to " + file.Name())
+	return "ok", nil
+}
+
+// Stops recording for one device
+func stopTranscriptRecording(socketKey string) (string, error) {
+	function := "stopTranscriptRecording"
+
+	transcriptRecordersMutex.Lock()
+	defer transcriptRecordersMutex.Unlock()
+
+	if file, exists := transcriptRecorders[socketKey]; exists {
+		file.Close()
+		delete(transcriptRecorders, socketKey)
+		framework.Log(function + " - stopped recording " + transcriptName(socketKey))
+	}
+	return "ok", nil
+}
+
+// Called from the send path after every command, with the notifications skipped on the way to the response.
+// Always records when transcriptDir is set, otherwise only devices that asked for it.
+func recordTranscript(socketKey string, command string, unsolicited []string, response string, elapsed time.Duration) {
+	function := "recordTranscript"
+
+	transcriptRecordersMutex.Lock()
+	defer transcriptRecordersMutex.Unlock()
+
+	file, exists := transcriptRecorders[socketKey]
+	if !exists {
+		if transcriptDir == "" {
+			return
+		}
+		var err error
+		file, err = openTranscriptFile(transcriptDir, socketKey)
+		if err != nil {
+			framework.AddToErrors(socketKey, function+" - error opening transcript: "+err.Error())
+			return
+		}
+		transcriptRecorders[socketKey] = file
+	}
+
+	entry := transcriptEntry{
+		Time:        time.Now(),
+		Command:     command,
+		Response:    response,
+		Unsolicited: unsolicited,
+		Elapsed:     elapsed.Milliseconds(),
+	}
+	if err := writeTranscriptEntry(file, entry); err != nil {
+		framework.AddToErrors(socketKey, function+" - error writing transcript: "+err.Error())
+	}
+}
+
+// Opens (appending) the transcript for socketKey and writes the header
+func openTranscriptFile(dir string, socketKey string) (*os.File, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, transcriptName(socketKey)+".jsonl")
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	header := transcriptEntry{Time: time.Now(), Model: deviceModel(socketKey)}
+	if err := writeTranscriptEntry(file, header); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+func writeTranscriptEntry(file *os.File, entry transcriptEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// File name for a socketKey with the credentials stripped, ex: "telnet_192.168.50.82_23"
+func transcriptName(socketKey string) string {
+	name := socketKey
+	if at := strings.LastIndex(name, "@"); at >= 0 {
+		protocol := ""
+		if pipe := strings.Index(name, "|"); pipe >= 0 && pipe < at {
+			protocol = name[:pipe] + "_"
+		}
+		name = protocol + name[at+1:]
+	}
+	return strings.Trim(unsafeFileChars.ReplaceAllString(name, "_"), "_")
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// Replay //
+///////////////////////////////////////////////////////////////////////////////
+
+// Answers commands from a recorded transcript instead of a socket.
+// Each write consumes the next unused entry with the same command, so unrelated
+// traffic in the capture (ex: keepalive polls) does not have to be replayed too.
+type replayTransport struct {
+	mu       sync.Mutex
+	entries  []transcriptEntry
+	used     []bool
+	pending  []string // responses waiting to be read
+	missed   []string // commands written that aren't in the transcript
+	realtime bool     // sleep for the recorded elapsed time before answering
+}
+
+func (r *replayTransport) ensureActiveConnection(socketKey string) error {
+	return nil
+}
+
+func (r *replayTransport) connected(socketKey string) bool {
+	return true
+}
+
+func (r *replayTransport) writeLine(socketKey string, line string) bool {
+	function := "replayTransport.writeLine"
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, entry := range r.entries {
+		if r.used[i] || entry.Command != line {
+			continue
+		}
+		r.used[i] = true
+		if r.realtime {
+			time.Sleep(time.Duration(entry.Elapsed) * time.Millisecond)
+		}
+		r.pending = append(r.pending, entry.Unsolicited...)
+		r.pending = append(r.pending, entry.Response)
+		return true
+	}
+	r.missed = append(r.missed, line)
+	framework.AddToErrors(socketKey, fmt.Sprintf("%s - command not in transcript: %q", function, line))
+	return false
+}
+
+func (r *replayTransport) readLine(socketKey string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.pending) == 0 {
+		return ""
+	}
+	resp := r.pending[0]
+	r.pending = r.pending[1:]
+	return resp
+}
+
+func (r *replayTransport) readLineWithin(socketKey string, timeout time.Duration) string {
+	return r.readLine(socketKey) // answers are there as soon as the command is written
+}
+
+// Drops answers not read yet, like a reconnect would
+func (r *replayTransport) close(socketKey string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pending = nil
+}
+
+// Replaces the socket for socketKey with the transcript at path.
+// The model name from the transcript header is loaded into the model cache as if the banner had been read.
+func startTranscriptReplay(socketKey string, path string, realtime bool) error {
+	function := "startTranscriptReplay"
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("%s - error opening transcript: %w", function, err)
+	}
+	defer file.Close()
+
+	replay := &replayTransport{realtime: realtime}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var entry transcriptEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return fmt.Errorf("%s - bad transcript line in %s: %w", function, path, err)
+		}
+		if entry.Command == "" {
+			if entry.Model != "" {
+				setDeviceModel(socketKey, entry.Model)
+			}
+			continue
+		}
+		replay.entries = append(replay.entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("%s - error reading transcript: %w", function, err)
+	}
+	replay.used = make([]bool, len(replay.entries))
+
+	transports.Store(socketKey, replay)
+	framework.Log(fmt.Sprintf("%s - replaying %d commands from %s for %s", function, len(replay.entries), path, transcriptName(socketKey)))
+	return nil
+}
+
+// Puts the framework socket back in place for socketKey
+func stopTranscriptReplay(socketKey string) {
+	transports.Delete(socketKey)
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	verboseMode = false // the captures were taken with verbose mode off
+	loadDeviceProfiles()
+	os.Exit(m.Run())
+}
+
+type replayCall struct {
+	method   string // "GET" or "SET"
+	endpoint string
+	arg1     string
+	arg2     string
+	want     string
+}
+
+// Each capture is replayed in place of the socket and the calls run in order against it.
+// Every command in a capture must be asked for, so a parser that sends more or different commands fails too.
+var replayTests = []struct {
+	transcript string
+	calls      []replayCall
+}{
+	{"telnet_da4_23.jsonl", []replayCall{
+		{"GET", "inputstatus", "1", "", `"true"`}, // "1*0 0 1 0 0": input first, then loop out and outputs
+		{"GET", "videomute", "2", "", `"false"`},
+		{"SET", "videomute", "2", "true", "ok"}, // "Vmt2*1"
+	}},
+	{"telnet_sw4_23.jsonl", []replayCall{
+		{"GET", "inputstatus", "3", "", "true"}, // "1 0 1 0*0": inputs first, output last
+		{"GET", "inputstatus", "2", "", "false"},
+		{"GET", "videomute", "1", "", `"false"`},
+	}},
+	{"telnet_in1606_23.jsonl", []replayCall{
+		{"GET", "volume", "programvolume", "", "46"},
+		{"SET", "volume", "programvolume", "50", "ok"}, // "GrpmD1*-170"
+		{"GET", "audiomute", "programmute", "", `"false"`},
+		{"SET", "audiomute", "programmute", "true", "ok"}, // "GrpmD2*1"
+		{"SET", "videomute", "1A", "true", "ok"},          // "Vmt1*1"
+		{"GET", "videomute", "1A", "", `"true"`},
+		{"GET", "inputstatus", "4", "", "true"},
+	}},
+	{"telnet_dmp128plus_at_23.jsonl", []replayCall{ // only the model from the banner, dmpmixpoint doesn't ask the device
+		{"GET", "dmpmixpoint", "21205", "", `{"oid":"21205","table":"MicToOut","input":"MicToOut13","output":"6","name":"AT input 1 to output 6"}`},
+		{"GET", "dmpmixpoint", "22117", "", `{"oid":"22117","table":"VRetToSend","input":"VRetToSendB","output":"B","name":"virtual return B to virtual send B"}`},
+	}},
+	{"telnet_dmp128plus_gain_23.jsonl", []replayCall{ // each block's own range: 100% is +80 dB input gain but 0 dB on an output
+		{"SET", "dmpinputgain", "1", "100", "ok"},
+		{"GET", "dmpinputgain", "1", "", "100"},
+		{"SET", "dmpinputgain", "12", "0", "ok"}, // -18 dB
+		{"SET", "dmpoutputvolume", "3", "100", "ok"},
+		{"GET", "dmpoutputvolume", "3", "", "100"},
+		{"SET", "dmpoutputvolume", "8", "0", "ok"}, // -100 dB
+		{"SET", "dmpvirtualreturngain", "P", "100", "ok"},
+	}},
+	{"telnet_in1804_volumedb_23.jsonl", []replayCall{
+		{"GET", "volumedb", "programvolume", "", "-20.0"}, // "Vol-20", whole dB
+		{"SET", "volumedb", "programvolume", "-12", "ok"}, // "-12V"
+	}},
+	{"telnet_in1808_ties_23.jsonl", []replayCall{
+		{"SET", "videoroute", "1B", "3", "ok"}, // "Out1 In3 Vid", 1A and 1B are tie output 1
+		{"GET", "videoroute", "1A", "", `"3"`},
+		{"SET", "audioandvideoroute", "LoopOut", "5", "ok"}, // "Out2 In5 All"
+		{"GET", "audioandvideoroute", "LoopOut", "", `"5"`},
+		{"SET", "audioroute", "1A", "7", "ok"}, // "Out1 In7 Aud"
+		{"GET", "audioroute", "1B", "", `"7"`},
+	}},
+	{"telnet_in1606_ties_23.jsonl", []replayCall{
+		{"SET", "videoroute", "", "4", "ok"}, // "In4 RGB"
+		{"GET", "videoroute", "", "", `"4"`},
+		{"SET", "audioroute", "", "2", "ok"}, // "In2 Aud"
+		{"GET", "audioroute", "", "", `"2"`},
+	}},
+	{"telnet_sw4_ties_23.jsonl", []replayCall{
+		{"SET", "audioandvideoroute", "", "2", "ok"}, // "In02 All"
+		{"GET", "audioandvideoroute", "", "", `"2"`},
+	}},
+}
+
+func TestTranscriptReplay(t *testing.T) {
+	for _, tt := range replayTests {
+		t.Run(tt.transcript, func(t *testing.T) {
+			replayCalls(t, tt.transcript, tt.calls)
+		})
+	}
+}
+
+// Replays a capture from testdata/transcripts and runs calls against it, each must succeed with the answer it wants
+func replayCalls(t *testing.T, transcript string, calls []replayCall) {
+	t.Helper()
+	socketKey := "telnet|admin:extron@" + strings.TrimSuffix(transcript, ".jsonl")
+	if err := startTranscriptReplay(socketKey, filepath.Join("testdata", "transcripts", transcript), false); err != nil {
+		t.Fatal(err)
+	}
+	defer stopTranscriptReplay(socketKey)
+
+	for _, call := range calls {
+		var got string
+		var err error
+		if call.method == "GET" {
+			got, err = doDeviceSpecificGet(socketKey, call.endpoint, call.arg1, call.arg2)
+		} else {
+			got, err = doDeviceSpecificSet(socketKey, call.endpoint, call.arg1, call.arg2, "")
+		}
+		if err != nil {
+			t.Fatalf("%s %s %s %s: %v", call.method, call.endpoint, call.arg1, call.arg2, err)
+		}
+		if got != call.want {
+			t.Errorf("%s %s %s %s = %s, want %s", call.method, call.endpoint, call.arg1, call.arg2, got, call.want)
+		}
+	}
+
+	replay, _ := transports.Load(socketKey)
+	for i, used := range replay.(*replayTransport).used {
+		if !used {
+			t.Errorf("command %q in the capture was never sent", replay.(*replayTransport).entries[i].Command)
+		}
+	}
+}
+
+func TestTranscriptReplayUnknownCommand(t *testing.T) {
+	socketKey := "telnet|admin:extron@unknown_command"
+	if err := startTranscriptReplay(socketKey, filepath.Join("testdata", "transcripts", "telnet_da4_23.jsonl"), false); err != nil {
+		t.Fatal(err)
+	}
+	defer stopTranscriptReplay(socketKey)
+
+	if _, err := doDeviceSpecificGet(socketKey, "audiomute", "1", ""); err == nil {
+		t.Error("a command that is not in the capture should fail")
+	}
+}
@@ -0,0 +1,305 @@
+package main
+
+// Volume curves: how the 0-100 percent the volume endpoints take maps onto a level's range in tenths of a dB.
+//
+// A curve is a log curve with a bend k, a straight line in dB, or a table of (percent, dB) breakpoints.
+// "log" (k 11, unity at about 76% over -100 to +12 dB) is the default, "linear" is built in too.
+// More curves, and the curve each endpoint uses on every device, come from the JSON file in curveFile (SIS_VOLUME_CURVES):
+//
+//	{
+//	  "curves": {
+//	    "capped": {"type": "log", "k": 11, "maxDb": 0},
+//	    "room": {"type": "table", "points": [[0, -100], [50, -30], [80, -10], [100, 0]]}
+//	  },
+//	  "endpoints": {"matrixvolume": "linear"}
+//	}
+//
+// A device profile can pick a curve for the whole model (volumeCurve) or per endpoint (volumeCurves), see profiles.go.
+//
+// A table runs at the dB in its points when they fit in the level's range, otherwise its shape is stretched onto the range.
+// Percents that round to the same tenth of a dB are nudged apart, so over 10 dB or more every percent gets a level of its own
+// and setting a percent reads back the same percent.  Curves are checked for that against the range of every endpoint they are assigned to.
+// Only a range under 10 dB (ex: DMP group soft limits set close together) can't tell 101 percents apart, then percents that share a level read back as the lowest of them.
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mefranklin6/microservice-framework/framework"
+)
+
+type volumeCurve struct {
+	Type   string       `json:"type"`             // "log", "linear" or "table"
+	K      float64      `json:"k,omitempty"`      // log: bend, the smaller the more of the range goes to the bottom percents
+	MinDb  *float64     `json:"minDb,omitempty"`  // log and linear: level at 0%, the bottom of the range when not set
+	MaxDb  *float64     `json:"maxDb,omitempty"`  // log and linear: level at 100%, the top of the range when not set, ex: 0 to stop at unity
+	Points [][2]float64 `json:"points,omitempty"` // table: [percent, dB] from 0% to 100%, straight lines in between
+
+	name string
+}
+
+const defaultVolumeCurve = "log"
+
+var volumeCurves = map[string]*volumeCurve{ // name -> curve, the built-in ones and the ones from curveFile
+	"log":    {Type: "log", K: 11, name: "log"},
+	"linear": {Type: "linear", name: "linear"},
+}
+
+var endpointVolumeCurves = make(map[string]string) // endpoint -> curve name, for every device
+
+// Endpoints whose percent goes through a curve.  The IN1804's whole dB volume keeps its own 1% per dB scale (see transformVolumeIN1804)
+var volumeCurveEndpoints = []string{
+	"volume", "matrixvolume", "groupvolume", "dmpinputgain", "dmppremixergain", "dmpvirtualreturngain", "dmpoutputvolume",
+}
+
+// Loads the curves and endpoint assignments in curveFile, on top of the built-in curves.
+// Curves that fail validation are logged and skipped, the rest still load.
+func loadVolumeCurves() {
+	function := "loadVolumeCurves"
+
+	if curveFile == "" {
+		return
+	}
+	data, err := os.ReadFile(curveFile)
+	if err != nil {
+		framework.Log(fmt.Sprintf("%s - can't read volume curves %s: %v", function, curveFile, err))
+		return
+	}
+
+	var file struct {
+		Curves    map[string]*volumeCurve `json:"curves"`
+		Endpoints map[string]string       `json:"endpoints"`
+	}
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.DisallowUnknownFields() // catch typos in field names
+	if err := decoder.Decode(&file); err != nil {
+		framework.Log(fmt.Sprintf("%s - skipping volume curves %s: %v", function, curveFile, err))
+		return
+	}
+
+	for name, curve := range file.Curves {
+		if curve == nil {
+			continue
+		}
+		curve.name = name
+		if err := curve.validate(); err != nil {
+			framework.Log(fmt.Sprintf("%s - skipping volume curve '%s': %v", function, name, err))
+			continue
+		}
+		if _, replaces := volumeCurves[name]; replaces {
+			framework.Log(fmt.Sprintf("%s - volume curve '%s' replaces the built-in one", function, name))
+		}
+		volumeCurves[name] = curve
+	}
+	for endpoint, name := range file.Endpoints {
+		if err := checkVolumeCurveAssignment(endpoint, name); err != nil {
+			framework.Log(fmt.Sprintf("%s - skipping the curve for %s: %v", function, endpoint, err))
+			continue
+		}
+		endpointVolumeCurves[endpoint] = name
+	}
+	framework.Log(fmt.Sprintf("%s - loaded %d volume curves", function, len(volumeCurves)))
+}
+
+func (c *volumeCurve) validate() error {
+	switch c.Type {
+	case "log":
+		if c.K <= 0 {
+			return errors.New("a log curve needs a k above 0")
+		}
+	case "linear":
+		if c.K != 0 {
+			return errors.New("k is only for log curves")
+		}
+	case "table":
+		if c.K != 0 || c.MinDb != nil || c.MaxDb != nil {
+			return errors.New("a table curve takes its levels from its points, not k, minDb or maxDb")
+		}
+		if len(c.Points) < 2 || c.Points[0][0] != 0 || c.Points[len(c.Points)-1][0] != 100 {
+			return errors.New("a table curve needs points from 0% to 100%")
+		}
+		for i := 1; i < len(c.Points); i++ {
+			if c.Points[i][0] <= c.Points[i-1][0] || c.Points[i][1] <= c.Points[i-1][1] {
+				return fmt.Errorf("points must go up in both percent and dB, %v does not follow %v", c.Points[i], c.Points[i-1])
+			}
+		}
+	default:
+		return fmt.Errorf("unknown type %q, must be log, linear or table", c.Type)
+	}
+	if c.MinDb != nil && c.MaxDb != nil && *c.MinDb >= *c.MaxDb {
+		return errors.New("minDb must be below maxDb")
+	}
+	return nil
+}
+
+// Every percent needs a level of its own over minTenths to maxTenths, so a percent that was set reads back the same
+func (c *volumeCurve) checkRange(minTenths int, maxTenths int) error {
+	steps, err := c.steps(minTenths, maxTenths)
+	if err != nil {
+		return err
+	}
+	for percent := 1; percent < len(steps); percent++ {
+		if steps[percent] <= steps[percent-1] {
+			return fmt.Errorf("volume curve '%s' leaves less than 10 dB of %s to %s dB, %d%% and %d%% both come out at %s dB",
+				c.name, tenthsToDb(minTenths), tenthsToDb(maxTenths), percent-1, percent, tenthsToDb(steps[percent]))
+		}
+	}
+	return nil
+}
+
+// The range in tenths of a dB an endpoint's curve runs over.
+// For groupvolume that is the mix point range, each group's soft limits narrow it when the device is asked.
+func volumeCurveRange(endpoint string) (int, int) {
+	if block, exists := dmpGainBlocks[endpoint]; exists {
+		return block.MinTenths, block.MaxTenths
+	}
+	return minVolumeTenths, maxVolumeTenths
+}
+
+// Endpoint and curve name must both be known, and the curve must give every percent a level of its own over the endpoint's range
+func checkVolumeCurveAssignment(endpoint string, name string) error {
+	if !containsString(volumeCurveEndpoints, endpoint) {
+		return fmt.Errorf("%q does not take a volume curve, only: %s", endpoint, strings.Join(volumeCurveEndpoints, ", "))
+	}
+	curve, exists := volumeCurves[name]
+	if !exists {
+		return fmt.Errorf("unknown volume curve %q", name)
+	}
+	return curve.checkRange(volumeCurveRange(endpoint))
+}
+
+// The curve for an endpoint on a device: the profile's curve for the endpoint, the profile's curve for the model,
+// the curve for the endpoint on every device, then the default
+func volumeCurveFor(socketKey string, endpoint string) *volumeCurve {
+	if profile := profileForModel(socketKey, deviceModel(socketKey)); profile != nil {
+		if name, exists := profile.VolumeCurves[endpoint]; exists {
+			return volumeCurves[name]
+		}
+		if profile.VolumeCurve != "" {
+			return volumeCurves[profile.VolumeCurve]
+		}
+	}
+	if name, exists := endpointVolumeCurves[endpoint]; exists {
+		return volumeCurves[name]
+	}
+	return volumeCurves[defaultVolumeCurve]
+}
+
+// Tenths of a dB for each percent 0 to 100 over minTenths to maxTenths, going up by at least a tenth each percent when the range allows
+func (c *volumeCurve) steps(minTenths int, maxTenths int) ([]int, error) {
+	lower, upper := float64(minTenths), float64(maxTenths)
+	if c.MinDb != nil {
+		lower = math.Max(lower, *c.MinDb*10)
+	}
+	if c.MaxDb != nil {
+		upper = math.Min(upper, *c.MaxDb*10)
+	}
+	if lower >= upper {
+		return nil, fmt.Errorf("volume curve '%s' has nothing left of %s to %s dB", c.name, tenthsToDb(minTenths), tenthsToDb(maxTenths))
+	}
+
+	steps := make([]int, 101)
+	for percent := range steps {
+		steps[percent] = int(math.Round(c.level(float64(percent), lower, upper)))
+	}
+
+	// Where the curve is flatter than a tenth per percent, push the levels apart: up from the bottom, then down from the top
+	bottom, top := int(math.Ceil(lower)), int(math.Floor(upper))
+	if top-bottom < len(steps)-1 {
+		return steps, nil // under 10 dB, some percents have to share a level
+	}
+	for percent := 1; percent < len(steps); percent++ {
+		steps[percent] = max(steps[percent], steps[percent-1]+1)
+	}
+	steps[100] = min(steps[100], top)
+	for percent := len(steps) - 2; percent >= 0; percent-- {
+		steps[percent] = min(steps[percent], steps[percent+1]-1)
+	}
+	return steps, nil
+}
+
+// Level in tenths of a dB at percent, between lower and upper
+func (c *volumeCurve) level(percent float64, lower float64, upper float64) float64 {
+	switch c.Type {
+	case "log": // similar to Shure, normalized to [0,1]
+		normalized := math.Log10(1.0+percent/c.K) / math.Log10(1.0+100.0/c.K)
+		return lower + normalized*(upper-lower)
+	case "table":
+		tenths := 10 * c.Points[len(c.Points)-1][1]
+		for i := 1; i < len(c.Points); i++ {
+			from, to := c.Points[i-1], c.Points[i]
+			if percent <= to[0] {
+				tenths = 10 * (from[1] + (percent-from[0])/(to[0]-from[0])*(to[1]-from[1]))
+				break
+			}
+		}
+		first, last := 10*c.Points[0][1], 10*c.Points[len(c.Points)-1][1]
+		if first < lower || last > upper { // doesn't fit, keep its shape over the level's range
+			return lower + (tenths-first)/(last-first)*(upper-lower)
+		}
+		return tenths
+	default: // linear
+		return lower + percent/100*(upper-lower)
+	}
+}
+
+// Percent 0 to 100 to tenths of a dB between minTenths and maxTenths
+func (c *volumeCurve) toTenths(percent int, minTenths int, maxTenths int) (int, error) {
+	steps, err := c.steps(minTenths, maxTenths)
+	if err != nil {
+		return 0, err
+	}
+	if percent < 0 {
+		percent = 0
+	}
+	if percent > 100 {
+		percent = 100
+	}
+	return steps[percent], nil
+}
+
+// Tenths of a dB to the nearest percent 0 to 100.  A level toTenths gave returns the same percent.
+func (c *volumeCurve) toPercent(tenths int, minTenths int, maxTenths int) (int, error) {
+	steps, err := c.steps(minTenths, maxTenths)
+	if err != nil {
+		return 0, err
+	}
+	percent := sort.SearchInts(steps, tenths) // the first percent at or above tenths
+	switch {
+	case percent == 0:
+		return 0, nil
+	case percent > 100:
+		return 100, nil
+	case steps[percent] != tenths && tenths-steps[percent-1] < steps[percent]-tenths:
+		return percent - 1, nil
+	}
+	return percent, nil
+}
+
+// "-35" -> "-3.5"
+func tenthsToDb(tenths int) string {
+	return strconv.FormatFloat(float64(tenths)/10, 'f', 1, 64)
+}
+
+// "-3.5" -> -35.  At most one decimal, and the level must be between minTenths and maxTenths.
+func dbToTenths(db string, minTenths int, maxTenths int) (int, error) {
+	db = strings.TrimSpace(strings.Trim(db, `"`))
+	if !dbPattern.MatchString(db) {
+		return 0, fmt.Errorf("'%s' is not a level in dB with at most one decimal, ex: -3.5", db)
+	}
+	value, _ := strconv.ParseFloat(db, 64)
+	tenths := int(math.Round(value * 10))
+	if tenths < minTenths || tenths > maxTenths {
+		return 0, fmt.Errorf("%s dB is out of range, this level goes from %s to %s dB", tenthsToDb(tenths), tenthsToDb(minTenths), tenthsToDb(maxTenths))
+	}
+	return tenths, nil
+}
+
+var dbPattern = regexp.MustCompile(`^[+-]?\d+(\.\d)?$`)
@@ -0,0 +1,162 @@
+package main
+
+import "testing"
+
+func dbPtr(db float64) *float64 {
+	return &db
+}
+
+var roomCurve = &volumeCurve{Type: "table", Points: [][2]float64{{0, -100}, {50, -30}, {80, -10}, {100, 0}}, name: "room"}
+
+func TestVolumeCurveToTenths(t *testing.T) {
+	capped := &volumeCurve{Type: "log", K: 11, MaxDb: dbPtr(0), name: "capped"}
+
+	tests := []struct {
+		name      string
+		curve     *volumeCurve
+		minTenths int
+		maxTenths int
+		percent   int
+		want      int
+	}{
+		{"log bottom", volumeCurves["log"], -1000, 120, 0, -1000},
+		{"log top", volumeCurves["log"], -1000, 120, 100, 120},
+		{"log below 0%", volumeCurves["log"], -1000, 120, -5, -1000},
+		{"log above 100%", volumeCurves["log"], -1000, 120, 150, 120},
+		{"linear middle", volumeCurves["linear"], -1000, 0, 50, -500},
+		{"capped top", capped, -1000, 120, 100, 0},
+		{"table at its points", roomCurve, -1000, 120, 50, -300},
+		{"table at its points, top", roomCurve, -1000, 120, 100, 0},
+		{"table between points", roomCurve, -1000, 120, 65, -200},
+		{"table stretched, bottom", roomCurve, -180, 800, 0, -180},
+		{"table stretched, top", roomCurve, -180, 800, 100, 800},
+		{"table stretched onto soft limits", roomCurve, -400, 0, 0, -400},
+		{"table stretched onto soft limits, top", roomCurve, -400, 0, 100, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.curve.toTenths(tt.percent, tt.minTenths, tt.maxTenths)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("toTenths(%d) over %d to %d = %d, want %d", tt.percent, tt.minTenths, tt.maxTenths, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVolumeCurveToPercent(t *testing.T) {
+	tests := []struct {
+		name      string
+		curve     *volumeCurve
+		minTenths int
+		maxTenths int
+		tenths    int
+		want      int
+	}{
+		{"log unity", volumeCurves["log"], -1000, 120, 0, 76},
+		{"below the range", volumeCurves["log"], -1000, 120, -1200, 0},
+		{"above the range", volumeCurves["log"], -1000, 120, 200, 100},
+		{"linear between percents, nearest", volumeCurves["linear"], -1000, 0, -504, 50},
+		{"linear between percents, nearest above", volumeCurves["linear"], -1000, 0, -496, 50},
+		{"table", roomCurve, -1000, 120, -100, 80},
+		{"under 10 dB, a shared level reads back as the lowest percent", volumeCurves["linear"], -50, 0, -25, 50}, // 50% and 51%
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.curve.toPercent(tt.tenths, tt.minTenths, tt.maxTenths)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("toPercent(%d) over %d to %d = %d, want %d", tt.tenths, tt.minTenths, tt.maxTenths, got, tt.want)
+			}
+		})
+	}
+}
+
+// Every percent set must read back the same over any range of 10 dB or more
+func TestVolumeCurveRoundTrip(t *testing.T) {
+	ranges := [][2]int{{-1000, 120}, {-1000, 0}, {-180, 800}, {-200, 0}, {-300, -200}, {-100, 0}}
+	for _, curve := range []*volumeCurve{volumeCurves["log"], volumeCurves["linear"], roomCurve} {
+		for _, r := range ranges {
+			for percent := 0; percent <= 100; percent++ {
+				tenths, err := curve.toTenths(percent, r[0], r[1])
+				if err != nil {
+					t.Fatal(err)
+				}
+				if tenths < r[0] || tenths > r[1] {
+					t.Errorf("%s over %d to %d: %d%% is %d, outside the range", curve.name, r[0], r[1], percent, tenths)
+				}
+				back, _ := curve.toPercent(tenths, r[0], r[1])
+				if back != percent {
+					t.Errorf("%s over %d to %d: %d%% is %d, reads back as %d%%", curve.name, r[0], r[1], percent, tenths, back)
+				}
+			}
+		}
+	}
+}
+
+func TestCheckVolumeCurveAssignment(t *testing.T) {
+	volumeCurves["room"] = roomCurve
+	volumeCurves["quiet"] = &volumeCurve{Type: "log", K: 11, MaxDb: dbPtr(-12), name: "quiet"}
+	defer delete(volumeCurves, "room")
+	defer delete(volumeCurves, "quiet")
+
+	tests := []struct {
+		endpoint string
+		curve    string
+		ok       bool
+	}{
+		{"dmpinputgain", "room", true},
+		{"groupvolume", "room", true},
+		{"matrixvolume", "quiet", true},
+		{"dmpinputgain", "quiet", false}, // -18 to -12 dB
+		{"dmpinputgain", "missing", false},
+		{"videomute", "log", false},
+	}
+	for _, tt := range tests {
+		err := checkVolumeCurveAssignment(tt.endpoint, tt.curve)
+		if (err == nil) != tt.ok {
+			t.Errorf("checkVolumeCurveAssignment(%s, %s) = %v, want ok %v", tt.endpoint, tt.curve, err, tt.ok)
+		}
+	}
+}
+
+func TestDbToTenths(t *testing.T) {
+	tests := []struct {
+		db      string
+		min     int
+		max     int
+		want    int
+		wantErr bool
+	}{
+		{"-3.5", -1000, 120, -35, false},
+		{"+12", -1000, 120, 120, false},
+		{"12.1", -1000, 120, 0, true}, // above the range, not clamped
+		{"-100", -1000, 120, -1000, false},
+		{"-100.1", -1000, 120, 0, true},
+		{`"-20"`, -1000, 0, -200, false}, // quoted by the framework
+		{"80", -180, 800, 800, false},    // input gain goes to +80
+		{"1", -1000, 0, 0, true},         // attenuation only
+		{"-3.25", -1000, 120, 0, true},   // one decimal at most
+		{"-3.", -1000, 120, 0, true},
+		{"loud", -1000, 120, 0, true},
+		{"", -1000, 120, 0, true},
+	}
+	for _, tt := range tests {
+		got, err := dbToTenths(tt.db, tt.min, tt.max)
+		if (err != nil) != tt.wantErr || (err == nil && got != tt.want) {
+			t.Errorf("dbToTenths(%s, %d, %d) = %d, %v, want %d (error %v)", tt.db, tt.min, tt.max, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestTenthsToDb(t *testing.T) {
+	for tenths, want := range map[int]string{-35: "-3.5", 0: "0.0", 120: "12.0", -1000: "-100.0", -200: "-20.0", 5: "0.5", -5: "-0.5"} {
+		if got := tenthsToDb(tenths); got != want {
+			t.Errorf("tenthsToDb(%d) = %s, want %s", tenths, got, want)
+		}
+	}
+}